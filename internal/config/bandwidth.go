@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// parseRate 解析限速配置，返回每秒字节数，为空或 "0" 时返回 0（不限速）
+// 支持 "10MB"、"512KiB"、"10MB/s" 等写法
+func parseRate(s string) (int64, error) {
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "/s"))
+	if s == "" {
+		return 0, nil
+	}
+	n, err := humanize.ParseBytes(s)
+	if err != nil {
+		return 0, err
+	}
+	return int64(n), nil
+}
+
+// parseClock 解析 "HH:MM" 格式的时间，返回当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("时间格式应为 HH:MM: %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// validate 校验限速配置中的速率与时段
+func (b BandwidthConfig) validate() error {
+	_, _, err := b.compile()
+	return err
+}
+
+// rateWindow 解析后的时段限速，速率为 -1 时沿用默认限速
+type rateWindow struct {
+	start, end       int // 当天的分钟数
+	upload, download int64
+}
+
+// contains 判断一天中的第 minute 分钟是否位于时段内，end 不晚于 start 时时段跨越零点
+func (w rateWindow) contains(minute int) bool {
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// compile 解析默认限速与各时段限速
+func (b BandwidthConfig) compile() (rateWindow, []rateWindow, error) {
+	var def rateWindow
+	var err error
+	if def.upload, err = parseRate(b.Upload); err != nil {
+		return def, nil, fmt.Errorf("bandwidth.upload 无效: %w", err)
+	}
+	if def.download, err = parseRate(b.Download); err != nil {
+		return def, nil, fmt.Errorf("bandwidth.download 无效: %w", err)
+	}
+
+	windows := make([]rateWindow, 0, len(b.Windows))
+	for i, w := range b.Windows {
+		var rw rateWindow
+		if rw.start, err = parseClock(w.Start); err != nil {
+			return def, nil, fmt.Errorf("第 %d 个限速时段的 start 无效: %w", i+1, err)
+		}
+		if rw.end, err = parseClock(w.End); err != nil {
+			return def, nil, fmt.Errorf("第 %d 个限速时段的 end 无效: %w", i+1, err)
+		}
+		if rw.start == rw.end {
+			return def, nil, fmt.Errorf("第 %d 个限速时段的 start 与 end 相同", i+1)
+		}
+		rw.upload, rw.download = -1, -1
+		if w.Upload != "" {
+			if rw.upload, err = parseRate(w.Upload); err != nil {
+				return def, nil, fmt.Errorf("第 %d 个限速时段的 upload 无效: %w", i+1, err)
+			}
+		}
+		if w.Download != "" {
+			if rw.download, err = parseRate(w.Download); err != nil {
+				return def, nil, fmt.Errorf("第 %d 个限速时段的 download 无效: %w", i+1, err)
+			}
+		}
+		windows = append(windows, rw)
+	}
+	return def, windows, nil
+}
+
+// Rates 返回按时刻计算上传与下载限速（每秒字节数，0 表示不限速）的函数
+// 配置已在 LoadConfig 中校验，解析失败时按不限速处理
+func (b BandwidthConfig) Rates() (upload, download func(time.Time) int64) {
+	def, windows, err := b.compile()
+	if err != nil {
+		return nil, nil
+	}
+	rate := func(t time.Time, pick func(rateWindow) int64) int64 {
+		if len(windows) > 0 {
+			minute := t.Hour()*60 + t.Minute()
+			for _, w := range windows {
+				if w.contains(minute) && pick(w) >= 0 {
+					return pick(w)
+				}
+			}
+		}
+		return pick(def)
+	}
+	upload = func(t time.Time) int64 {
+		return rate(t, func(w rateWindow) int64 { return w.upload })
+	}
+	download = func(t time.Time) int64 {
+		return rate(t, func(w rateWindow) int64 { return w.download })
+	}
+	return upload, download
+}
+
+// Enabled 是否配置了任何限速
+func (b BandwidthConfig) Enabled() bool {
+	return b.Upload != "" || b.Download != "" || len(b.Windows) > 0
+}
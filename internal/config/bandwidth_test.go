@@ -0,0 +1,55 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBandwidthRates(t *testing.T) {
+	b := BandwidthConfig{
+		Download: "20MB",
+		Windows: []BandwidthWindow{
+			{Start: "08:00", End: "20:00", Upload: "10MB/s"},
+			{Start: "22:00", End: "06:00", Upload: "1MiB", Download: "0"},
+		},
+	}
+	if err := b.validate(); err != nil {
+		t.Fatal(err)
+	}
+	upload, download := b.Rates()
+
+	day := time.Date(2024, 6, 5, 0, 0, 0, 0, time.Local)
+	cases := []struct {
+		hour, minute     int
+		upload, download int64
+	}{
+		{7, 59, 0, 20_000_000},
+		{8, 0, 10_000_000, 20_000_000},
+		{19, 59, 10_000_000, 20_000_000},
+		{20, 0, 0, 20_000_000},
+		{23, 30, 1 << 20, 0},
+		{5, 59, 1 << 20, 0},
+		{6, 0, 0, 20_000_000},
+	}
+	for _, c := range cases {
+		now := day.Add(time.Duration(c.hour)*time.Hour + time.Duration(c.minute)*time.Minute)
+		if got := upload(now); got != c.upload {
+			t.Errorf("%02d:%02d upload = %d, want %d", c.hour, c.minute, got, c.upload)
+		}
+		if got := download(now); got != c.download {
+			t.Errorf("%02d:%02d download = %d, want %d", c.hour, c.minute, got, c.download)
+		}
+	}
+}
+
+func TestBandwidthInvalid(t *testing.T) {
+	for name, b := range map[string]BandwidthConfig{
+		"rate":       {Upload: "fast"},
+		"start":      {Windows: []BandwidthWindow{{Start: "25:00", End: "06:00"}}},
+		"empty span": {Windows: []BandwidthWindow{{Start: "08:00", End: "08:00"}}},
+	} {
+		if err := b.validate(); err == nil {
+			t.Errorf("%s: expected validate to fail", name)
+		}
+	}
+}
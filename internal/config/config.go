@@ -2,7 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/BurntSushi/toml"
@@ -10,12 +14,132 @@ import (
 )
 
 const (
-	DefaultKeepDays = 30
+	DefaultKeepDays         = 30
+	DefaultJobName          = "default"
+	DefaultFullIntervalDays = 7
+
+	DefaultHookTimeoutSeconds = 300
+
+	DefaultLockWaitMinutes        = 60
+	DefaultRemoteLockStaleMinutes = 10
+
+	DefaultRetryBackoffSeconds    = 300
+	DefaultRetryMaxBackoffSeconds = 3600
+
+	// MinPartSizeMB 分块大小下限：S3 要求除最后一块外的分块不小于 5MB，否则完成上传时报 EntityTooSmall
+	MinPartSizeMB = 5
+)
+
+// 备份格式
+const (
+	FormatArchive    = "archive"    // 每次备份生成一个 tar.zst 归档
+	FormatRepository = "repository" // 文件按内容分块去重存储，每次备份生成一个快照索引
+)
+
+// 数据库类型
+const (
+	DatabasePostgres = "postgres"
+	DatabaseMySQL    = "mysql"
+	DatabaseSQLite   = "sqlite"
+)
+
+// 通知消息格式
+const (
+	WebhookGeneric  = "generic"
+	WebhookWeCom    = "wecom"
+	WebhookDingTalk = "dingtalk"
+	WebhookSlack    = "slack"
+)
+
+// 存储后端类型
+const (
+	StorageCOS   = "cos"
+	StorageLocal = "local"
+	StorageS3    = "s3"
 )
 
 type Config struct {
-	Cos    CosConfig    `toml:"cos"`
-	Backup BackupConfig `toml:"backup"`
+	Cos        CosConfig        `toml:"cos"`
+	Storage    StorageConfig    `toml:"storage"` // 存储后端，未配置时使用 [cos]
+	Backup     BackupConfig     `toml:"backup"`
+	Jobs       []JobConfig      `toml:"jobs"` // 多个命名备份任务，配置后忽略 [backup]
+	Encryption EncryptionConfig `toml:"encryption"`
+	Notify     NotifyConfig     `toml:"notify"`    // 备份结果通知
+	Metrics    MetricsConfig    `toml:"metrics"`   // 服务模式下的 Prometheus 监控指标
+	Control    ControlConfig    `toml:"control"`   // 服务模式下的本地控制接口
+	Lock       LockConfig       `toml:"lock"`      // 防止同一任务的多次备份同时运行
+	Bandwidth  BandwidthConfig  `toml:"bandwidth"` // 上传与下载限速
+}
+
+// 任务已在运行时的处理方式
+const (
+	LockSkip = "skip" // 跳过本次备份
+	LockWait = "wait" // 等待上一次备份结束
+	LockFail = "fail" // 备份失败并报错
+)
+
+// LockConfig 任务锁配置
+// 每次备份在 state/locks 下创建任务锁文件（记录主机、PID 与开始时间），持有进程已退出的残留锁会被自动清除
+type LockConfig struct {
+	OnConflict         string `toml:"on_conflict"`          // 任务已在运行时: "skip"（默认）、"wait"、"fail"
+	WaitTimeoutMinutes int    `toml:"wait_timeout_minutes"` // wait 模式的最长等待时间（分钟），默认 60
+	Remote             bool   `toml:"remote"`               // 同时在任务前缀下写入锁对象，多台主机共用同一前缀时启用
+	RemoteStaleMinutes int    `toml:"remote_stale_minutes"` // 远程锁超过该时长未刷新视为残留（分钟），默认 10
+}
+
+// WaitTimeout 返回 wait 模式的最长等待时间
+func (l LockConfig) WaitTimeout() time.Duration {
+	if l.WaitTimeoutMinutes <= 0 {
+		return DefaultLockWaitMinutes * time.Minute
+	}
+	return time.Duration(l.WaitTimeoutMinutes) * time.Minute
+}
+
+// RemoteStale 返回远程锁未刷新多久后视为残留
+func (l LockConfig) RemoteStale() time.Duration {
+	if l.RemoteStaleMinutes <= 0 {
+		return DefaultRemoteLockStaleMinutes * time.Minute
+	}
+	return time.Duration(l.RemoteStaleMinutes) * time.Minute
+}
+
+// BandwidthConfig 上传与下载限速，速率为每秒字节数，如 "10MB"、"512KiB"，为空或 "0" 表示不限速
+// 同一进程内同时进行的传输共用限速；时段按本机时区判断，多个时段重叠时使用配置在前面、且设置了对应限速的时段
+type BandwidthConfig struct {
+	Upload   string            `toml:"upload"`   // 默认上传限速（归档上传、分块上传与流式上传）
+	Download string            `toml:"download"` // 默认下载限速（恢复时下载归档）
+	Windows  []BandwidthWindow `toml:"window"`   // 按时段覆盖默认限速
+}
+
+// BandwidthWindow 时段限速，end 不晚于 start 时表示跨越零点，如 22:00-06:00
+type BandwidthWindow struct {
+	Start    string `toml:"start"`    // 开始时间，如 "08:00"
+	End      string `toml:"end"`      // 结束时间（不含），如 "20:00"
+	Upload   string `toml:"upload"`   // 时段内的上传限速，为空时沿用默认限速
+	Download string `toml:"download"` // 时段内的下载限速，为空时沿用默认限速
+}
+
+// MetricsConfig Prometheus 监控指标配置
+type MetricsConfig struct {
+	Listen string `toml:"listen"` // 监听地址，如 "127.0.0.1:9101"，指标位于 /metrics；为空时不启用
+}
+
+// DefaultControlSocket 控制接口默认的 Unix socket 路径，与 logs、state 一样位于工作目录下
+var DefaultControlSocket = filepath.Join("state", "backup-go.sock")
+
+// ControlConfig 本地控制接口配置，交互式菜单通过它与后台服务通信
+// 控制接口没有鉴权，只能监听 Unix socket（权限 0600）或本机回环地址
+type ControlConfig struct {
+	Socket string `toml:"socket"` // Unix socket 路径，默认 state/backup-go.sock
+	Listen string `toml:"listen"` // 额外的 HTTP 监听地址，如 "127.0.0.1:9102"，只允许回环地址；为空时不启用
+}
+
+// SocketPath 返回控制接口的 Unix socket 路径
+func (c ControlConfig) SocketPath() string {
+	if c.Socket == "" {
+		return DefaultControlSocket
+	}
+	return c.Socket
 }
 
 type CosConfig struct {
@@ -25,18 +149,240 @@ type CosConfig struct {
 	Region    string `toml:"region"`
 	Prefix    string `toml:"prefix"`
 	KeepDays  int    `toml:"keep_days"` // COS备份文件保留天数
+	RetentionConfig
+
+	PruneDryRun     bool `toml:"prune_dry_run"`     // 清理过期备份时只输出清理计划，不实际删除
+	PruneMaxPercent int  `toml:"prune_max_percent"` // 单次清理最多删除匹配备份的百分比，超过时中止清理，默认 50，100 表示不限制
+
+	StreamUpload bool `toml:"stream_upload"` // 流式上传：打包压缩的数据直接分块上传，不在本地生成临时文件
+	PartSizeMB   int  `toml:"part_size_mb"`  // 分块大小（MB），默认 32，不小于 5，单个文件最多 10000 个分块
+
+	MultipartThresholdMB int `toml:"multipart_threshold_mb"` // 超过该大小（MB）的文件使用可续传的分块上传，默认 64
+}
+
+// RetentionConfig 按时间分级保留备份（GFS），与 keep_days 保留的备份取并集，0 表示不使用该规则
+// 每个周期保留其中最新的一个备份；无论规则如何，最新的一个备份总会保留
+type RetentionConfig struct {
+	KeepLast    int `toml:"keep_last"`    // 保留最新的若干个备份
+	KeepDaily   int `toml:"keep_daily"`   // 保留最近若干天每天最新的备份
+	KeepWeekly  int `toml:"keep_weekly"`  // 保留最近若干周每周最新的备份
+	KeepMonthly int `toml:"keep_monthly"` // 保留最近若干月每月最新的备份
+	KeepYearly  int `toml:"keep_yearly"`  // 保留最近若干年每年最新的备份
+}
+
+// StorageConfig 存储后端配置
+// [cos] 中的 prefix、keep_days、stream_upload 等传输与保留选项对所有后端生效
+type StorageConfig struct {
+	Type      string `toml:"type"`       // 存储类型: "cos"（默认）、"local"、"s3"
+	Path      string `toml:"path"`       // local: 备份目录（本地磁盘或挂载的 NAS）
+	Endpoint  string `toml:"endpoint"`   // s3: 服务地址，如 "https://s3.amazonaws.com"、"http://minio:9000"
+	Bucket    string `toml:"bucket"`     // s3: 存储桶名称
+	Region    string `toml:"region"`     // s3: 地域，如 "us-east-1"，R2 使用 "auto"
+	AccessKey string `toml:"access_key"` // s3: 访问密钥
+	SecretKey string `toml:"secret_key"` // s3: 访问密钥 Secret
+	PathStyle bool   `toml:"path_style"` // s3: 使用路径风格访问（MinIO 通常需要开启）
+}
+
+// StorageTarget 返回存储位置的可读描述
+func (c *Config) StorageTarget() string {
+	switch c.Storage.Type {
+	case StorageLocal:
+		return "local:" + c.Storage.Path
+	case StorageS3:
+		return "s3://" + c.Storage.Bucket
+	default:
+		return "cos://" + c.Cos.Bucket
+	}
+}
+
+// NotifyConfig 备份结果通知，每个任务执行结束后向所有渠道发送一次
+type NotifyConfig struct {
+	Webhooks []WebhookConfig `toml:"webhook"`
+	Emails   []EmailConfig   `toml:"email"`
+}
+
+// WebhookConfig HTTP webhook 通知渠道
+type WebhookConfig struct {
+	Type          string            `toml:"type"`            // 消息格式: "generic"（默认，JSON）、"wecom"、"dingtalk"、"slack"
+	URL           string            `toml:"url"`             // webhook 地址
+	Headers       map[string]string `toml:"headers"`         // generic: 附加的请求头，如鉴权信息
+	SecretEnv     string            `toml:"secret_env"`      // dingtalk: 加签密钥所在的环境变量名
+	OnFailureOnly bool              `toml:"on_failure_only"` // 只在备份失败时通知
+}
+
+// EmailConfig SMTP 邮件通知渠道
+type EmailConfig struct {
+	Host          string   `toml:"host"`            // SMTP 服务器地址
+	Port          int      `toml:"port"`            // 端口，默认 587（STARTTLS），465 使用 SSL 直连
+	Username      string   `toml:"username"`        // 登录用户名，为空时不认证
+	PasswordEnv   string   `toml:"password_env"`    // 登录密码所在的环境变量名
+	From          string   `toml:"from"`            // 发件人，默认为 username
+	To            []string `toml:"to"`              // 收件人
+	OnFailureOnly bool     `toml:"on_failure_only"` // 只在备份失败时通知
+}
+
+// EncryptionConfig 客户端加密配置，配置中只保存密钥的引用（文件路径或环境变量名）
+type EncryptionConfig struct {
+	Mode           string   `toml:"mode"`            // 加密方式: ""（不加密）、"age"、"passphrase"
+	Recipients     []string `toml:"recipients"`      // age 模式: 接收方公钥（age1...）
+	IdentityFile   string   `toml:"identity_file"`   // age 模式: 私钥文件路径，恢复时使用
+	PassphraseFile string   `toml:"passphrase_file"` // passphrase 模式: 口令文件路径
+	PassphraseEnv  string   `toml:"passphrase_env"`  // passphrase 模式: 口令所在的环境变量名
 }
 
 type BackupConfig struct {
 	DataDir  string         `toml:"data_dir"`
+	Sources  []string       `toml:"sources"` // 多个源目录，配置后忽略 data_dir，每个目录在归档中位于以其目录名命名的顶层目录下
+	Include  []string       `toml:"include"` // 仅备份匹配的路径（gitignore 风格，为空表示全部）
+	Exclude  []string       `toml:"exclude"` // 排除匹配的路径（gitignore 风格，支持 **）
 	Schedule ScheduleConfig `toml:"schedule"`
+
+	Format           string `toml:"format"`             // 备份格式: "archive"（默认）、"repository"
+	Incremental      bool   `toml:"incremental"`        // 增量备份：只打包相对上次备份新增或变化的文件（仅 archive 格式）
+	FullIntervalDays int    `toml:"full_interval_days"` // 距上次完整备份超过该天数时重新执行完整备份，默认 7
+
+	Databases []DatabaseConfig `toml:"databases"` // 数据库导出源，导出文件位于归档的 databases/ 目录下
+
+	HooksConfig
+}
+
+// FullInterval 返回两次完整备份之间的最长间隔
+func (b BackupConfig) FullInterval() time.Duration {
+	days := b.FullIntervalDays
+	if days <= 0 {
+		days = DefaultFullIntervalDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// DatabaseConfig 数据库导出源，每次备份时导出，导出内容作为一个文件与源目录一起打包
+type DatabaseConfig struct {
+	Type        string   `toml:"type"`         // 数据库类型: "postgres"、"mysql"、"sqlite"
+	Database    string   `toml:"database"`     // 数据库名称；sqlite 为数据库文件路径
+	Name        string   `toml:"name"`         // 导出文件名，默认为数据库名加 .sql（sqlite 为数据库文件名）
+	Host        string   `toml:"host"`         // postgres/mysql: 主机地址，为空时使用客户端默认值（本机 socket）
+	Port        int      `toml:"port"`         // postgres/mysql: 端口
+	User        string   `toml:"user"`         // postgres/mysql: 用户名
+	PasswordEnv string   `toml:"password_env"` // postgres/mysql: 密码所在的环境变量名
+	Command     string   `toml:"command"`      // 导出程序路径，默认 pg_dump、mysqldump、sqlite3
+	Args        []string `toml:"args"`         // 传给导出程序的额外参数
+}
+
+// FileName 返回导出文件在归档 databases/ 目录下的文件名
+func (d DatabaseConfig) FileName() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	if d.Type == DatabaseSQLite {
+		return filepath.Base(d.Database)
+	}
+	return d.Database + ".sql"
+}
+
+// HooksConfig 备份前后执行的命令，通过 sh -c（Windows 上为 cmd /C）执行，运行信息以 BACKUP_* 环境变量传入
+type HooksConfig struct {
+	PreHooks              []string `toml:"pre_hooks"`                 // 备份前依次执行，如导出数据库到源目录
+	PostHooks             []string `toml:"post_hooks"`                // 备份结束后依次执行（无论成功与否），BACKUP_STATUS 为本次结果
+	OnFailure             []string `toml:"on_failure"`                // 备份失败时依次执行（在 post_hooks 之前）
+	HookTimeoutSeconds    int      `toml:"hook_timeout_seconds"`      // 单个命令的超时时间（秒），默认 300
+	AbortOnPreHookFailure bool     `toml:"abort_on_pre_hook_failure"` // 前置命令失败时中止本次备份
+}
+
+// HookTimeout 返回单个钩子命令的超时时间
+func (h HooksConfig) HookTimeout() time.Duration {
+	seconds := h.HookTimeoutSeconds
+	if seconds <= 0 {
+		seconds = DefaultHookTimeoutSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// JobConfig 命名备份任务，拥有独立的源目录、存储前缀、保留策略和定时配置
+type JobConfig struct {
+	Name            string `toml:"name"`      // 任务名称（唯一）
+	Prefix          string `toml:"prefix"`    // 存储目录前缀，为空时使用 cos.prefix + 任务名称
+	KeepDays        int    `toml:"keep_days"` // 保留天数，0 表示沿用 cos.keep_days，负数表示不清理
+	RetentionConfig        // 分级保留规则，全部为 0 时沿用 [cos] 中的配置
+	BackupConfig
 }
 
 type ScheduleConfig struct {
 	Enabled  bool   `toml:"enabled"`
+	Cron     string `toml:"cron"`     // cron 表达式，如 "0 * * * *"、"@daily"、"@every 6h"；配置后忽略 hour/minute
 	Hour     int    `toml:"hour"`     // 小时 (0-23)
 	Minute   int    `toml:"minute"`   // 分钟 (0-59)
 	Timezone string `toml:"timezone"` // 时区，如 "Asia/Shanghai"
+
+	// 服务停止或系统休眠期间错过定时运行时的处理方式: "none"（默认，只记录警告）、"once"（补跑一次）
+	CatchUp            string `toml:"catch_up"`
+	MaxLatenessMinutes int    `toml:"max_lateness_minutes"` // 错过超过该时长（分钟）的定时运行不再补跑，0 表示不限制
+
+	Retry RetryConfig `toml:"retry"` // 定时备份失败后的重试策略
+}
+
+// RetryConfig 定时与补跑备份失败后的重试策略，与单次上传失败的重试相互独立
+// 第 n 次重试前等待 backoff_seconds × 2^(n-1)（不超过 max_backoff_seconds），实际等待时间在其一半到全部之间随机
+type RetryConfig struct {
+	Attempts          int `toml:"attempts"`            // 失败后最多重试的次数，0 表示不重试
+	BackoffSeconds    int `toml:"backoff_seconds"`     // 第一次重试前的等待时间（秒），默认 300
+	MaxBackoffSeconds int `toml:"max_backoff_seconds"` // 单次等待时间上限（秒），默认 3600
+	DeadlineMinutes   int `toml:"deadline_minutes"`    // 距第一次运行开始超过该时长（分钟）后不再重试，默认不超过下一次定时运行
+}
+
+// Backoff 返回第 n 次重试前的等待时间（未加随机抖动）
+func (r RetryConfig) Backoff(n int) time.Duration {
+	base := time.Duration(r.BackoffSeconds) * time.Second
+	if base <= 0 {
+		base = DefaultRetryBackoffSeconds * time.Second
+	}
+	limit := time.Duration(r.MaxBackoffSeconds) * time.Second
+	if limit <= 0 {
+		limit = DefaultRetryMaxBackoffSeconds * time.Second
+	}
+	d := base
+	for i := 1; i < n && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+// Deadline 返回不再重试的时间点，未配置 deadline_minutes 时为 next（下一次定时运行）
+func (r RetryConfig) Deadline(start, next time.Time) time.Time {
+	if r.DeadlineMinutes > 0 {
+		return start.Add(time.Duration(r.DeadlineMinutes) * time.Minute)
+	}
+	return next
+}
+
+// 错过定时运行时的处理方式
+const (
+	CatchUpNone = "none"
+	CatchUpOnce = "once"
+)
+
+// MaxLateness 返回允许补跑的最大延迟，0 表示不限制
+func (s ScheduleConfig) MaxLateness() time.Duration {
+	return time.Duration(max(s.MaxLatenessMinutes, 0)) * time.Minute
+}
+
+// Describe 返回定时配置的可读描述
+func (s ScheduleConfig) Describe() string {
+	tz := s.Timezone
+	if tz == "" {
+		tz = "本地时区"
+	}
+	if s.Cron != "" {
+		return fmt.Sprintf("cron %q (时区: %s)", s.Cron, tz)
+	}
+	return fmt.Sprintf("每天 %02d:%02d (时区: %s)", s.Hour, s.Minute, tz)
+}
+
+// cronSpec 返回定时配置对应的 cron 表达式
+func (s ScheduleConfig) cronSpec() string {
+	if s.Cron != "" {
+		return s.Cron
+	}
+	return fmt.Sprintf("%d %d * * *", s.Minute, s.Hour)
 }
 
 // SaveConfig 保存配置到文件
@@ -66,10 +412,226 @@ func LoadConfig(cfgPath string) (*Config, error) {
 	if _, err := toml.Decode(string(data), &cfg); err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
+	if err := cfg.validateStorage(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateJobs(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateNotify(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateControl(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Bandwidth.validate(); err != nil {
+		return nil, err
+	}
+	switch cfg.Lock.OnConflict {
+	case "":
+		cfg.Lock.OnConflict = LockSkip
+	case LockSkip, LockWait, LockFail:
+	default:
+		return nil, fmt.Errorf("lock.on_conflict 无效: %q（可选 skip、wait、fail）", cfg.Lock.OnConflict)
+	}
 
 	return &cfg, nil
 }
 
+// validateStorage 校验存储后端配置
+func (c *Config) validateStorage() error {
+	switch c.Storage.Type {
+	case "":
+		c.Storage.Type = StorageCOS
+	case StorageCOS:
+	case StorageLocal:
+		if c.Storage.Path == "" {
+			return fmt.Errorf("本地存储需要配置 storage.path")
+		}
+	case StorageS3:
+		if c.Storage.Endpoint == "" || c.Storage.Bucket == "" {
+			return fmt.Errorf("S3 存储需要配置 storage.endpoint 和 storage.bucket")
+		}
+	default:
+		return fmt.Errorf("不支持的存储类型: %q（可选 cos、local、s3）", c.Storage.Type)
+	}
+	if c.Cos.PartSizeMB > 0 && c.Cos.PartSizeMB < MinPartSizeMB {
+		return fmt.Errorf("cos.part_size_mb 不能小于 %d", MinPartSizeMB)
+	}
+	return nil
+}
+
+// validateNotify 校验通知渠道配置
+func (c *Config) validateNotify() error {
+	for i, w := range c.Notify.Webhooks {
+		switch w.Type {
+		case "":
+			c.Notify.Webhooks[i].Type = WebhookGeneric
+		case WebhookGeneric, WebhookWeCom, WebhookDingTalk, WebhookSlack:
+		default:
+			return fmt.Errorf("不支持的 webhook 类型: %q（可选 generic、wecom、dingtalk、slack）", w.Type)
+		}
+		if w.URL == "" {
+			return fmt.Errorf("第 %d 个 webhook 缺少 url", i+1)
+		}
+	}
+	for i, e := range c.Notify.Emails {
+		if e.Host == "" || len(e.To) == 0 {
+			return fmt.Errorf("第 %d 个邮件通知需要配置 host 和 to", i+1)
+		}
+		if e.From == "" && e.Username == "" {
+			return fmt.Errorf("第 %d 个邮件通知需要配置 from", i+1)
+		}
+	}
+	return nil
+}
+
+// validateControl 校验控制接口配置，没有鉴权的 HTTP 接口只允许监听本机回环地址
+func (c *Config) validateControl() error {
+	if c.Control.Listen == "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(c.Control.Listen)
+	if err != nil {
+		return fmt.Errorf("control.listen 格式错误: %w", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("control.listen 只能监听本机回环地址（如 127.0.0.1），当前为 %q", c.Control.Listen)
+	}
+	return nil
+}
+
+// validateDatabases 校验任务的数据库导出源，导出文件名不能重复
+func validateDatabases(job JobConfig) error {
+	names := make(map[string]bool)
+	for _, db := range job.Databases {
+		switch db.Type {
+		case DatabasePostgres, DatabaseMySQL, DatabaseSQLite:
+		default:
+			return fmt.Errorf("任务 %s 的数据库类型无效: %q（可选 postgres、mysql、sqlite）", job.Name, db.Type)
+		}
+		if db.Database == "" {
+			return fmt.Errorf("任务 %s 的 %s 数据库缺少 database", job.Name, db.Type)
+		}
+		name := db.FileName()
+		if name == "" || name == "." || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("任务 %s 的数据库导出文件名无效: %q", job.Name, name)
+		}
+		if names[name] {
+			return fmt.Errorf("任务 %s 的数据库导出文件名重复: %s，请通过 name 区分", job.Name, name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// validateJobs 校验任务名称非空且唯一
+func (c *Config) validateJobs() error {
+	seen := make(map[string]bool)
+	for i, job := range c.Jobs {
+		if job.Name == "" {
+			return fmt.Errorf("第 %d 个任务缺少 name", i+1)
+		}
+		// 任务名称直接用于本地临时目录与锁文件名，不能包含路径分隔符、":" 或 ".."
+		if strings.ContainsAny(job.Name, `/\:`) || strings.Contains(job.Name, "..") || job.Name == "." {
+			return fmt.Errorf("任务名称无效: %q（不能包含 /、\\、: 或 ..）", job.Name)
+		}
+		if seen[job.Name] {
+			return fmt.Errorf("任务名称重复: %s", job.Name)
+		}
+		seen[job.Name] = true
+	}
+
+	for _, job := range c.JobList() {
+		if _, err := parseCron(job.Schedule.cronSpec()); err != nil {
+			return fmt.Errorf("任务 %s 的定时配置无效: %w", job.Name, err)
+		}
+		switch job.Format {
+		case "", FormatArchive, FormatRepository:
+		default:
+			return fmt.Errorf("任务 %s 的备份格式无效: %q（可选 archive、repository）", job.Name, job.Format)
+		}
+		if err := validateDatabases(job); err != nil {
+			return err
+		}
+		switch job.Schedule.CatchUp {
+		case "", CatchUpNone, CatchUpOnce:
+		default:
+			return fmt.Errorf("任务 %s 的 catch_up 无效: %q（可选 none、once）", job.Name, job.Schedule.CatchUp)
+		}
+	}
+
+	// 任务前缀不能互相包含，否则清理过期备份时会误删其他任务的备份
+	jobs := c.JobList()
+	for i := range jobs {
+		for j := range jobs {
+			if i != j && strings.HasPrefix(jobs[j].Prefix, jobs[i].Prefix) {
+				return fmt.Errorf("任务 %s 的前缀 %q 包含了任务 %s 的前缀 %q", jobs[i].Name, jobs[i].Prefix, jobs[j].Name, jobs[j].Prefix)
+			}
+		}
+	}
+	return nil
+}
+
+// JobList 返回生效的备份任务列表
+// 未配置 [[jobs]] 时，将 [backup] 与 [cos] 组合为一个名为 "default" 的任务
+func (c *Config) JobList() []JobConfig {
+	if len(c.Jobs) == 0 {
+		return []JobConfig{{
+			Name:            DefaultJobName,
+			Prefix:          c.Cos.Prefix,
+			KeepDays:        c.Cos.KeepDays,
+			RetentionConfig: c.Cos.RetentionConfig,
+			BackupConfig:    c.Backup,
+		}}
+	}
+
+	jobs := make([]JobConfig, 0, len(c.Jobs))
+	for _, job := range c.Jobs {
+		if job.Prefix == "" {
+			job.Prefix = path.Join(c.Cos.Prefix, job.Name) + "/"
+		}
+		if job.KeepDays == 0 {
+			job.KeepDays = c.Cos.KeepDays
+		}
+		if job.RetentionConfig == (RetentionConfig{}) {
+			job.RetentionConfig = c.Cos.RetentionConfig
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// FindJob 按名称查找任务
+func (c *Config) FindJob(name string) (JobConfig, error) {
+	for _, job := range c.JobList() {
+		if job.Name == name {
+			return job, nil
+		}
+	}
+	return JobConfig{}, fmt.Errorf("未找到备份任务: %s", name)
+}
+
+// NextJobRun 计算启用定时的任务中最早的下次运行时间，并返回在该时间到期的所有任务
+func NextJobRun(jobs []JobConfig) (time.Time, []JobConfig) {
+	var next time.Time
+	var due []JobConfig
+	for _, job := range jobs {
+		if !job.Schedule.Enabled {
+			continue
+		}
+		t := CalculateNextRunTime(job.Schedule)
+		switch {
+		case next.IsZero() || t.Before(next):
+			next = t
+			due = []JobConfig{job}
+		case t.Equal(next):
+			due = append(due, job)
+		}
+	}
+	return next, due
+}
+
 // GenerateDefaultConfig 生成默认配置
 func GenerateDefaultConfig(configPath string) error {
 	// 手动创建带注释的TOML配置内容
@@ -81,17 +643,125 @@ bucket     = "your-bucket-name-appid"                 # COS存储桶名称（必
 region     = "ap-shanghai"                            # COS地域（如：ap-shanghai, ap-beijing）
 prefix     = "backup/"                                # COS存储目录前缀
 keep_days  = 30                                       # COS备份文件保留天数
+# keep_last    = 3                                    # 分级保留（与 keep_days 取并集）：最新的若干个备份
+# keep_daily   = 7                                    # 最近 7 天每天保留最新的一个
+# keep_weekly  = 4                                    # 最近 4 周每周保留最新的一个
+# keep_monthly = 12                                   # 最近 12 个月每月保留最新的一个
+# keep_yearly  = 3                                    # 最近 3 年每年保留最新的一个
+# prune_dry_run = false                               # 只输出清理计划（删除哪些备份及原因），不实际删除
+# prune_max_percent = 50                              # 单次清理删除超过该比例的备份时中止，防止前缀或保留天数配置错误误删
+stream_upload = false                                 # 流式上传（不占用本地磁盘空间，内存中仅缓存一个分块）
+part_size_mb  = 32                                    # 分块大小（MB，不小于 5），单个备份最大 = 分块大小 × 10000
+multipart_threshold_mb = 64                           # 超过该大小的归档使用分块上传，中断后下次运行自动续传
+
+# 存储后端（可选）：默认使用上面的 COS，也可以改为本地/NAS 目录或 S3 兼容存储（AWS S3、MinIO、R2）
+# [cos] 中的 prefix、keep_days、stream_upload、part_size_mb 对所有存储后端生效
+[storage]
+type = "cos"                                          # 存储类型: "cos"、"local"、"s3"
+# path       = "/mnt/nas/backup"                      # local: 备份目录
+# endpoint   = "https://s3.amazonaws.com"             # s3: 服务地址（MinIO 如 "http://minio:9000"）
+# bucket     = "my-backup"                            # s3: 存储桶名称
+# region     = "us-east-1"                            # s3: 地域（R2 使用 "auto"）
+# access_key = "xxxxxxxx"                             # s3: 访问密钥
+# secret_key = "xxxxxxxx"                             # s3: 访问密钥 Secret
+# path_style = false                                  # s3: 路径风格访问（MinIO 通常需要开启）
 
 # 本地备份配置
 [backup]
 data_dir = "./data"                                   # 本地需要备份的源目录（支持相对路径或绝对路径）
+# sources = ["/etc", "/var/www"]                      # 多个源目录（配置后忽略 data_dir），归档内按目录名分别存放
+include  = []                                         # 仅备份匹配的路径（gitignore 风格，为空表示全部），如 ["*.sql", "www/"]
+exclude  = ["node_modules/", ".git/", "*.tmp"]        # 排除匹配的路径（gitignore 风格，支持 **），子目录中的 .backupignore 同样生效
+format   = "archive"                                  # 备份格式: "archive"（每次生成完整归档）、"repository"（分块去重仓库）
+incremental = false                                   # 增量备份：只打包相对上次备份新增或变化的文件，记录删除的文件
+full_interval_days = 7                                # 增量模式下每隔多少天执行一次完整备份
+# pre_hooks  = ["pg_dump -Fc mydb -f ./data/mydb.dump"] # 备份前执行的命令（sh -c），运行信息通过 BACKUP_* 环境变量传入
+# post_hooks = ["rm -f ./data/mydb.dump"]             # 备份结束后执行（无论成功与否），BACKUP_STATUS 为 success/failure/skipped
+# on_failure = ["logger -t backup-go \"$BACKUP_ERROR\""] # 备份失败时执行
+# hook_timeout_seconds = 300                          # 单个命令超时时间（秒）
+# abort_on_pre_hook_failure = true                    # 前置命令失败时中止本次备份
+
+# [[backup.databases]]                                # 数据库导出源（可配置多个），导出文件位于归档的 databases/ 目录下
+# type     = "postgres"                               # "postgres"（pg_dump）、"mysql"（mysqldump）、"sqlite"（在线备份）
+# database = "mydb"                                   # 数据库名称；sqlite 为数据库文件路径
+# host     = "127.0.0.1"
+# user     = "backup"
+# password_env = "PGPASSWORD_MYDB"                    # 密码所在的环境变量名
+# args     = ["--no-owner"]                           # 传给导出程序的额外参数
 
 # 定时任务配置
 [backup.schedule]
 enabled  = false                                      # 是否启用定时任务
+# cron   = "0 */6 * * *"                              # cron 表达式（分 时 日 月 周，也支持 @hourly/@daily/@every 6h），配置后忽略 hour/minute
 hour     = 2                                          # 执行小时（24小时制，0-23）
 minute   = 0                                          # 执行分钟（0-59）
 timezone = "Asia/Shanghai"                            # 时区设置
+# catch_up = "once"                                   # 服务停止或休眠期间错过定时时: "none"（只记录警告）、"once"（启动/唤醒后补跑一次）
+# max_lateness_minutes = 720                          # 错过超过该时长的定时不再补跑，0 表示不限制
+# [backup.schedule.retry]                             # 定时备份失败后重试（与单次上传的重试相互独立）
+# attempts = 3                                        # 最多重试次数，0 表示不重试
+# backoff_seconds = 300                               # 首次重试前等待时间，之后每次翻倍（带随机抖动）
+# max_backoff_seconds = 3600                          # 单次等待时间上限
+# deadline_minutes = 360                              # 距第一次运行超过该时长不再重试，默认不超过下一次定时运行
+
+# 客户端加密（可选）：归档在上传前加密，恢复时自动解密
+[encryption]
+mode = ""                                             # 加密方式: ""（不加密）、"age"、"passphrase"
+# recipients      = ["age1..."]                       # age 模式: 接收方公钥
+# identity_file   = "/path/to/age-key.txt"            # age 模式: 私钥文件，恢复时使用
+# passphrase_file = "/path/to/passphrase"             # passphrase 模式: 口令文件（AES-256-GCM）
+# passphrase_env  = "BACKUP_PASSPHRASE"               # passphrase 模式: 或从环境变量读取口令
+
+# 备份结果通知（可选）：每个任务执行结束后发送，可配置多个渠道
+# [[notify.webhook]]
+# type = "generic"                                    # "generic"（JSON）、"wecom"（企业微信）、"dingtalk"（钉钉）、"slack"
+# url  = "https://example.com/backup-hook"
+# on_failure_only = false                             # true: 只在备份失败时通知
+# [[notify.email]]
+# host = "smtp.example.com"
+# port = 465                                          # 465: SSL 直连；587: STARTTLS
+# username = "backup@example.com"
+# password_env = "SMTP_PASSWORD"                      # 密码所在的环境变量名
+# to = ["ops@example.com"]
+# on_failure_only = true
+
+# 监控指标（可选）：服务模式下在 /metrics 暴露 Prometheus 指标（上次成功时间、耗时、字节数、下次运行时间等）
+# [metrics]
+# listen = "127.0.0.1:9101"
+
+# 控制接口：服务模式下监听 Unix socket，交互式菜单通过它触发备份、查看进度、暂停/恢复定时调度
+# [control]
+# socket = "state/backup-go.sock"
+# listen = "127.0.0.1:9102"                           # 可选的本机 HTTP 接口，只允许回环地址
+
+# 任务锁：同一任务的上一次备份（定时、once 或交互式菜单）尚未结束时的处理方式
+# [lock]
+# on_conflict = "skip"                                # "skip": 跳过本次；"wait": 等待；"fail": 报错
+# wait_timeout_minutes = 60
+# remote = false                                      # 多台主机共用同一存储前缀时启用，在前缀下写入 backup.lock
+# remote_stale_minutes = 10                           # 远程锁超过该时长未刷新视为残留
+
+# 限速（可选）：速率为每秒字节数，如 "10MB"、"512KiB"，为空或 "0" 表示不限速
+# [bandwidth]
+# upload   = ""                                       # 默认上传限速
+# download = ""                                       # 默认下载限速（恢复）
+# [[bandwidth.window]]                                # 按时段覆盖默认限速（本机时区，可跨零点，如 22:00-06:00）
+# start  = "08:00"
+# end    = "20:00"
+# upload = "10MB"                                     # 工作时间上传限速 10MB/s，其余时间不限速
+
+# 多任务配置（可选）：配置 [[jobs]] 后忽略上面的 [backup]，每个任务拥有独立的源目录、存储前缀、保留天数和定时
+# [[jobs]]
+# name      = "www"                                   # 任务名称（唯一）
+# sources   = ["/var/www", "/etc/nginx"]
+# prefix    = "backup/www/"                           # 为空时使用 cos.prefix + 任务名称
+# keep_days = 14                                      # 0 表示沿用 cos.keep_days
+# keep_daily = 7                                      # 分级保留规则，未配置时沿用 [cos] 中的配置
+# [jobs.schedule]
+# enabled  = true
+# hour     = 3
+# minute   = 0
+# timezone = "Asia/Shanghai"
 `
 
 	file, err := os.OpenFile(configPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
@@ -111,31 +781,67 @@ timezone = "Asia/Shanghai"                            # 时区设置
 
 // CalculateNextRunTime 计算下次运行时间
 func CalculateNextRunTime(schedule ScheduleConfig) time.Time {
-	now := time.Now()
+	return nextRunAfter(schedule, time.Now())
+}
+
+// nextRunAfter 计算 now 之后的下次运行时间（在配置的时区中求值）
+func nextRunAfter(schedule ScheduleConfig, now time.Time) time.Time {
+	cron, loc := schedule.compile()
+	return cron.next(now, loc)
+}
+
+// maxMissedScan 在一个时间窗口内最多向后推算的次数，防止异常配置导致推算不结束
+const maxMissedScan = 100000
+
+// MissedRun 返回 (last, now] 之间最近的一个定时运行时间，用于检查服务停止或系统休眠期间错过的定时备份
+// 从 now 往前按逐步加倍的时间窗口查找，高频定时在长时间停机后也只需推算最近的一段时间
+func MissedRun(schedule ScheduleConfig, last, now time.Time) (time.Time, bool) {
+	cron, loc := schedule.compile()
+	span := now.Sub(last)
+	for window := 24 * time.Hour; window > 0 && window < span; window *= 2 {
+		if missed, ok := lastRunBetween(cron, loc, now.Add(-window), now); ok {
+			return missed, true
+		}
+	}
+	return lastRunBetween(cron, loc, last, now)
+}
+
+// lastRunBetween 返回 (from, now] 之间最后一个定时运行时间
+func lastRunBetween(cron *cronSchedule, loc *time.Location, from, now time.Time) (time.Time, bool) {
+	var missed time.Time
+	t := from
+	for i := 0; i < maxMissedScan; i++ {
+		t = cron.next(t, loc)
+		if t.IsZero() || t.After(now) {
+			break
+		}
+		missed = t
+	}
+	return missed, !missed.IsZero()
+}
+
+// compile 解析定时配置与时区，配置无效时回退为每天 hour:minute 执行
+func (s ScheduleConfig) compile() (*cronSchedule, *time.Location) {
 	var loc *time.Location
 	var err error
 
-	if schedule.Timezone != "" {
-		loc, err = time.LoadLocation(schedule.Timezone)
+	if s.Timezone != "" {
+		loc, err = time.LoadLocation(s.Timezone)
 		if err != nil {
-			logger.PrintLog("warn", fmt.Sprintf("加载时区失败 '%s'，使用系统默认时区: %v", schedule.Timezone, err))
+			logger.PrintLog("warn", fmt.Sprintf("加载时区失败 '%s'，使用系统默认时区: %v", s.Timezone, err))
 			loc = time.Local
 		}
 	} else {
 		loc = time.Local
 	}
 
-	// 转换当前时间到目标时区
-	nowInLoc := now.In(loc)
-	
-	// 构建今天的目标时间
-	nextRun := time.Date(nowInLoc.Year(), nowInLoc.Month(), nowInLoc.Day(), 
-		schedule.Hour, schedule.Minute, 0, 0, loc)
-
-	// 如果今天的时间已经过了，推到明天
-	if nextRun.Before(nowInLoc) {
-		nextRun = nextRun.Add(24 * time.Hour)
+	cron, err := parseCron(s.cronSpec())
+	if err != nil {
+		logger.PrintLog("warn", fmt.Sprintf("定时配置无效，改为每天 %02d:%02d 执行: %v", s.Hour, s.Minute, err))
+		cron, _ = parseCron(ScheduleConfig{Hour: s.Hour, Minute: s.Minute}.cronSpec())
+		if cron == nil {
+			cron, _ = parseCron(cronAliases["@daily"])
+		}
 	}
-
-	return nextRun
+	return cron, loc
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -97,3 +98,163 @@ func TestCalculateNextRunTime(t *testing.T) {
 		t.Errorf("Expected run time to be tomorrow (Day %d), got Day %d", expectedTomorrow.Day(), nextRun2.Day())
 	}
 }
+
+func TestLoadConfigJobs(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfgPath := filepath.Join(tmpDir, "jobs.toml")
+	content := `
+[cos]
+bucket    = "bucket-123"
+prefix    = "backup/"
+keep_days = 30
+keep_daily   = 7
+keep_monthly = 12
+
+[[jobs]]
+name    = "www"
+sources = ["/var/www", "/etc/nginx"]
+exclude = ["*.log"]
+[jobs.schedule]
+enabled = true
+hour    = 3
+
+[[jobs]]
+name      = "db"
+data_dir  = "/var/dump"
+prefix    = "db-backup/"
+keep_days = 7
+keep_last = 3
+[[jobs.databases]]
+type     = "postgres"
+database = "app"
+[[jobs.databases]]
+type     = "sqlite"
+database = "/var/lib/app/state.db"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	jobs := cfg.JobList()
+	if len(jobs) != 2 {
+		t.Fatalf("Expected 2 jobs, got %d", len(jobs))
+	}
+	www, db := jobs[0], jobs[1]
+	if www.Prefix != "backup/www/" || www.KeepDays != 30 {
+		t.Errorf("Unexpected www defaults: prefix %q keep_days %d", www.Prefix, www.KeepDays)
+	}
+	if len(www.Sources) != 2 || len(www.Exclude) != 1 || !www.Schedule.Enabled || www.Schedule.Hour != 3 {
+		t.Errorf("Unexpected www job: %+v", www)
+	}
+	if db.Prefix != "db-backup/" || db.KeepDays != 7 || db.DataDir != "/var/dump" {
+		t.Errorf("Unexpected db job: %+v", db)
+	}
+	if len(db.Databases) != 2 || db.Databases[0].FileName() != "app.sql" || db.Databases[1].FileName() != "state.db" {
+		t.Errorf("Unexpected db databases: %+v", db.Databases)
+	}
+	// 未配置分级保留的任务沿用 [cos]，配置了的任务完全使用自己的规则
+	if www.RetentionConfig != (RetentionConfig{KeepDaily: 7, KeepMonthly: 12}) {
+		t.Errorf("Unexpected www retention: %+v", www.RetentionConfig)
+	}
+	if db.RetentionConfig != (RetentionConfig{KeepLast: 3}) {
+		t.Errorf("Unexpected db retention: %+v", db.RetentionConfig)
+	}
+
+	next, due := NextJobRun(jobs)
+	if next.IsZero() || len(due) != 1 || due[0].Name != "www" {
+		t.Errorf("Expected only www to be scheduled, got %v %v", next, due)
+	}
+}
+
+func TestLoadConfigInvalidJobs(t *testing.T) {
+	cases := map[string]string{
+		"duplicate name": `
+[[jobs]]
+name = "a"
+[[jobs]]
+name = "a"
+`,
+		"path traversal name": `
+[[jobs]]
+name = "../.."
+`,
+		"name with separator": `
+[[jobs]]
+name = "www/static"
+`,
+		"name with colon": `
+[[jobs]]
+name = "c:www"
+`,
+		"nested prefix": `
+[[jobs]]
+name   = "a"
+prefix = "backup/"
+[[jobs]]
+name   = "b"
+prefix = "backup/b/"
+`,
+		"duplicate dump name": `
+[[jobs]]
+name = "a"
+[[jobs.databases]]
+type     = "mysql"
+database = "app"
+[[jobs.databases]]
+type     = "postgres"
+database = "app"
+`,
+		"unknown database type": `
+[backup]
+[[backup.databases]]
+type     = "oracle"
+database = "app"
+`,
+		"public control listen": `
+[control]
+listen = "0.0.0.0:9102"
+`,
+		"part size below the S3 minimum": `
+[cos]
+part_size_mb = 1
+`,
+		"invalid bandwidth rate": `
+[bandwidth]
+upload = "fast"
+`,
+		"invalid bandwidth window": `
+[[bandwidth.window]]
+start  = "8am"
+end    = "20:00"
+upload = "10MB"
+`,
+	}
+	for name, content := range cases {
+		cfgPath := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(cfgPath, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(cfgPath); err == nil {
+			t.Errorf("%s: expected LoadConfig to fail", name)
+		}
+	}
+}
+
+func TestJobListDefault(t *testing.T) {
+	cfg := &Config{
+		Cos:    CosConfig{Prefix: "backup/", KeepDays: 10},
+		Backup: BackupConfig{DataDir: "/data"},
+	}
+	jobs := cfg.JobList()
+	if len(jobs) != 1 {
+		t.Fatalf("Expected 1 job, got %d", len(jobs))
+	}
+	if jobs[0].Name != DefaultJobName || jobs[0].Prefix != "backup/" || jobs[0].KeepDays != 10 || jobs[0].DataDir != "/data" {
+		t.Errorf("Unexpected default job: %+v", jobs[0])
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"@every 10s",
+		"@every soon",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) expected error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("时区数据不可用: %v", err)
+	}
+	// 2024-06-05 是星期三
+	now := time.Date(2024, 6, 5, 10, 17, 30, 0, loc)
+
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"@hourly", time.Date(2024, 6, 5, 11, 0, 0, 0, loc)},
+		{"@daily", time.Date(2024, 6, 6, 0, 0, 0, 0, loc)},
+		{"30 2 * * *", time.Date(2024, 6, 6, 2, 30, 0, 0, loc)},
+		{"*/15 * * * *", time.Date(2024, 6, 5, 10, 30, 0, 0, loc)},
+		{"0 3 * * SUN", time.Date(2024, 6, 9, 3, 0, 0, 0, loc)},
+		{"0 3 * * 7", time.Date(2024, 6, 9, 3, 0, 0, 0, loc)},
+		{"0 9-17/4 * * 1-5", time.Date(2024, 6, 5, 13, 0, 0, 0, loc)},
+		{"0 0 1 JAN *", time.Date(2025, 1, 1, 0, 0, 0, 0, loc)},
+		{"0 0 1 * 1", time.Date(2024, 6, 10, 0, 0, 0, 0, loc)},   // 日与星期满足其一
+		{"0 0 */2 * 1", time.Date(2024, 6, 10, 0, 0, 0, 0, loc)}, // 日字段以 * 开头时只按星期匹配
+		{"0 0 1 * */3", time.Date(2024, 7, 1, 0, 0, 0, 0, loc)},  // 星期字段以 * 开头时只按日匹配
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, loc)},
+		{"@every 6h", time.Date(2024, 6, 5, 12, 0, 0, 0, loc)},
+	}
+	for _, c := range cases {
+		cron, err := parseCron(c.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q) failed: %v", c.expr, err)
+			continue
+		}
+		if got := cron.next(now, loc); !got.Equal(c.want) {
+			t.Errorf("next(%q) = %v, want %v", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestCronNextAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("时区数据不可用: %v", err)
+	}
+
+	cron, err := parseCron("30 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2024-03-10 02:00 时钟拨快到 03:00，02:30 不存在，顺延到跳变之后执行
+	now := time.Date(2024, 3, 10, 0, 0, 0, 0, loc)
+	got := cron.next(now, loc)
+	if got.Month() != 3 || got.Day() != 10 || got.Hour() != 3 {
+		t.Errorf("Expected run on 2024-03-10 after the gap, got %v", got)
+	}
+	// 跳变后下一次应回到正常的 02:30
+	if next := cron.next(got, loc); !next.Equal(time.Date(2024, 3, 11, 2, 30, 0, 0, loc)) {
+		t.Errorf("Expected 2024-03-11 02:30, got %v", next)
+	}
+
+	// 2024-11-03 02:00 时钟回拨到 01:00，01:30 出现两次但只执行一次
+	cron, err = parseCron("30 1 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = time.Date(2024, 11, 3, 0, 0, 0, 0, loc)
+	first := cron.next(now, loc)
+	if first.Day() != 3 || first.Hour() != 1 || first.Minute() != 30 {
+		t.Fatalf("Expected 2024-11-03 01:30, got %v", first)
+	}
+	if second := cron.next(first, loc); second.Day() != 4 {
+		t.Errorf("Expected next run on 2024-11-04, got %v", second)
+	}
+}
+
+func TestCalculateNextRunTimeWithCron(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skipf("时区数据不可用: %v", err)
+	}
+	now := time.Date(2024, 6, 5, 23, 59, 0, 0, loc)
+
+	// hour/minute 仍然有效
+	got := nextRunAfter(ScheduleConfig{Hour: 2, Timezone: "Asia/Shanghai"}, now)
+	if want := time.Date(2024, 6, 6, 2, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	// cron 优先于 hour/minute
+	got = nextRunAfter(ScheduleConfig{Cron: "@hourly", Hour: 2, Timezone: "Asia/Shanghai"}, now)
+	if want := time.Date(2024, 6, 6, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestMissedRun(t *testing.T) {
+	s := ScheduleConfig{Hour: 2, Minute: 0, Timezone: "UTC"}
+	last := time.Date(2024, 6, 3, 2, 0, 0, 0, time.UTC)
+
+	// 停机两天多，返回最近错过的一次
+	missed, ok := MissedRun(s, last, time.Date(2024, 6, 5, 9, 0, 0, 0, time.UTC))
+	if !ok || !missed.Equal(time.Date(2024, 6, 5, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected missed run at 2024-06-05 02:00, got %v (%v)", missed, ok)
+	}
+	if _, ok := MissedRun(s, last, time.Date(2024, 6, 4, 1, 59, 0, 0, time.UTC)); ok {
+		t.Error("Expected no missed run before the next slot")
+	}
+
+	missed, ok = MissedRun(ScheduleConfig{Cron: "@every 6h", Timezone: "UTC"}, last, time.Date(2024, 6, 3, 13, 0, 0, 0, time.UTC))
+	if !ok || !missed.Equal(time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected missed run at 12:00, got %v (%v)", missed, ok)
+	}
+
+	// 每分钟执行的定时停机半年，返回的仍是最近错过的一次
+	now := time.Date(2024, 12, 3, 8, 30, 20, 0, time.UTC)
+	missed, ok = MissedRun(ScheduleConfig{Cron: "* * * * *", Timezone: "UTC"}, last, now)
+	if !ok || !missed.Equal(time.Date(2024, 12, 3, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected missed run at 08:30, got %v (%v)", missed, ok)
+	}
+
+	// 每年执行一次的定时需要扩大查找窗口
+	missed, ok = MissedRun(ScheduleConfig{Cron: "0 0 1 1 *", Timezone: "UTC"}, time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), now)
+	if !ok || !missed.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected missed run at 2024-01-01, got %v (%v)", missed, ok)
+	}
+}
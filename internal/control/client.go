@@ -0,0 +1,100 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/history"
+)
+
+// Client 控制接口客户端，通过 Unix socket 连接后台服务
+type Client struct {
+	http *http.Client
+}
+
+// NewClient 创建连接 cfg 中 Unix socket 的客户端
+func NewClient(cfg config.ControlConfig) *Client {
+	socket := cfg.SocketPath()
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Client{http: &http.Client{Transport: transport, Timeout: 10 * time.Second}}
+}
+
+// Status 查询后台服务状态，服务未运行时返回错误
+func (c *Client) Status() (Status, error) {
+	var st Status
+	err := c.do(http.MethodGet, "/status", nil, &st)
+	return st, err
+}
+
+// Run 触发后台服务立即执行备份，job 为空时执行全部任务
+func (c *Client) Run(job string) (RunResult, error) {
+	var res RunResult
+	err := c.do(http.MethodPost, "/run", url.Values{"job": {job}}, &res)
+	return res, err
+}
+
+// History 读取运行历史，job 为空时返回全部任务，limit 为 0 时不限制条数
+func (c *Client) History(job string, limit int) ([]history.Record, error) {
+	var records []history.Record
+	err := c.do(http.MethodGet, "/history", url.Values{"job": {job}, "limit": {strconv.Itoa(limit)}}, &records)
+	return records, err
+}
+
+// Reload 让后台服务重新加载配置文件
+func (c *Client) Reload() (Status, error) {
+	var st Status
+	err := c.do(http.MethodPost, "/reload", nil, &st)
+	return st, err
+}
+
+// SetPaused 暂停或恢复后台服务的定时调度
+func (c *Client) SetPaused(paused bool) (Status, error) {
+	path := "/resume"
+	if paused {
+		path = "/pause"
+	}
+	var st Status
+	err := c.do(http.MethodPost, path, nil, &st)
+	return st, err
+}
+
+func (c *Client) do(method, path string, query url.Values, out any) error {
+	u := "http://backup-go" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("连接后台服务失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e errorResponse
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+		return fmt.Errorf("后台服务返回错误: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析后台服务响应失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// Package control 实现后台服务的本地控制接口（Unix socket 与可选的本机 HTTP），
+// 交互式菜单通过它触发备份、查询进度与运行历史、重载配置以及暂停/恢复定时调度
+package control
+
+import (
+	"time"
+
+	"backup-go/internal/task"
+)
+
+// Daemon 后台服务需要提供给控制接口的操作
+type Daemon interface {
+	Status() Status
+	// Run 立即执行指定任务，job 为空时执行全部任务；正在执行的任务不会重复启动
+	Run(job string) (RunResult, error)
+	// Reload 重新加载配置文件
+	Reload() error
+	// SetPaused 暂停或恢复定时调度，不影响通过 Run 手动触发的备份
+	SetPaused(paused bool)
+}
+
+// Status 后台服务状态
+type Status struct {
+	PID    int         `json:"pid"`
+	Paused bool        `json:"paused"`
+	Jobs   []JobStatus `json:"jobs"`
+}
+
+// JobStatus 单个任务的状态
+type JobStatus struct {
+	Name         string         `json:"name"`
+	NextRun      time.Time      `json:"next_run,omitzero"`       // 未启用定时时为零值
+	Running      bool           `json:"running"`                 // 已启动且尚未结束，包括等待重试
+	RetryPending bool           `json:"retry_pending,omitempty"` // 失败后等待重试，此时手动触发会取消等待并立即执行
+	Progress     *task.Progress `json:"progress,omitempty"`      // 执行阶段，刚启动时可能为 nil
+}
+
+// RunResult 触发备份的结果
+type RunResult struct {
+	Started []string `json:"started"`
+	Skipped []string `json:"skipped"` // 上一次执行尚未结束而跳过的任务
+}
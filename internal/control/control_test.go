@@ -0,0 +1,127 @@
+package control
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/history"
+	"backup-go/internal/task"
+)
+
+// fakeDaemon 记录控制接口调用的测试实现
+type fakeDaemon struct {
+	paused  bool
+	reloads int
+}
+
+func (d *fakeDaemon) Status() Status {
+	return Status{PID: 42, Paused: d.paused, Jobs: []JobStatus{
+		{Name: "www", Running: true, Progress: &task.Progress{Job: "www", Phase: task.PhaseUpload}},
+		{Name: "db"},
+	}}
+}
+
+func (d *fakeDaemon) Run(job string) (RunResult, error) {
+	switch job {
+	case "":
+		return RunResult{Started: []string{"db"}, Skipped: []string{"www"}}, nil
+	case "db":
+		return RunResult{Started: []string{"db"}}, nil
+	}
+	return RunResult{}, errors.New("未找到备份任务: " + job)
+}
+
+func (d *fakeDaemon) Reload() error {
+	d.reloads++
+	return nil
+}
+
+func (d *fakeDaemon) SetPaused(paused bool) { d.paused = paused }
+
+func startTestServer(t *testing.T, d Daemon) config.ControlConfig {
+	t.Helper()
+	cfg := config.ControlConfig{Socket: filepath.Join(t.TempDir(), "ctl.sock")}
+	stop, err := Serve(cfg, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stop)
+	return cfg
+}
+
+func TestClientServer(t *testing.T) {
+	oldPath := history.Path
+	history.Path = filepath.Join(t.TempDir(), "history.jsonl")
+	t.Cleanup(func() { history.Path = oldPath })
+	for _, job := range []string{"www", "db"} {
+		history.Append(history.Record{Job: job, Status: task.StatusSuccess, StartTime: time.Now()})
+	}
+
+	d := &fakeDaemon{}
+	c := NewClient(startTestServer(t, d))
+
+	st, err := c.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.PID != 42 || len(st.Jobs) != 2 || st.Jobs[0].Progress == nil || st.Jobs[0].Progress.Phase != task.PhaseUpload {
+		t.Errorf("Unexpected status: %+v", st)
+	}
+	if !st.Jobs[1].NextRun.IsZero() || st.Jobs[1].Progress != nil {
+		t.Errorf("Expected idle job without schedule, got %+v", st.Jobs[1])
+	}
+
+	res, err := c.Run("")
+	if err != nil || len(res.Started) != 1 || len(res.Skipped) != 1 {
+		t.Errorf("Unexpected run result: %+v, %v", res, err)
+	}
+	if _, err := c.Run("missing"); err == nil || err.Error() != "未找到备份任务: missing" {
+		t.Errorf("Expected server error to be returned, got %v", err)
+	}
+
+	records, err := c.History("db", 0)
+	if err != nil || len(records) != 1 || records[0].Job != "db" {
+		t.Errorf("Unexpected history: %+v, %v", records, err)
+	}
+
+	if st, err := c.SetPaused(true); err != nil || !st.Paused {
+		t.Errorf("Expected scheduling to be paused: %+v, %v", st, err)
+	}
+	if st, err := c.SetPaused(false); err != nil || st.Paused {
+		t.Errorf("Expected scheduling to be resumed: %+v, %v", st, err)
+	}
+	if _, err := c.Reload(); err != nil || d.reloads != 1 {
+		t.Errorf("Expected one reload, got %d (%v)", d.reloads, err)
+	}
+}
+
+func TestServeSocket(t *testing.T) {
+	cfg := startTestServer(t, &fakeDaemon{})
+	if info, err := os.Stat(cfg.Socket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket with mode 0600, got %v (%v)", info, err)
+	}
+	// 同一 socket 上不能再启动第二个服务
+	if _, err := Serve(cfg, &fakeDaemon{}); err == nil {
+		t.Error("Expected an error for a socket already in use")
+	}
+
+	// 残留的 socket 文件会被替换
+	stale := config.ControlConfig{Socket: filepath.Join(t.TempDir(), "stale.sock")}
+	os.WriteFile(stale.Socket, nil, 0600)
+	stop, err := Serve(stale, &fakeDaemon{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if _, err := NewClient(stale).Status(); err != nil {
+		t.Errorf("Expected server on replaced socket: %v", err)
+	}
+
+	if _, err := NewClient(config.ControlConfig{Socket: filepath.Join(t.TempDir(), "none.sock")}).Status(); err == nil {
+		t.Error("Expected an error when no server is running")
+	}
+}
@@ -0,0 +1,137 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/history"
+	"backup-go/internal/logger"
+)
+
+// Serve 启动控制接口，返回用于关闭监听的函数
+// Unix socket 已被其他正在运行的服务占用时返回错误；残留的 socket 文件会被删除
+func Serve(cfg config.ControlConfig, d Daemon) (func(), error) {
+	srv := &http.Server{Handler: newHandler(d), ReadHeaderTimeout: 10 * time.Second}
+
+	socket := cfg.SocketPath()
+	ln, err := listenUnix(socket)
+	if err != nil {
+		return nil, err
+	}
+	listeners := []net.Listener{ln}
+	logger.PrintLog("daemon", "控制接口已启用: "+socket)
+
+	if cfg.Listen != "" {
+		tcp, err := net.Listen("tcp", cfg.Listen)
+		if err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("启动控制接口 HTTP 监听失败: %w", err)
+		}
+		listeners = append(listeners, tcp)
+		logger.PrintLog("daemon", fmt.Sprintf("控制接口 HTTP 已启用: http://%s", tcp.Addr()))
+	}
+
+	for _, l := range listeners {
+		go func() {
+			if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.PrintLog("error", fmt.Sprintf("控制接口异常退出: %v", err))
+			}
+		}()
+	}
+	return func() { srv.Close() }, nil
+}
+
+// listenUnix 监听 Unix socket，只允许当前用户访问
+func listenUnix(socket string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+		return nil, fmt.Errorf("创建控制接口目录失败: %w", err)
+	}
+	if _, err := os.Stat(socket); err == nil {
+		if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("控制接口 %s 已被占用，是否已有备份服务在运行？", socket)
+		}
+		// 上次服务异常退出残留的 socket 文件
+		os.Remove(socket)
+	}
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("启动控制接口失败: %w", err)
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("设置控制接口权限失败: %w", err)
+	}
+	return ln, nil
+}
+
+func newHandler(d Daemon) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	mux.HandleFunc("POST /run", func(w http.ResponseWriter, r *http.Request) {
+		res, err := d.Run(r.URL.Query().Get("job"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, res)
+	})
+	mux.HandleFunc("GET /history", func(w http.ResponseWriter, r *http.Request) {
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("无效的 limit: %q", v))
+				return
+			}
+			limit = n
+		}
+		records, err := history.Load(r.URL.Query().Get("job"), limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, records)
+	})
+	mux.HandleFunc("POST /reload", func(w http.ResponseWriter, r *http.Request) {
+		if err := d.Reload(); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, r *http.Request) {
+		d.SetPaused(true)
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, r *http.Request) {
+		d.SetPaused(false)
+		writeJSON(w, http.StatusOK, d.Status())
+	})
+	return mux
+}
+
+// errorResponse 请求失败时返回的内容
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
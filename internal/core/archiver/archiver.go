@@ -2,7 +2,10 @@ package archiver
 
 import (
 	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"os"
@@ -14,16 +17,21 @@ import (
 	"backup-go/internal/logger"
 )
 
-// CalculateDirSize 计算目录总大小（仅统计常规文件）
-func CalculateDirSize(dir string) (int64, error) {
+// CalculateDirSize 计算目录总大小（仅统计常规文件，遵循 include/exclude 规则）
+func CalculateDirSize(dir string, opts Options) (int64, error) {
 	var size int64
 	visited := make(map[string]bool)
+	filter := NewFilter(opts)
 
 	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
+		if skip, err := filter.visit(dir, p, d.IsDir()); skip {
+			return err
+		}
+
 		// 检查符号链接循环
 		if d.Type()&os.ModeSymlink != 0 {
 			link, err := os.Readlink(p)
@@ -62,17 +70,55 @@ func CalculateDirSize(dir string) (int64, error) {
 	return size, err
 }
 
-// addTarEntry 写入一个条目到 tar
-func addTarEntry(tw *tar.Writer, root, path string, d fs.DirEntry) error {
-	rel, err := filepath.Rel(root, path)
+// CalculateSourcesSize 计算多个源目录的总大小
+func CalculateSourcesSize(sources []Source, opts Options) (int64, error) {
+	var total int64
+	for _, src := range sources {
+		size, err := CalculateDirSize(src.Path, opts)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", src.Path, err)
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// EntryWriter 接收遍历源目录得到的条目，*tar.Writer 即为一种实现
+// 每个条目先调用 WriteHeader，普通文件随后通过 Write 写入内容
+type EntryWriter interface {
+	WriteHeader(h *tar.Header) error
+	Write(b []byte) (int, error)
+}
+
+// openFile 打开要打包的文件，测试中替换以模拟无法读取的文件
+var openFile = os.Open
+
+// entryName 返回源目录中的路径在 tar 包中的条目名（目录不含结尾的 /），无前缀的源目录根目录返回空字符串
+func entryName(src Source, path string) (string, error) {
+	rel, err := filepath.Rel(src.Path, path)
 	if err != nil {
-		return fmt.Errorf("计算相对路径失败: %w", err)
+		return "", fmt.Errorf("计算相对路径失败: %w", err)
 	}
-	// 跳过根目录自身的条目 "."
 	if rel == "." {
-		return nil
+		return src.Prefix, nil
 	}
 	name := filepath.ToSlash(rel)
+	if src.Prefix != "" {
+		name = src.Prefix + "/" + name
+	}
+	return name, nil
+}
+
+// addTarEntry 写入一个条目到 tar，m 非 nil 时同时记录到清单
+func addTarEntry(tw EntryWriter, src Source, path string, d fs.DirEntry, m *Manifest) error {
+	name, err := entryName(src, path)
+	if err != nil {
+		return err
+	}
+	// 无前缀时跳过根目录自身的条目 "."，有前缀时根目录即顶层目录
+	if name == "" {
+		return nil
+	}
 
 	info, err := d.Info()
 	if err != nil {
@@ -115,6 +161,7 @@ func addTarEntry(tw *tar.Writer, root, path string, d fs.DirEntry) error {
 		if err := tw.WriteHeader(h); err != nil {
 			return fmt.Errorf("写入符号链接 tar 头失败: %w", err)
 		}
+		m.add(h, info, "")
 		return nil
 	}
 
@@ -128,31 +175,51 @@ func addTarEntry(tw *tar.Writer, root, path string, d fs.DirEntry) error {
 			name += "/"
 		}
 		h.Name = name
-		return tw.WriteHeader(h)
+		if err := tw.WriteHeader(h); err != nil {
+			return err
+		}
+		m.add(h, info, "")
+		return nil
 	}
 
-	// 常规文件
+	// 常规文件，增量备份时跳过未变化的文件
 	if info.Mode().IsRegular() {
+		if prev, ok := m.unchanged(name, info); ok {
+			m.Files = append(m.Files, prev)
+			return nil
+		}
+		// 先打开文件再写入 tar 头，无法读取的文件不会在 tar 中留下没有内容的条目
+		f, err := openFile(path)
+		if err != nil {
+			return fmt.Errorf("打开文件失败: %w", err)
+		}
 		h := &tar.Header{
-			Name:    name,
-			Size:    info.Size(),
-			Mode:    int64(info.Mode().Perm()),
-			ModTime: info.ModTime(),
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     info.Size(),
+			Mode:     int64(info.Mode().Perm()),
+			ModTime:  info.ModTime(),
 		}
 		if err := tw.WriteHeader(h); err != nil {
+			f.Close()
 			return fmt.Errorf("写入 tar header 失败: %w", err)
 		}
-		f, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("打开文件失败: %w", err)
+		var dst io.Writer = tw
+		var sum hash.Hash
+		if m != nil {
+			sum = sha256.New()
+			dst = io.MultiWriter(tw, sum)
 		}
-		if _, err := io.Copy(tw, f); err != nil {
+		if _, err := io.Copy(dst, f); err != nil {
 			f.Close()
 			return fmt.Errorf("拷贝文件内容失败: %w", err)
 		}
 		if closeErr := f.Close(); closeErr != nil {
 			logger.PrintLog("warn", fmt.Sprintf("关闭文件失败: %s: %v", path, closeErr))
 		}
+		if sum != nil {
+			m.add(h, info, hex.EncodeToString(sum.Sum(nil)))
+		}
 		return nil
 	}
 
@@ -162,57 +229,88 @@ func addTarEntry(tw *tar.Writer, root, path string, d fs.DirEntry) error {
 		return fmt.Errorf("创建 tar header 失败: %w", err)
 	}
 	h.Name = name
-	return tw.WriteHeader(h)
-}
-
-// Compress 压缩 data 目录为 zstd 压缩的 tar 包
-func Compress(srcDir, dstFile string) (int64, int64, error) {
-	logger.PrintLog("backup", "开始计算源目录大小: "+srcDir)
-	originalSize, err := CalculateDirSize(srcDir)
-	if err != nil {
-		return 0, 0, fmt.Errorf("计算源目录大小失败: %w", err)
+	if err := tw.WriteHeader(h); err != nil {
+		return err
 	}
-	if originalSize == 0 {
-		return 0, 0, fmt.Errorf("源目录 %s 为空，跳过备份", srcDir)
-	}
-	logger.PrintLog("backup", fmt.Sprintf("源目录大小: %s (%d bytes)", humanize.Bytes(uint64(originalSize)), originalSize))
+	m.add(h, info, "")
+	return nil
+}
 
-	dst, err := os.Create(dstFile)
-	if err != nil {
-		return 0, 0, fmt.Errorf("创建压缩目标文件失败: %w", err)
+// addParentEntries 补写尚未写入的上级目录条目（仅在 include 规则生效时需要）
+func addParentEntries(tw EntryWriter, src Source, p string, written map[string]bool, m *Manifest) error {
+	var parents []string
+	for dir := filepath.Dir(p); !written[dir]; dir = filepath.Dir(dir) {
+		rel, err := filepath.Rel(src.Path, dir)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			break
+		}
+		parents = append(parents, dir)
+		if rel == "." {
+			break
+		}
 	}
-	
-	defer func() {
-		_ = dst.Close()
+	for i := len(parents) - 1; i >= 0; i-- {
+		info, err := os.Lstat(parents[i])
 		if err != nil {
-			_ = os.Remove(dstFile)
+			return fmt.Errorf("获取目录信息失败: %w", err)
 		}
-	}()
-
-	zs, err := zstd.NewWriter(dst, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
-	if err != nil {
-		return 0, 0, fmt.Errorf("创建 zstd 压缩器失败: %w", err)
+		if err := addTarEntry(tw, src, parents[i], fs.FileInfoToDirEntry(info), m); err != nil {
+			return err
+		}
+		written[parents[i]] = true
 	}
-	tw := tar.NewWriter(zs)
+	return nil
+}
 
-	logger.PrintLog("backup", fmt.Sprintf("开始压缩打包 (zstd) %s → %s", srcDir, dstFile))
+// WalkStats 打包过程中的文件统计
+type WalkStats struct {
+	Processed int64 // 成功写入的条目数
+	Skipped   int64 // 因读取错误、危险路径等原因跳过的条目数
+	Excluded  int64 // 被过滤规则排除的条目数
+	Streamed  int64 // 数据流条目写入的字节数
+}
 
-	var processedFiles, skippedFiles int64
-	visited := make(map[string]bool)
+// addSource 遍历一个源目录并写入 tar
+func addSource(tw EntryWriter, src Source, opts Options, visited map[string]bool, stats *WalkStats) error {
+	filter := NewFilter(opts)
+	writtenDirs := make(map[string]bool)
 
-	if err := filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+	return filepath.WalkDir(src.Path, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			logger.PrintLog("warn", fmt.Sprintf("跳过文件访问错误: %s (错误: %v)", p, err))
-			skippedFiles++
+			stats.Skipped++
+			keepSkipped(src, p, opts.Manifest)
 			return nil
 		}
 
+		if skip, err := filter.visit(src.Path, p, d.IsDir()); skip {
+			stats.Excluded++
+			return err
+		}
+		if filter.HasIncludes() {
+			// 未被 include 选中的目录延迟到其下有文件入选时再写入
+			if d.IsDir() {
+				rel, _ := filepath.Rel(src.Path, p)
+				if rel == "." || !filter.Included(filepath.ToSlash(rel), true) {
+					return nil
+				}
+			}
+			if err := addParentEntries(tw, src, p, writtenDirs, opts.Manifest); err != nil {
+				logger.PrintLog("warn", fmt.Sprintf("跳过文件处理错误: %s (错误: %v)", p, err))
+				stats.Skipped++
+				return nil
+			}
+			if d.IsDir() {
+				writtenDirs[p] = true
+			}
+		}
+
 		// 检查符号链接循环
 		if d.Type()&os.ModeSymlink != 0 {
 			link, err := os.Readlink(p)
 			if err != nil {
 				logger.PrintLog("warn", fmt.Sprintf("跳过无法读取的符号链接: %s (错误: %v)", p, err))
-				skippedFiles++
+				stats.Skipped++
 				return nil
 			}
 
@@ -220,13 +318,13 @@ func Compress(srcDir, dstFile string) (int64, int64, error) {
 			absTarget, err := filepath.Abs(targetPath)
 			if err != nil {
 				logger.PrintLog("warn", fmt.Sprintf("跳过路径解析失败的符号链接: %s → %s", p, link))
-				skippedFiles++
+				stats.Skipped++
 				return nil
 			}
 
 			if visited[absTarget] {
 				logger.PrintLog("warn", fmt.Sprintf("检测到循环符号链接，跳过: %s → %s", p, link))
-				skippedFiles++
+				stats.Skipped++
 				return nil
 			}
 		}
@@ -234,29 +332,141 @@ func Compress(srcDir, dstFile string) (int64, int64, error) {
 		absPath, err := filepath.Abs(p)
 		if err != nil {
 			logger.PrintLog("warn", fmt.Sprintf("跳过路径解析失败的文件: %s", p))
-			skippedFiles++
+			stats.Skipped++
 			return nil
 		}
 		visited[absPath] = true
 
-		err = addTarEntry(tw, srcDir, p, d)
+		err = addTarEntry(tw, src, p, d, opts.Manifest)
 		if err != nil {
 			logger.PrintLog("warn", fmt.Sprintf("跳过文件处理错误: %s (错误: %v)", p, err))
-			skippedFiles++
+			stats.Skipped++
+			keepSkipped(src, p, opts.Manifest)
 			return nil
 		}
 
-		processedFiles++
+		stats.Processed++
 		return nil
-	}); err != nil {
-		_ = tw.Close()
-		_ = zs.Close()
-		return 0, 0, fmt.Errorf("遍历并打包目录失败: %w", err)
+	})
+}
+
+// keepSkipped 增量备份中因读取错误跳过的条目沿用上一个备份的记录，不会被记为已删除
+func keepSkipped(src Source, p string, m *Manifest) {
+	if name, err := entryName(src, p); err == nil && name != "" {
+		m.keepBase(name)
+	}
+}
+
+// WalkSources 按过滤规则遍历所有源目录，将条目依次写入 w，最后写入 opts 中的数据流条目
+func WalkSources(sources []Source, w EntryWriter, opts Options) error {
+	var stats WalkStats
+	visited := make(map[string]bool)
+
+	for _, src := range sources {
+		if err := addSource(w, src, opts, visited, &stats); err != nil {
+			return fmt.Errorf("遍历并打包目录失败: %w", err)
+		}
+	}
+	streamed, err := addStreams(w, opts.Streams, opts.TempDir, opts.Manifest, &stats)
+	if err != nil {
+		return err
+	}
+	stats.Streamed = streamed
+
+	if opts.Stats != nil {
+		*opts.Stats = stats
+	}
+	logger.PrintLog("backup", fmt.Sprintf("文件处理统计: 成功 %d 个，跳过 %d 个，规则排除 %d 个", stats.Processed, stats.Skipped, stats.Excluded))
+	if stats.Skipped > 0 {
+		logger.PrintLog("warn", fmt.Sprintf("备份过程中跳过了 %d 个有问题的文件，请检查上述警告信息", stats.Skipped))
+	}
+	return nil
+}
+
+// Compress 压缩 data 目录为 zstd 压缩的 tar 包
+func Compress(srcDir, dstFile string, opts Options) (int64, int64, error) {
+	return CompressSources([]Source{{Path: srcDir}}, dstFile, opts)
+}
+
+// CompressSources 将多个源目录压缩为一个 zstd 压缩的 tar 包，每个源目录位于各自的顶层目录下
+func CompressSources(sources []Source, dstFile string, opts Options) (originalSize, compressedSize int64, err error) {
+	dst, err := os.Create(dstFile)
+	if err != nil {
+		return 0, 0, fmt.Errorf("创建压缩目标文件失败: %w", err)
+	}
+	defer func() {
+		_ = dst.Close()
+		if err != nil {
+			_ = os.Remove(dstFile)
+		}
+	}()
+
+	logger.PrintLog("backup", fmt.Sprintf("开始压缩打包 (zstd) %s → %s", sourcesDesc(sources), dstFile))
+	originalSize, compressedSize, err = CompressTo(sources, dst, opts)
+	if err != nil {
+		return 0, 0, err
 	}
+	if err = dst.Sync(); err != nil {
+		return 0, 0, fmt.Errorf("写入压缩文件失败: %w", err)
+	}
+	return originalSize, compressedSize, nil
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// CompressTo 将多个源目录打包压缩后写入 w（不关闭 w），返回原始大小与写入的字节数
+// 源目录为空时在写入任何数据之前返回错误
+func CompressTo(sources []Source, w io.Writer, opts Options) (int64, int64, error) {
+	if len(sources) == 0 && len(opts.Streams) == 0 {
+		return 0, 0, fmt.Errorf("未配置备份源目录")
+	}
+	srcDesc := sourcesDesc(sources)
 
-	logger.PrintLog("backup", fmt.Sprintf("文件处理统计: 成功 %d 个，跳过 %d 个", processedFiles, skippedFiles))
-	if skippedFiles > 0 {
-		logger.PrintLog("warn", fmt.Sprintf("备份过程中跳过了 %d 个有问题的文件，请检查上述警告信息", skippedFiles))
+	logger.PrintLog("backup", "开始计算源目录大小: "+srcDesc)
+	originalSize, err := CalculateSourcesSize(sources, opts)
+	if err != nil {
+		return 0, 0, fmt.Errorf("计算源目录大小失败: %w", err)
+	}
+	if originalSize == 0 && len(opts.Streams) == 0 {
+		return 0, 0, fmt.Errorf("源目录 %s 为空，跳过备份", srcDesc)
+	}
+	logger.PrintLog("backup", fmt.Sprintf("源目录大小: %s (%d bytes)", humanize.Bytes(uint64(originalSize)), originalSize))
+
+	// tar → zstd → [加密] → w
+	counter := &countingWriter{w: w}
+	var out io.Writer = counter
+	var enc io.WriteCloser
+	if opts.Encryptor != nil {
+		enc, err = opts.Encryptor.Encrypt(counter)
+		if err != nil {
+			return 0, 0, err
+		}
+		out = enc
+	}
+
+	zs, err := zstd.NewWriter(out, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	if err != nil {
+		return 0, 0, fmt.Errorf("创建 zstd 压缩器失败: %w", err)
+	}
+	tw := tar.NewWriter(zs)
+
+	if opts.Stats == nil {
+		opts.Stats = new(WalkStats)
+	}
+	if err := WalkSources(sources, tw, opts); err != nil {
+		_ = tw.Close()
+		_ = zs.Close()
+		return 0, 0, err
 	}
 
 	if err := tw.Close(); err != nil {
@@ -266,12 +476,27 @@ func Compress(srcDir, dstFile string) (int64, int64, error) {
 	if err := zs.Close(); err != nil {
 		return 0, 0, fmt.Errorf("关闭 zstd 压缩器失败: %w", err)
 	}
-
-	info, err := os.Stat(dstFile)
-	if err != nil {
-		return 0, 0, fmt.Errorf("获取压缩文件大小失败: %w", err)
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return 0, 0, fmt.Errorf("完成加密失败: %w", err)
+		}
+	}
+	compressedSize := counter.n
+	originalSize += opts.Stats.Streamed
+	if m := opts.Manifest; m != nil {
+		m.OriginalSize, m.CompressedSize = originalSize, compressedSize
+		m.Encrypted = opts.Encryptor != nil
+		m.finish()
+		if m.IsIncremental() {
+			var unchanged int64
+			for _, f := range m.Files {
+				if f.Unchanged {
+					unchanged++
+				}
+			}
+			logger.PrintLog("backup", fmt.Sprintf("增量备份（基于 %s）: 未变化 %d 个文件，删除 %d 个条目", m.Base, unchanged, len(m.Deleted)))
+		}
 	}
-	compressedSize := info.Size()
 
 	logger.PrintLog("backup", "压缩完成")
 	logger.PrintLog("backup", "原始大小: "+humanize.Bytes(uint64(originalSize)))
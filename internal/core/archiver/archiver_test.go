@@ -2,12 +2,16 @@ package archiver
 
 import (
 	"archive/tar"
+	"bytes"
 	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/klauspost/compress/zstd"
+	"backup-go/internal/config"
+	"backup-go/internal/core/encryptor"
 )
 
 func TestCalculateDirSize(t *testing.T) {
@@ -51,7 +55,7 @@ func TestCalculateDirSize(t *testing.T) {
 	
 	expectedSize := int64(300)
 	
-	size, err := CalculateDirSize(tmpDir)
+	size, err := CalculateDirSize(tmpDir, Options{})
 	if err != nil {
 		t.Fatalf("CalculateDirSize failed: %v", err)
 	}
@@ -74,7 +78,7 @@ func TestCompress(t *testing.T) {
 	dstFile := filepath.Join(dstDir, "archive.tar.zst")
 
 	// Run Compress
-	origSize, compSize, err := Compress(srcDir, dstFile)
+	origSize, compSize, err := Compress(srcDir, dstFile, Options{})
 	if err != nil {
 		t.Fatalf("Compress failed: %v", err)
 	}
@@ -128,3 +132,180 @@ func TestCompress(t *testing.T) {
 		t.Errorf("Content mismatch. Got %s, want %s", string(content), string(testData))
 	}
 }
+
+func TestExtract(t *testing.T) {
+	srcDir := t.TempDir()
+	subDir := filepath.Join(srcDir, "sub")
+	if err := os.Mkdir(subDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	testData := []byte("Hello Restore")
+	f := filepath.Join(subDir, "test.txt")
+	if err := os.WriteFile(f, testData, 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(f, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/test.txt", filepath.Join(srcDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	dstFile := filepath.Join(t.TempDir(), "archive.tar.zst")
+	if _, _, err := Compress(srcDir, dstFile, Options{}); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	restoreDir := t.TempDir()
+	if err := Extract(dstFile, restoreDir); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	restored := filepath.Join(restoreDir, "sub", "test.txt")
+	content, err := os.ReadFile(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != string(testData) {
+		t.Errorf("Content mismatch. Got %s, want %s", string(content), string(testData))
+	}
+
+	info, err := os.Stat(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640, got %o", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected mtime %v, got %v", mtime, info.ModTime())
+	}
+
+	link, err := os.Readlink(filepath.Join(restoreDir, "link"))
+	if err != nil {
+		t.Fatalf("Symlink not restored: %v", err)
+	}
+	if link != "sub/test.txt" {
+		t.Errorf("Expected link target sub/test.txt, got %s", link)
+	}
+}
+
+func TestExtractRejectsPathTraversal(t *testing.T) {
+	dstFile := filepath.Join(t.TempDir(), "evil.tar.zst")
+	f, err := os.Create(dstFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zs, err := zstd.NewWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(zs)
+	data := []byte("evil")
+	if err := tw.WriteHeader(&tar.Header{Name: "../evil.txt", Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	zs.Close()
+	f.Close()
+
+	parent := t.TempDir()
+	restoreDir := filepath.Join(parent, "restore")
+	if err := Extract(dstFile, restoreDir); err == nil {
+		t.Fatal("Expected Extract to reject path traversal entry")
+	}
+	if _, err := os.Stat(filepath.Join(parent, "evil.txt")); !os.IsNotExist(err) {
+		t.Error("Entry escaped the target directory")
+	}
+}
+
+func TestCompressEncrypted(t *testing.T) {
+	srcDir := t.TempDir()
+	testData := []byte("Hello Encrypted Backup")
+	if err := os.WriteFile(filepath.Join(srcDir, "secret.txt"), testData, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TEST_BACKUP_PASSPHRASE", "archiver-test")
+	cfg := config.EncryptionConfig{Mode: encryptor.ModePassphrase, PassphraseEnv: "TEST_BACKUP_PASSPHRASE"}
+	enc, err := encryptor.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dstFile := filepath.Join(t.TempDir(), "archive.tar.zst.enc")
+	if _, _, err := Compress(srcDir, dstFile, Options{Encryptor: enc}); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	// 加密后的文件不能被直接解压
+	if err := Extract(dstFile, t.TempDir()); err == nil {
+		t.Error("Expected plain Extract to fail on encrypted archive")
+	}
+
+	f, err := os.Open(dstFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := encryptor.NewReader(f, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreDir := t.TempDir()
+	if err := ExtractReader(r, restoreDir); err != nil {
+		t.Fatalf("ExtractReader failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(restoreDir, "secret.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != string(testData) {
+		t.Errorf("Content mismatch. Got %s, want %s", string(content), string(testData))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	srcDir := t.TempDir()
+	for i, size := range []int{10, 300000, 5} {
+		data := make([]byte, size)
+		for j := range data {
+			data[j] = byte(j * (i + 7))
+		}
+		if err := os.WriteFile(filepath.Join(srcDir, "file"+string(rune('a'+i))), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := filepath.Join(t.TempDir(), "archive.tar.zst")
+	if _, _, err := Compress(srcDir, archive, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := Verify(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatalf("Verify failed on intact archive: %v", err)
+	}
+	if stats.Files != 3 || stats.Bytes != 300015 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// 截断的归档必须报错
+	if _, err := Verify(bytes.NewReader(data[:len(data)/2]), nil); err == nil {
+		t.Error("Expected error for truncated archive")
+	}
+
+	// 篡改中间的数据必须报错
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)/2] ^= 0xff
+	if _, err := Verify(bytes.NewReader(corrupt), nil); err == nil {
+		t.Error("Expected error for corrupted archive")
+	}
+}
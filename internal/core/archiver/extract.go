@@ -0,0 +1,186 @@
+package archiver
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"backup-go/internal/logger"
+)
+
+// Extract 解压 zstd 压缩的 tar 包到目标目录
+func Extract(srcFile, dstDir string) error {
+	f, err := os.Open(srcFile)
+	if err != nil {
+		return fmt.Errorf("打开备份文件失败: %w", err)
+	}
+	defer f.Close()
+
+	logger.PrintLog("restore", fmt.Sprintf("开始解压 %s → %s", srcFile, dstDir))
+	return ExtractReader(f, dstDir)
+}
+
+// ExtractReader 从数据流解压 zstd 压缩的 tar 包到目标目录
+func ExtractReader(r io.Reader, dstDir string) error {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("创建 zstd 解压器失败: %w", err)
+	}
+	defer zr.Close()
+	return ExtractTar(zr, dstDir)
+}
+
+// ExtractTar 从未压缩的 tar 数据流解包到目标目录
+func ExtractTar(r io.Reader, dstDir string) error {
+	absDst, err := filepath.Abs(dstDir)
+	if err != nil {
+		return fmt.Errorf("解析目标目录失败: %w", err)
+	}
+	if err := os.MkdirAll(absDst, 0755); err != nil {
+		return fmt.Errorf("创建目标目录失败: %w", err)
+	}
+	tr := tar.NewReader(r)
+
+	// 目录的修改时间需在其内容全部写入后再设置
+	type dirTime struct {
+		path    string
+		modTime time.Time
+	}
+	var dirs []dirTime
+	var extractedFiles, skippedFiles int64
+
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取 tar 条目失败: %w", err)
+		}
+
+		target, err := safeJoin(absDst, h.Name)
+		if err != nil {
+			return err
+		}
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("创建目录失败: %s: %w", target, err)
+			}
+			if err := os.Chmod(target, os.FileMode(h.Mode).Perm()); err != nil {
+				return fmt.Errorf("设置目录权限失败: %s: %w", target, err)
+			}
+			dirs = append(dirs, dirTime{path: target, modTime: h.ModTime})
+
+		case tar.TypeReg:
+			if err := extractFile(tr, target, h); err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink:
+			// 与打包时的规则一致：只接受不含 '..' 的相对路径链接
+			if strings.Contains(h.Linkname, "..") || filepath.IsAbs(h.Linkname) {
+				logger.PrintLog("warn", fmt.Sprintf("跳过不安全的符号链接: %s → %s", h.Name, h.Linkname))
+				skippedFiles++
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("创建父目录失败: %s: %w", target, err)
+			}
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("替换已存在文件失败: %s: %w", target, err)
+			}
+			if err := os.Symlink(filepath.FromSlash(h.Linkname), target); err != nil {
+				return fmt.Errorf("创建符号链接失败: %s: %w", target, err)
+			}
+
+		default:
+			logger.PrintLog("warn", fmt.Sprintf("跳过不支持的条目类型: %s (类型: %c)", h.Name, h.Typeflag))
+			skippedFiles++
+			continue
+		}
+		extractedFiles++
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime); err != nil {
+			logger.PrintLog("warn", fmt.Sprintf("设置目录时间失败: %s: %v", dirs[i].path, err))
+		}
+	}
+
+	logger.PrintLog("restore", fmt.Sprintf("解压完成: 成功 %d 个，跳过 %d 个", extractedFiles, skippedFiles))
+	return nil
+}
+
+// RemoveEntries 删除目标目录中增量备份记录为已删除的条目，用于按增量链恢复
+func RemoveEntries(dstDir string, names []string) error {
+	absDst, err := filepath.Abs(dstDir)
+	if err != nil {
+		return fmt.Errorf("解析目标目录失败: %w", err)
+	}
+	for _, name := range names {
+		target, err := safeJoin(absDst, strings.TrimSuffix(name, "/"))
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("删除条目失败: %s: %w", target, err)
+		}
+	}
+	if len(names) > 0 {
+		logger.PrintLog("restore", fmt.Sprintf("已删除 %d 个在后续备份中被删除的条目", len(names)))
+	}
+	return nil
+}
+
+// extractFile 写出一个常规文件并恢复权限与修改时间
+func extractFile(r io.Reader, target string, h *tar.Header) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("创建父目录失败: %s: %w", target, err)
+	}
+	// 已存在的符号链接不能被跟随写入
+	if fi, err := os.Lstat(target); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(target); err != nil {
+			return fmt.Errorf("替换已存在的符号链接失败: %s: %w", target, err)
+		}
+	}
+
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %s: %w", target, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("写入文件内容失败: %s: %w", target, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("关闭文件失败: %s: %w", target, err)
+	}
+
+	if err := os.Chmod(target, os.FileMode(h.Mode).Perm()); err != nil {
+		return fmt.Errorf("设置文件权限失败: %s: %w", target, err)
+	}
+	if err := os.Chtimes(target, h.ModTime, h.ModTime); err != nil {
+		return fmt.Errorf("设置文件时间失败: %s: %w", target, err)
+	}
+	return nil
+}
+
+// safeJoin 将 tar 条目名拼接到目标目录，拒绝逃逸出目标目录的路径
+func safeJoin(root, name string) (string, error) {
+	if name == "" || filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("拒绝不安全的条目路径: %q", name)
+	}
+	target := filepath.Join(root, filepath.FromSlash(name))
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("拒绝逃逸出目标目录的条目路径: %q", name)
+	}
+	return target, nil
+}
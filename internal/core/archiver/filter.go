@@ -0,0 +1,230 @@
+package archiver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"backup-go/internal/core/encryptor"
+	"backup-go/internal/logger"
+)
+
+const (
+	// IgnoreFileName 目录级排除规则文件名，规则仅作用于所在目录及其子目录
+	IgnoreFileName = ".backupignore"
+)
+
+// Options 打包选项
+type Options struct {
+	Include   []string            // 仅备份匹配的路径（gitignore 风格，为空表示全部）
+	Exclude   []string            // 排除匹配的路径（gitignore 风格）
+	Encryptor encryptor.Encryptor // 压缩后的数据流加密器，为 nil 表示不加密
+	Manifest  *Manifest           // 非 nil 时在打包过程中记录文件清单
+	Stats     *WalkStats          // 非 nil 时在打包结束后写入文件统计
+	Streams   []Stream            // 在源目录之后写入的数据流条目，如数据库导出
+	TempDir   string              // 数据流超出内存缓冲时转存的目录，为空时使用系统临时目录
+}
+
+// ignorePattern 单条 gitignore 风格规则
+type ignorePattern struct {
+	negate   bool
+	dirOnly  bool
+	segments []string
+}
+
+// parsePattern 解析一条规则，空行与注释返回 false
+func parsePattern(line string) (ignorePattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	var p ignorePattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // 转义开头的 '#' 或 '!'
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	// 不含 '/' 的规则匹配任意层级，否则相对规则所在目录锚定
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	p.segments = strings.Split(line, "/")
+	if !anchored {
+		p.segments = append([]string{"**"}, p.segments...)
+	}
+	return p, true
+}
+
+// match 判断相对路径（以 / 分隔）是否匹配规则
+func (p ignorePattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return matchSegments(p.segments, strings.Split(rel, "/"))
+}
+
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			// 末尾的 "**" 匹配其下的所有内容（至少一层）
+			if len(pat) == 1 {
+				return len(name) > 0
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pat[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// Filter 备份路径过滤器
+// 规则按 gitignore 语义求值：配置中的 exclude 优先级最低，越深层目录中的 .backupignore 优先级越高，最后命中的规则生效
+type Filter struct {
+	includes []ignorePattern
+	excludes []ignorePattern
+	ignores  map[string][]ignorePattern // 目录相对路径 → .backupignore 规则
+}
+
+// NewFilter 根据打包选项创建过滤器
+func NewFilter(opts Options) *Filter {
+	f := &Filter{ignores: make(map[string][]ignorePattern)}
+	for _, line := range opts.Include {
+		if p, ok := parsePattern(line); ok {
+			p.negate = false
+			f.includes = append(f.includes, p)
+		}
+	}
+	for _, line := range opts.Exclude {
+		if p, ok := parsePattern(line); ok {
+			f.excludes = append(f.excludes, p)
+		}
+	}
+	return f
+}
+
+// LoadIgnoreFile 读取目录下的 .backupignore（不存在时忽略）
+func (f *Filter) LoadIgnoreFile(dir, rel string) error {
+	file, err := os.Open(filepath.Join(dir, IgnoreFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("读取 %s 失败: %w", IgnoreFileName, err)
+	}
+	defer file.Close()
+
+	var patterns []ignorePattern
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if p, ok := parsePattern(scanner.Text()); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取 %s 失败: %w", IgnoreFileName, err)
+	}
+	if len(patterns) > 0 {
+		f.ignores[rel] = patterns
+	}
+	return nil
+}
+
+// Excluded 判断相对路径是否被排除
+func (f *Filter) Excluded(rel string, isDir bool) bool {
+	excluded := false
+	apply := func(patterns []ignorePattern, name string) {
+		for _, p := range patterns {
+			if p.match(name, isDir) {
+				excluded = !p.negate
+			}
+		}
+	}
+
+	apply(f.excludes, rel)
+	if len(f.ignores) == 0 {
+		return excluded
+	}
+
+	// 由浅入深应用各级目录的 .backupignore
+	if patterns, ok := f.ignores["."]; ok {
+		apply(patterns, rel)
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		if patterns, ok := f.ignores[dir]; ok {
+			apply(patterns, strings.Join(parts[i:], "/"))
+		}
+	}
+	return excluded
+}
+
+// HasIncludes 是否配置了 include 规则
+func (f *Filter) HasIncludes() bool {
+	return len(f.includes) > 0
+}
+
+// Included 判断相对路径是否被 include 规则选中（路径本身或任一上级目录命中即可）
+func (f *Filter) Included(rel string, isDir bool) bool {
+	if len(f.includes) == 0 {
+		return true
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i <= len(parts); i++ {
+		name := strings.Join(parts[:i], "/")
+		nameIsDir := isDir || i < len(parts)
+		for _, p := range f.includes {
+			if p.match(name, nameIsDir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// visit 在遍历中对路径应用过滤规则
+// 返回 skip=true 表示跳过该条目；被排除的目录返回 fs.SkipDir 以跳过整棵子树
+func (f *Filter) visit(root, p string, isDir bool) (skip bool, err error) {
+	rel, relErr := filepath.Rel(root, p)
+	if relErr != nil {
+		return false, nil
+	}
+	rel = filepath.ToSlash(rel)
+
+	if rel != "." && f.Excluded(rel, isDir) {
+		if isDir {
+			return true, filepath.SkipDir
+		}
+		return true, nil
+	}
+	if isDir {
+		if loadErr := f.LoadIgnoreFile(p, rel); loadErr != nil {
+			logger.PrintLog("warn", fmt.Sprintf("忽略无法读取的排除规则文件: %s (错误: %v)", p, loadErr))
+		}
+		return false, nil
+	}
+	return !f.Included(rel, false), nil
+}
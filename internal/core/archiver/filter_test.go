@@ -0,0 +1,163 @@
+package archiver
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestFilterExcluded(t *testing.T) {
+	f := NewFilter(Options{Exclude: []string{
+		"*.tmp",
+		"node_modules/",
+		"/build",
+		"logs/**/*.log",
+		"!keep.tmp",
+	}})
+
+	cases := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"a.tmp", false, true},
+		{"sub/dir/b.tmp", false, true},
+		{"keep.tmp", false, false},
+		{"node_modules", true, true},
+		{"web/node_modules", true, true},
+		{"node_modules", false, false}, // 仅匹配目录
+		{"build", true, true},
+		{"src/build", true, false}, // 以 / 开头的规则锚定根目录
+		{"logs/app.log", false, true},
+		{"logs/2024/01/app.log", false, true},
+		{"other/app.log", false, false},
+		{"main.go", false, false},
+	}
+	for _, c := range cases {
+		if got := f.Excluded(c.rel, c.isDir); got != c.want {
+			t.Errorf("Excluded(%q, %v) = %v, want %v", c.rel, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestFilterIncluded(t *testing.T) {
+	f := NewFilter(Options{Include: []string{"*.sql", "www/"}})
+
+	cases := []struct {
+		rel  string
+		want bool
+	}{
+		{"dump.sql", true},
+		{"db/nightly/dump.sql", true},
+		{"www/index.html", true},
+		{"www/static/app.js", true},
+		{"etc/nginx.conf", false},
+	}
+	for _, c := range cases {
+		if got := f.Included(c.rel, false); got != c.want {
+			t.Errorf("Included(%q) = %v, want %v", c.rel, got, c.want)
+		}
+	}
+}
+
+func TestCompressWithFilters(t *testing.T) {
+	srcDir := t.TempDir()
+	files := map[string]string{
+		"keep.txt":                "keep",
+		"cache.tmp":               "tmp",
+		"node_modules/pkg/a.js":   "js",
+		"app/main.go":             "go",
+		"app/secret/key.pem":      "pem",
+		"app/" + IgnoreFileName:   "secret/\n",
+		"app/data/" + "large.bin": "bin",
+	}
+	for name, content := range files {
+		p := filepath.Join(srcDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := Options{Exclude: []string{"*.tmp", "node_modules/", "**/data/**"}}
+	dstFile := filepath.Join(t.TempDir(), "archive.tar.zst")
+	origSize, _, err := Compress(srcDir, dstFile, opts)
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+
+	want := []string{"app/", "app/" + IgnoreFileName, "app/data/", "app/main.go", "keep.txt"}
+	got := readArchiveNames(t, dstFile)
+	if !equalStrings(got, want) {
+		t.Errorf("Archive entries = %v, want %v", got, want)
+	}
+
+	size, err := CalculateDirSize(srcDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != origSize {
+		t.Errorf("CalculateDirSize = %d, Compress original size = %d", size, origSize)
+	}
+	if wantSize := int64(len("keep") + len("go") + len("secret/\n")); size != wantSize {
+		t.Errorf("Expected filtered size %d, got %d", wantSize, size)
+	}
+
+	// include 规则只保留选中的文件及其上级目录
+	dstFile2 := filepath.Join(t.TempDir(), "archive.tar.zst")
+	if _, _, err := Compress(srcDir, dstFile2, Options{Include: []string{"*.go"}}); err != nil {
+		t.Fatalf("Compress with include failed: %v", err)
+	}
+	want = []string{"app/", "app/main.go"}
+	if got := readArchiveNames(t, dstFile2); !equalStrings(got, want) {
+		t.Errorf("Archive entries = %v, want %v", got, want)
+	}
+}
+
+func readArchiveNames(t *testing.T, archive string) []string {
+	t.Helper()
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := zstd.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	var names []string
+	tr := tar.NewReader(zr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, h.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
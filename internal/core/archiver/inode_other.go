@@ -0,0 +1,10 @@
+//go:build !unix
+
+package archiver
+
+import "io/fs"
+
+// fileInode 当前平台不支持 inode，增量备份仅比较大小、修改时间和权限
+func fileInode(info fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package archiver
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileInode 返回文件的 inode 编号，用于增量备份识别被替换的文件
+func fileInode(info fs.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,270 @@
+package archiver
+
+import (
+	"archive/tar"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/core/encryptor"
+)
+
+const (
+	// ManifestSuffix 清单文件后缀，与归档存放在同一前缀下
+	ManifestSuffix = ".manifest.json"
+	// manifestFormat 清单格式版本
+	manifestFormat = 1
+)
+
+// 备份类型
+const (
+	KindFull        = "full"
+	KindIncremental = "incremental"
+	KindSnapshot    = "snapshot" // 分块仓库的快照，文件内容按 Chunks 引用仓库中的数据块
+)
+
+// 清单中的条目类型
+const (
+	EntryFile    = "file"
+	EntryDir     = "dir"
+	EntrySymlink = "symlink"
+	EntryOther   = "other"
+)
+
+// Manifest 备份清单，记录归档内的全部条目，无需下载归档即可列举、比较和校验
+type Manifest struct {
+	Format         int            `json:"format"`
+	Kind           string         `json:"kind"`           // 备份类型，旧版本清单为空时视为完整备份
+	Base           string         `json:"base,omitempty"` // 增量备份所基于的上一个备份文件名
+	Tool           string         `json:"tool"`
+	ToolVersion    string         `json:"tool_version"`
+	Hostname       string         `json:"hostname"`
+	CreatedAt      time.Time      `json:"created_at"`
+	Sources        []Source       `json:"sources"`
+	Encrypted      bool           `json:"encrypted"`
+	OriginalSize   int64          `json:"original_size"`
+	CompressedSize int64          `json:"compressed_size"`
+	Files          []ManifestFile `json:"files"`
+	Deleted        []string       `json:"deleted,omitempty"` // 增量备份: 相对上一个备份被删除的条目
+
+	base map[string]ManifestFile // 上一个备份的条目索引，仅增量打包时使用
+}
+
+// ManifestFile 清单中的一个条目
+type ManifestFile struct {
+	Path    string    `json:"path"` // 归档内路径，目录以 / 结尾
+	Type    string    `json:"type"`
+	Size    int64     `json:"size,omitempty"`
+	Mode    string    `json:"mode"` // 八进制权限，如 "0644"
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256,omitempty"` // 仅普通文件
+	Link    string    `json:"link,omitempty"`   // 仅符号链接
+	Inode   uint64    `json:"inode,omitempty"`
+	Chunks  []string  `json:"chunks,omitempty"` // 仅分块仓库: 按顺序组成文件内容的数据块 ID
+
+	Unchanged bool `json:"unchanged,omitempty"` // 增量备份: 内容未变化，不在本归档中
+}
+
+// NewManifest 创建空清单
+func NewManifest(sources []Source) *Manifest {
+	hostname, _ := os.Hostname()
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		version = info.Main.Version
+	}
+	return &Manifest{
+		Format:      manifestFormat,
+		Kind:        KindFull,
+		Tool:        "backup-go",
+		ToolVersion: version,
+		Hostname:    hostname,
+		CreatedAt:   time.Now(),
+		Sources:     sources,
+	}
+}
+
+// IsIncremental 是否为增量备份
+func (m *Manifest) IsIncremental() bool {
+	return m.Kind == KindIncremental
+}
+
+// SetBase 将清单设为基于上一个备份的增量清单，name 为上一个备份的文件名
+// 打包时大小、修改时间、权限和 inode 均未变化的文件不再写入归档
+func (m *Manifest) SetBase(base *Manifest, name string) {
+	m.Kind = KindIncremental
+	m.Base = name
+	m.base = make(map[string]ManifestFile, len(base.Files))
+	for _, f := range base.Files {
+		m.base[f.Path] = f
+	}
+}
+
+// unchanged 判断文件相对上一个备份是否未变化，未变化时返回沿用的条目
+func (m *Manifest) unchanged(name string, info fs.FileInfo) (ManifestFile, bool) {
+	if m == nil || m.base == nil {
+		return ManifestFile{}, false
+	}
+	prev, ok := m.base[name]
+	if !ok || prev.Type != EntryFile || prev.SHA256 == "" {
+		return ManifestFile{}, false
+	}
+	inode := fileInode(info)
+	if prev.Size != info.Size() || !prev.ModTime.Equal(info.ModTime()) ||
+		prev.Mode != fmt.Sprintf("%04o", info.Mode().Perm()) ||
+		(prev.Inode != 0 && inode != 0 && prev.Inode != inode) {
+		return ManifestFile{}, false
+	}
+	prev.Unchanged = true
+	return prev, true
+}
+
+// keepBase 沿用上一个备份中 name 的条目，name 为目录时连同其下的全部条目
+// 用于因读取错误跳过的条目：源上仍然存在，恢复时从之前的归档中取得最后一次成功备份的内容
+func (m *Manifest) keepBase(name string) {
+	if m == nil || m.base == nil {
+		return
+	}
+	seen := make(map[string]bool, len(m.Files))
+	for _, f := range m.Files {
+		seen[f.Path] = true
+	}
+	dir := strings.TrimSuffix(name, "/") + "/"
+	for path, f := range m.base {
+		if seen[path] || (path != name && !strings.HasPrefix(path, dir)) {
+			continue
+		}
+		if f.Type == EntryFile {
+			f.Unchanged = true
+		}
+		m.Files = append(m.Files, f)
+	}
+}
+
+// finish 打包结束后记录相对上一个备份被删除的条目
+func (m *Manifest) finish() {
+	if m.base == nil {
+		return
+	}
+	seen := make(map[string]bool, len(m.Files))
+	for _, f := range m.Files {
+		seen[f.Path] = true
+	}
+	m.Deleted = nil
+	for name := range m.base {
+		if !seen[name] {
+			m.Deleted = append(m.Deleted, name)
+		}
+	}
+	sort.Strings(m.Deleted)
+}
+
+// add 记录一个已写入 tar 的条目
+func (m *Manifest) add(h *tar.Header, info fs.FileInfo, sha256 string) {
+	if m == nil {
+		return
+	}
+	f := ManifestFile{
+		Path:    h.Name,
+		Mode:    fmt.Sprintf("%04o", fs.FileMode(h.Mode).Perm()),
+		ModTime: h.ModTime,
+		SHA256:  sha256,
+		Inode:   fileInode(info),
+	}
+	switch h.Typeflag {
+	case tar.TypeReg:
+		f.Type, f.Size = EntryFile, h.Size
+	case tar.TypeDir:
+		f.Type = EntryDir
+	case tar.TypeSymlink:
+		f.Type, f.Link = EntrySymlink, h.Linkname
+	default:
+		f.Type = EntryOther
+	}
+	m.Files = append(m.Files, f)
+}
+
+// addEntry 记录一个不来自文件系统的条目（如数据流条目）
+func (m *Manifest) addEntry(f ManifestFile) {
+	if m == nil {
+		return
+	}
+	m.Files = append(m.Files, f)
+}
+
+// Encode 将清单编码为 JSON 写入 w，enc 非 nil 时与归档使用相同的方式加密
+func (m *Manifest) Encode(w io.Writer, enc encryptor.Encryptor) error {
+	out := w
+	var ew io.WriteCloser
+	if enc != nil {
+		var err error
+		if ew, err = enc.Encrypt(w); err != nil {
+			return err
+		}
+		out = ew
+	}
+	e := json.NewEncoder(out)
+	e.SetIndent("", "  ")
+	if err := e.Encode(m); err != nil {
+		return fmt.Errorf("编码清单失败: %w", err)
+	}
+	if ew != nil {
+		if err := ew.Close(); err != nil {
+			return fmt.Errorf("加密清单失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteManifestFile 将清单写入本地文件
+func WriteManifestFile(path string, m *Manifest, enc encryptor.Encryptor) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建清单文件失败: %w", err)
+	}
+	if err := m.Encode(f, enc); err != nil {
+		f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// ReadManifest 读取清单，加密的清单自动解密
+func ReadManifest(r io.Reader, cfg config.EncryptionConfig) (*Manifest, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(1)
+	if err != nil {
+		return nil, fmt.Errorf("读取清单失败: %w", err)
+	}
+	var src io.Reader = br
+	if head[0] != '{' {
+		if src, err = encryptor.NewReader(br, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	var m Manifest
+	if err := json.NewDecoder(src).Decode(&m); err != nil {
+		return nil, fmt.Errorf("解析清单失败: %w", err)
+	}
+	return &m, nil
+}
+
+// FileIndex 返回清单中内容位于本归档内的普通文件的路径索引
+func (m *Manifest) FileIndex() map[string]ManifestFile {
+	index := make(map[string]ManifestFile, len(m.Files))
+	for _, f := range m.Files {
+		if f.Type == EntryFile && !f.Unchanged {
+			index[f.Path] = f
+		}
+	}
+	return index
+}
@@ -0,0 +1,279 @@
+package archiver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/core/encryptor"
+)
+
+func TestCompressManifest(t *testing.T) {
+	srcDir := t.TempDir()
+	content := []byte("manifest content")
+	if err := os.MkdirAll(filepath.Join(srcDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "sub", "a.txt"), content, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/a.txt", filepath.Join(srcDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := NewSources([]string{srcDir})
+	m := NewManifest(sources)
+	archive := filepath.Join(t.TempDir(), "archive.tar.zst")
+	orig, compressed, err := CompressSources(sources, archive, Options{Manifest: m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.OriginalSize != orig || m.CompressedSize != compressed || m.Encrypted {
+		t.Errorf("Unexpected manifest sizes: %+v", m)
+	}
+
+	prefix := sources[0].Prefix
+	byPath := make(map[string]ManifestFile)
+	for _, f := range m.Files {
+		byPath[f.Path] = f
+	}
+	sum := sha256.Sum256(content)
+	file := byPath[prefix+"/sub/a.txt"]
+	if file.Type != EntryFile || file.Size != int64(len(content)) || file.Mode != "0640" || file.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("Unexpected file entry: %+v", file)
+	}
+	if f := byPath[prefix+"/sub/"]; f.Type != EntryDir {
+		t.Errorf("Expected directory entry, got %+v", f)
+	}
+	if f := byPath[prefix+"/link"]; f.Type != EntrySymlink || f.Link != "sub/a.txt" {
+		t.Errorf("Expected symlink entry, got %+v", f)
+	}
+
+	// 归档与清单一致时校验通过，清单被篡改时报告出错的条目
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(bytes.NewReader(data), m); err != nil {
+		t.Fatalf("Verify with manifest failed: %v", err)
+	}
+	for i := range m.Files {
+		if m.Files[i].Type == EntryFile {
+			m.Files[i].SHA256 = strings.Repeat("0", 64)
+		}
+	}
+	_, err = Verify(bytes.NewReader(data), m)
+	if err == nil || !strings.Contains(err.Error(), "sub/a.txt") {
+		t.Errorf("Expected SHA-256 mismatch for sub/a.txt, got %v", err)
+	}
+}
+
+func TestManifestEncodeEncrypted(t *testing.T) {
+	t.Setenv("TEST_BACKUP_PASSPHRASE", "manifest-test")
+	cfg := config.EncryptionConfig{Mode: encryptor.ModePassphrase, PassphraseEnv: "TEST_BACKUP_PASSPHRASE"}
+	enc, err := encryptor.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManifest([]Source{{Path: "/data"}})
+	m.Files = append(m.Files, ManifestFile{Path: "secret-name.txt", Type: EntryFile, Size: 1})
+
+	for _, e := range []encryptor.Encryptor{nil, enc} {
+		var buf bytes.Buffer
+		if err := m.Encode(&buf, e); err != nil {
+			t.Fatal(err)
+		}
+		if e != nil && bytes.Contains(buf.Bytes(), []byte("secret-name")) {
+			t.Error("Encrypted manifest leaks file names")
+		}
+		got, err := ReadManifest(&buf, cfg)
+		if err != nil {
+			t.Fatalf("ReadManifest failed: %v", err)
+		}
+		if len(got.Files) != 1 || got.Files[0].Path != "secret-name.txt" || got.Hostname != m.Hostname {
+			t.Errorf("Unexpected manifest: %+v", got)
+		}
+	}
+}
+
+func TestCompressIncremental(t *testing.T) {
+	srcDir := t.TempDir()
+	write := func(name, content string, mtime time.Time) {
+		p := filepath.Join(srcDir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	write("same.txt", "unchanged", old)
+	write("changed.txt", "before", old)
+	write("deleted.txt", "gone soon", old)
+
+	sources := []Source{{Path: srcDir}}
+	full := NewManifest(sources)
+	fullArchive := filepath.Join(t.TempDir(), "full.tar.zst")
+	if _, _, err := CompressSources(sources, fullArchive, Options{Manifest: full}); err != nil {
+		t.Fatal(err)
+	}
+
+	write("changed.txt", "after!", old.Add(time.Hour))
+	write("new.txt", "new file", old)
+	if err := os.Remove(filepath.Join(srcDir, "deleted.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 经 JSON 往返后的清单作为基础，与从存储读取的情况一致
+	var buf bytes.Buffer
+	if err := full.Encode(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	base, err := ReadManifest(&buf, config.EncryptionConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	incr := NewManifest(sources)
+	incr.SetBase(base, "full.tar.zst")
+	incrArchive := filepath.Join(t.TempDir(), "incr.tar.zst")
+	if _, _, err := CompressSources(sources, incrArchive, Options{Manifest: incr}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !incr.IsIncremental() || incr.Base != "full.tar.zst" {
+		t.Errorf("Unexpected manifest kind/base: %s/%s", incr.Kind, incr.Base)
+	}
+	if len(incr.Deleted) != 1 || incr.Deleted[0] != "deleted.txt" {
+		t.Errorf("Expected deleted.txt to be recorded as deleted, got %v", incr.Deleted)
+	}
+	archived := incr.FileIndex()
+	if _, ok := archived["same.txt"]; ok || len(archived) != 2 {
+		t.Errorf("Expected only changed.txt and new.txt in archive, got %v", archived)
+	}
+
+	data, err := os.ReadFile(incrArchive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(bytes.NewReader(data), incr); err != nil {
+		t.Fatalf("Verify incremental failed: %v", err)
+	}
+
+	// 依次恢复完整备份与增量备份
+	restoreDir := t.TempDir()
+	if err := Extract(fullArchive, restoreDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := Extract(incrArchive, restoreDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveEntries(restoreDir, incr.Deleted); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"same.txt": "unchanged", "changed.txt": "after!", "new.txt": "new file"}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(restoreDir, name))
+		if err != nil || string(got) != content {
+			t.Errorf("%s: got %q (%v), want %q", name, got, err, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(restoreDir, "deleted.txt")); !os.IsNotExist(err) {
+		t.Error("Deleted file was restored")
+	}
+}
+
+func TestCompressIncrementalUnreadable(t *testing.T) {
+	srcDir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "aaa", "secret.txt": "secret", "sub/b.txt": "bbb"} {
+		p := filepath.Join(srcDir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sources := []Source{{Path: srcDir}}
+	full := NewManifest(sources)
+	fullArchive := filepath.Join(t.TempDir(), "full.tar.zst")
+	if _, _, err := CompressSources(sources, fullArchive, Options{Manifest: full}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 增量备份时 secret.txt 已修改但无法读取
+	if err := os.WriteFile(filepath.Join(srcDir, "secret.txt"), []byte("changed secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := openFile
+	openFile = func(name string) (*os.File, error) {
+		if filepath.Base(name) == "secret.txt" {
+			return nil, os.ErrPermission
+		}
+		return old(name)
+	}
+	t.Cleanup(func() { openFile = old })
+
+	incr := NewManifest(sources)
+	incr.SetBase(full, "full.tar.zst")
+	incrArchive := filepath.Join(t.TempDir(), "incr.tar.zst")
+	var stats WalkStats
+	if _, _, err := CompressSources(sources, incrArchive, Options{Manifest: incr, Stats: &stats}); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Skipped != 1 {
+		t.Errorf("Expected one skipped file, got %d", stats.Skipped)
+	}
+	if len(incr.Deleted) != 0 {
+		t.Errorf("Unreadable file should not be recorded as deleted, got %v", incr.Deleted)
+	}
+	data, err := os.ReadFile(incrArchive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(bytes.NewReader(data), incr); err != nil {
+		t.Fatalf("Verify incremental failed: %v", err)
+	}
+
+	// 恢复后保留上一次成功备份的内容
+	restoreDir := t.TempDir()
+	for _, archive := range []string{fullArchive, incrArchive} {
+		if err := Extract(archive, restoreDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RemoveEntries(restoreDir, incr.Deleted); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(restoreDir, "secret.txt")); err != nil || string(got) != "secret" {
+		t.Errorf("secret.txt: got %q (%v), want the previous content", got, err)
+	}
+}
+
+func TestManifestKeepBaseDir(t *testing.T) {
+	base := &Manifest{Files: []ManifestFile{
+		{Path: "sub/", Type: EntryDir},
+		{Path: "sub/a.txt", Type: EntryFile, SHA256: "aa"},
+		{Path: "subway.txt", Type: EntryFile, SHA256: "bb"},
+	}}
+	m := NewManifest(nil)
+	m.SetBase(base, "full.tar.zst")
+	m.Files = []ManifestFile{{Path: "sub/", Type: EntryDir}}
+
+	// 目录无法读取时沿用其下的全部条目，不重复记录已写入的目录
+	m.keepBase("sub")
+	m.finish()
+	if len(m.Files) != 2 || m.Files[1].Path != "sub/a.txt" || !m.Files[1].Unchanged {
+		t.Errorf("Expected sub/a.txt to be carried forward, got %+v", m.Files)
+	}
+	if len(m.Deleted) != 1 || m.Deleted[0] != "subway.txt" {
+		t.Errorf("Expected only subway.txt to be deleted, got %v", m.Deleted)
+	}
+}
@@ -0,0 +1,46 @@
+package archiver
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Source 备份源目录
+type Source struct {
+	Path   string `json:"path"`             // 本地目录
+	Prefix string `json:"prefix,omitempty"` // 在 tar 包中的顶层目录名，为空时内容直接位于包根目录
+}
+
+// NewSources 为多个源目录分配互不冲突的顶层目录名（取目录名，重名时追加 -2、-3 …）
+// reserved 为归档中已被其他内容占用的顶层目录名（如数据库导出目录），源目录不会使用这些名称
+func NewSources(paths []string, reserved ...string) []Source {
+	sources := make([]Source, 0, len(paths))
+	used := make(map[string]bool)
+	for _, name := range reserved {
+		used[name] = true
+	}
+	for _, p := range paths {
+		base := filepath.Base(filepath.Clean(p))
+		base = strings.TrimLeft(base, ".")
+		if base == "" || base == string(filepath.Separator) {
+			base = "root"
+		}
+		prefix := base
+		for i := 2; used[prefix]; i++ {
+			prefix = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[prefix] = true
+		sources = append(sources, Source{Path: p, Prefix: prefix})
+	}
+	return sources
+}
+
+// sourcesDesc 返回用于日志的源目录描述
+func sourcesDesc(sources []Source) string {
+	paths := make([]string, 0, len(sources))
+	for _, src := range sources {
+		paths = append(paths, src.Path)
+	}
+	return strings.Join(paths, ", ")
+}
@@ -0,0 +1,68 @@
+package archiver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSources(t *testing.T) {
+	sources := NewSources([]string{"/etc", "/srv/etc", "/var/www/", "/"})
+	want := []string{"etc", "etc-2", "www", "root"}
+	if len(sources) != len(want) {
+		t.Fatalf("Expected %d sources, got %d", len(want), len(sources))
+	}
+	for i, src := range sources {
+		if src.Prefix != want[i] {
+			t.Errorf("Source %s: expected prefix %s, got %s", src.Path, want[i], src.Prefix)
+		}
+	}
+}
+
+func TestNewSourcesReserved(t *testing.T) {
+	sources := NewSources([]string{"/srv/databases", "/backup/databases", "/var/www"}, "databases")
+	want := []string{"databases-2", "databases-3", "www"}
+	for i, src := range sources {
+		if src.Prefix != want[i] {
+			t.Errorf("Source %s: expected prefix %s, got %s", src.Path, want[i], src.Prefix)
+		}
+	}
+}
+
+func TestCompressSources(t *testing.T) {
+	dir1 := filepath.Join(t.TempDir(), "www")
+	dir2 := filepath.Join(t.TempDir(), "dump")
+	emptyDir := filepath.Join(t.TempDir(), "empty")
+	for _, d := range []string{dir1, dir2, emptyDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir1, "index.html"), []byte("<html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir2, "db.sql"), []byte("SELECT 1;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := NewSources([]string{dir1, dir2, emptyDir})
+	dstFile := filepath.Join(t.TempDir(), "archive.tar.zst")
+	origSize, _, err := CompressSources(sources, dstFile, Options{})
+	if err != nil {
+		t.Fatalf("CompressSources failed: %v", err)
+	}
+	if want := int64(len("<html>") + len("SELECT 1;")); origSize != want {
+		t.Errorf("Expected original size %d, got %d", want, origSize)
+	}
+
+	want := []string{"dump/", "dump/db.sql", "empty/", "www/", "www/index.html"}
+	if got := readArchiveNames(t, dstFile); !equalStrings(got, want) {
+		t.Errorf("Archive entries = %v, want %v", got, want)
+	}
+
+	// 所有源目录都为空时跳过备份
+	_, _, err = CompressSources(NewSources([]string{emptyDir}), filepath.Join(t.TempDir(), "empty.tar.zst"), Options{})
+	if err == nil {
+		t.Error("Expected error for empty sources")
+	}
+}
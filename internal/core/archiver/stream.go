@@ -0,0 +1,149 @@
+package archiver
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+// streamMemoryLimit 数据流在内存中缓冲的最大长度，超出后转存到临时文件
+var streamMemoryLimit = 32 * 1024 * 1024
+
+// Stream 内容来自数据流的条目，如数据库导出程序的标准输出
+// tar 头中需事先写入内容长度，因此数据流先读取完毕再作为单个条目写入：
+// 不超过 streamMemoryLimit 时缓冲在内存中，超出时转存到 Options.TempDir 下的临时文件
+type Stream struct {
+	Name string                        // 在 tar 包中的路径，如 "databases/app.sql"
+	Open func() (io.ReadCloser, error) // 打包到该条目时调用；Close 返回数据源的错误（如导出程序失败）
+}
+
+// addStreams 在源目录之后写入数据流条目及其上级目录，返回写入的内容字节数
+// 数据流出错时中止打包：导出失败的备份不能当作成功
+func addStreams(tw EntryWriter, streams []Stream, tempDir string, m *Manifest, stats *WalkStats) (int64, error) {
+	now := time.Now()
+	dirs := make(map[string]bool)
+	var total int64
+	for _, s := range streams {
+		// 上级目录条目，与源目录中的目录一样记录到清单
+		var parents []string
+		for dir := path.Dir(s.Name); dir != "." && dir != "/" && !dirs[dir]; dir = path.Dir(dir) {
+			parents = append(parents, dir)
+			dirs[dir] = true
+		}
+		for i := len(parents) - 1; i >= 0; i-- {
+			h := &tar.Header{Typeflag: tar.TypeDir, Name: parents[i] + "/", Mode: 0755, ModTime: now}
+			if err := tw.WriteHeader(h); err != nil {
+				return total, fmt.Errorf("写入目录 %s 失败: %w", h.Name, err)
+			}
+			m.addEntry(ManifestFile{Path: h.Name, Type: EntryDir, Mode: "0755", ModTime: now})
+			stats.Processed++
+		}
+
+		n, err := addStream(tw, s, tempDir, m)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		stats.Processed++
+	}
+	return total, nil
+}
+
+// addStream 读取完一个数据流后将其作为单个条目写入 tar，返回写入的字节数
+func addStream(tw EntryWriter, s Stream, tempDir string, m *Manifest) (int64, error) {
+	modTime := time.Now()
+	content, size, sum, err := spoolStream(s, tempDir)
+	if err != nil {
+		return 0, err
+	}
+	defer content.Close()
+
+	h := &tar.Header{Typeflag: tar.TypeReg, Name: s.Name, Size: size, Mode: 0600, ModTime: modTime}
+	if err := tw.WriteHeader(h); err != nil {
+		return 0, fmt.Errorf("写入 tar header 失败: %w", err)
+	}
+	n, err := io.Copy(tw, content)
+	if err != nil {
+		return n, fmt.Errorf("写入 %s 失败: %w", s.Name, err)
+	}
+	m.addEntry(ManifestFile{
+		Path:    s.Name,
+		Type:    EntryFile,
+		Size:    size,
+		Mode:    "0600",
+		ModTime: modTime,
+		SHA256:  sum,
+	})
+	return n, nil
+}
+
+// spoolStream 读取整个数据流并返回其内容、长度与 SHA256
+// 数据源的错误在写入 tar 之前返回，返回的内容用完后需 Close（转存的临时文件随之删除）
+func spoolStream(s Stream, tempDir string) (io.ReadCloser, int64, string, error) {
+	r, err := s.Open()
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("打开 %s 失败: %w", s.Name, err)
+	}
+	hash := sha256.New()
+	content, size, err := readStream(io.TeeReader(r, hash), tempDir)
+	// 数据源的错误（如导出程序失败）优先于读取错误返回
+	if closeErr := r.Close(); closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		if content != nil {
+			content.Close()
+		}
+		return nil, 0, "", fmt.Errorf("%s: %w", s.Name, err)
+	}
+	return content, size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readStream 读取 r 的全部内容，不超过 streamMemoryLimit 时保存在内存中，否则转存到 tempDir 下的临时文件
+func readStream(r io.Reader, tempDir string) (io.ReadCloser, int64, error) {
+	var mem bytes.Buffer
+	if _, err := mem.ReadFrom(io.LimitReader(r, int64(streamMemoryLimit)+1)); err != nil {
+		return nil, 0, fmt.Errorf("读取失败: %w", err)
+	}
+	if mem.Len() <= streamMemoryLimit {
+		size := int64(mem.Len())
+		return io.NopCloser(&mem), size, nil
+	}
+
+	// 超出内存缓冲，连同剩余内容转存到临时文件
+	if tempDir != "" {
+		if err := os.MkdirAll(tempDir, 0700); err != nil {
+			return nil, 0, fmt.Errorf("创建临时目录失败: %w", err)
+		}
+	}
+	f, err := os.CreateTemp(tempDir, "stream-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	spool := &tempFile{f}
+	size, err := io.Copy(f, io.MultiReader(&mem, r))
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return spool, 0, fmt.Errorf("转存到临时文件失败: %w", err)
+	}
+	return spool, size, nil
+}
+
+// tempFile 关闭时删除自身的临时文件
+type tempFile struct {
+	*os.File
+}
+
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	os.Remove(t.Name())
+	return err
+}
@@ -0,0 +1,119 @@
+package archiver
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// errCloser 读取完毕后 Close 返回指定错误的数据流
+type errCloser struct {
+	io.Reader
+	err error
+}
+
+func (e errCloser) Close() error { return e.err }
+
+func bytesStream(name string, data []byte, closeErr error) Stream {
+	return Stream{Name: name, Open: func() (io.ReadCloser, error) {
+		return errCloser{Reader: bytes.NewReader(data), err: closeErr}, nil
+	}}
+}
+
+func TestCompressStreams(t *testing.T) {
+	old := streamMemoryLimit
+	streamMemoryLimit = 1024
+	t.Cleanup(func() { streamMemoryLimit = old })
+
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dump := bytes.Repeat([]byte("INSERT INTO t VALUES (1);\n"), 100) // 超出内存缓冲，转存到临时文件
+	sources := []Source{{Path: srcDir, Prefix: "data"}}
+	m := NewManifest(sources)
+	tempDir := filepath.Join(t.TempDir(), "tmp")
+	opts := Options{Manifest: m, TempDir: tempDir, Streams: []Stream{
+		bytesStream("databases/app.sql", dump, nil),
+		bytesStream("databases/empty.sql", nil, nil),
+	}}
+
+	archive := filepath.Join(t.TempDir(), "backup.tar.zst")
+	original, _, err := CompressSources(sources, archive, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(5 + len(dump)); original != want {
+		t.Errorf("Original size = %d, want %d", original, want)
+	}
+	names := readArchiveNames(t, archive)
+	if !equalStrings(names, []string{"data/", "data/a.txt", "databases/", "databases/app.sql", "databases/empty.sql"}) {
+		t.Errorf("Unexpected archive entries: %v", names)
+	}
+	if f := m.FileIndex()["databases/app.sql"]; f.Size != int64(len(dump)) || f.SHA256 == "" {
+		t.Errorf("Unexpected manifest entry: %+v", f)
+	}
+	if left, _ := os.ReadDir(tempDir); len(left) != 0 {
+		t.Errorf("Spooled temp files should be removed, got %d", len(left))
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := Verify(f, m)
+	f.Close()
+	if err != nil || stats.Files != 3 {
+		t.Errorf("Verify = %+v, %v", stats, err)
+	}
+	dst := t.TempDir()
+	if err := Extract(archive, dst); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dst, "databases", "app.sql"))
+	if err != nil || !bytes.Equal(got, dump) {
+		t.Errorf("Extracted dump mismatch (%d bytes, %v)", len(got), err)
+	}
+
+	// 只有数据流、没有源目录时同样可以打包
+	if _, _, err := CompressSources(nil, archive, Options{Streams: opts.Streams[:1]}); err != nil {
+		t.Errorf("Streams without sources should be archived: %v", err)
+	}
+}
+
+func TestCompressStreamFailure(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Streams: []Stream{bytesStream("databases/app.sql", []byte("partial"), errors.New("pg_dump 导出失败"))}}
+	archive := filepath.Join(t.TempDir(), "backup.tar.zst")
+	if _, _, err := CompressSources([]Source{{Path: srcDir}}, archive, opts); err == nil || !strings.Contains(err.Error(), "pg_dump") {
+		t.Errorf("Expected the dump failure to abort the backup, got %v", err)
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Error("Failed archive should be removed")
+	}
+}
+
+func TestCompressStreamFailureSpooled(t *testing.T) {
+	old := streamMemoryLimit
+	streamMemoryLimit = 16
+	t.Cleanup(func() { streamMemoryLimit = old })
+
+	tempDir := t.TempDir()
+	opts := Options{TempDir: tempDir, Streams: []Stream{
+		bytesStream("databases/app.sql", bytes.Repeat([]byte("x"), 100), errors.New("mysqldump 导出失败")),
+	}}
+	archive := filepath.Join(t.TempDir(), "backup.tar.zst")
+	if _, _, err := CompressSources(nil, archive, opts); err == nil || !strings.Contains(err.Error(), "mysqldump") {
+		t.Errorf("Expected the dump failure to abort the backup, got %v", err)
+	}
+	if left, _ := os.ReadDir(tempDir); len(left) != 0 {
+		t.Errorf("Spooled temp files should be removed, got %d", len(left))
+	}
+}
@@ -0,0 +1,87 @@
+package archiver
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// VerifyStats 校验时统计的归档内容
+type VerifyStats struct {
+	Entries int64 // tar 条目数
+	Files   int64 // 普通文件数
+	Bytes   int64 // 文件内容总字节数
+}
+
+// Verify 完整解码 zstd 压缩的 tar 数据流但不写入任何文件，用于检查归档是否损坏或被截断
+// 解码失败时错误中包含出错的条目名称；m 非 nil 时同时核对每个文件的 SHA-256 与清单是否一致
+func Verify(r io.Reader, m *Manifest) (VerifyStats, error) {
+	var stats VerifyStats
+	var index map[string]ManifestFile
+	if m != nil {
+		index = m.FileIndex()
+	}
+
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return stats, fmt.Errorf("创建 zstd 解压器失败: %w", err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+
+	last := ""
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if last == "" {
+				return stats, fmt.Errorf("读取第一个 tar 条目失败: %w", err)
+			}
+			return stats, fmt.Errorf("读取条目 %s 之后的 tar 头失败: %w", last, err)
+		}
+		stats.Entries++
+		last = h.Name
+
+		if h.Typeflag == tar.TypeReg {
+			sum := sha256.New()
+			n, err := io.Copy(sum, tr)
+			stats.Bytes += n
+			if err != nil {
+				return stats, fmt.Errorf("解码条目 %s 失败（已读取 %d/%d 字节）: %w", h.Name, n, h.Size, err)
+			}
+			stats.Files++
+
+			if index != nil {
+				f, ok := index[h.Name]
+				if !ok {
+					return stats, fmt.Errorf("条目 %s 不在清单中", h.Name)
+				}
+				if got := hex.EncodeToString(sum.Sum(nil)); got != f.SHA256 {
+					return stats, fmt.Errorf("条目 %s 的 SHA-256 与清单不一致: 期望 %s，实际 %s", h.Name, f.SHA256, got)
+				}
+				delete(index, h.Name)
+			}
+		}
+	}
+	if len(index) > 0 {
+		missing := make([]string, 0, len(index))
+		for name := range index {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return stats, fmt.Errorf("清单中有 %d 个文件不在归档中，如 %s", len(missing), missing[0])
+	}
+
+	// tar 结束标记之后读完剩余数据，确保 zstd 帧完整且校验和正确
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		return stats, fmt.Errorf("解码归档尾部失败: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,199 @@
+package dbdump
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"backup-go/internal/config"
+	"backup-go/internal/logger"
+)
+
+// maxStderr 导出失败时错误信息中保留的错误输出长度
+const maxStderr = 2048
+
+// Open 开始导出数据库，返回导出内容的数据流，读取完毕后必须调用 Close，导出失败时 Close 返回错误
+// postgres/mysql 直接读取 pg_dump/mysqldump 的标准输出，不占用本地磁盘；
+// sqlite 使用在线备份 API 在 tempDir 下复制出一致的数据库文件，Close 时删除
+func Open(db config.DatabaseConfig, tempDir string) (io.ReadCloser, error) {
+	logger.PrintLog("backup", fmt.Sprintf("开始导出 %s 数据库: %s", db.Type, db.Database))
+	if db.Type == config.DatabaseSQLite {
+		return openSQLite(db, tempDir)
+	}
+
+	name, args, env, err := dumpCommand(db)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("创建导出管道失败: %w", err)
+	}
+	c := &commandReader{cmd: cmd, stdout: stdout, name: name}
+	cmd.Stderr = &c.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动 %s 失败: %w", name, err)
+	}
+	return c, nil
+}
+
+// commandReader 读取导出程序的标准输出，Close 等待程序退出并返回其错误
+type commandReader struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	name   string
+	stderr bytes.Buffer
+	eof    bool
+}
+
+func (c *commandReader) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err == io.EOF {
+		c.eof = true
+	}
+	return n, err
+}
+
+func (c *commandReader) Close() error {
+	if !c.eof {
+		// 提前结束读取（如打包失败）时结束导出程序，避免其阻塞在写入管道上
+		_ = c.cmd.Process.Kill()
+	}
+	if err := c.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s 导出失败: %w%s", c.name, err, stderrSuffix(c.stderr.String()))
+	}
+	return nil
+}
+
+// openSQLite 将 SQLite 数据库复制到 tempDir 下的临时文件并打开
+func openSQLite(db config.DatabaseConfig, tempDir string) (io.ReadCloser, error) {
+	if _, err := os.Stat(db.Database); err != nil {
+		return nil, fmt.Errorf("读取 SQLite 数据库失败: %w", err)
+	}
+	if err := os.MkdirAll(tempDir, 0700); err != nil {
+		return nil, fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(tempDir, "sqlite-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("创建导出文件失败: %w", err)
+	}
+	dst := tmp.Name()
+	tmp.Close()
+
+	if db.Command != "" || !onlineBackupSupported {
+		err = sqliteCLIBackup(db, dst)
+	} else {
+		err = sqliteOnlineBackup(db.Database, dst)
+	}
+	if err != nil {
+		os.Remove(dst)
+		return nil, err
+	}
+	f, err := os.Open(dst)
+	if err != nil {
+		os.Remove(dst)
+		return nil, fmt.Errorf("打开导出文件失败: %w", err)
+	}
+	return &tempFile{File: f}, nil
+}
+
+// tempFile 关闭时删除的临时文件
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	os.Remove(f.Name())
+	return err
+}
+
+// dumpCommand 返回 postgres/mysql 导出命令、参数与附加的环境变量（密码通过环境变量传递，不出现在进程参数中）
+func dumpCommand(db config.DatabaseConfig) (string, []string, []string, error) {
+	password := ""
+	if db.PasswordEnv != "" {
+		password = os.Getenv(db.PasswordEnv)
+		if password == "" {
+			return "", nil, nil, fmt.Errorf("环境变量 %s 未设置", db.PasswordEnv)
+		}
+	}
+
+	var name string
+	var args, env []string
+	switch db.Type {
+	case config.DatabasePostgres:
+		name = "pg_dump"
+		args = []string{"--no-password"}
+		if db.Host != "" {
+			args = append(args, "--host="+db.Host)
+		}
+		if db.Port > 0 {
+			args = append(args, "--port="+strconv.Itoa(db.Port))
+		}
+		if db.User != "" {
+			args = append(args, "--username="+db.User)
+		}
+		if password != "" {
+			env = append(env, "PGPASSWORD="+password)
+		}
+	case config.DatabaseMySQL:
+		name = "mysqldump"
+		// InnoDB 表在一个事务中导出，不锁表也能得到一致的快照
+		args = []string{"--single-transaction"}
+		if db.Host != "" {
+			args = append(args, "--host="+db.Host)
+		}
+		if db.Port > 0 {
+			args = append(args, "--port="+strconv.Itoa(db.Port))
+		}
+		if db.User != "" {
+			args = append(args, "--user="+db.User)
+		}
+		if password != "" {
+			env = append(env, "MYSQL_PWD="+password)
+		}
+	default:
+		return "", nil, nil, fmt.Errorf("不支持的数据库类型: %q", db.Type)
+	}
+	if db.Command != "" {
+		name = db.Command
+	}
+	args = append(args, db.Args...)
+	args = append(args, db.Database)
+	return name, args, env, nil
+}
+
+// sqliteCLIBackup 通过 sqlite3 命令行的 .backup 命令（同样基于在线备份 API）导出数据库
+func sqliteCLIBackup(db config.DatabaseConfig, dst string) error {
+	name := db.Command
+	if name == "" {
+		name = "sqlite3"
+	}
+	args := append([]string{"-readonly"}, db.Args...)
+	args = append(args, db.Database, ".backup '"+strings.ReplaceAll(dst, "'", "''")+"'")
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s 导出失败: %w%s", name, err, stderrSuffix(stderr.String()))
+	}
+	return nil
+}
+
+// stderrSuffix 将导出程序的错误输出附加到错误信息中
+func stderrSuffix(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return ""
+	}
+	if len(stderr) > maxStderr {
+		stderr = "..." + stderr[len(stderr)-maxStderr:]
+	}
+	return ": " + stderr
+}
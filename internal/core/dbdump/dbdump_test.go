@@ -0,0 +1,41 @@
+package dbdump
+
+import (
+	"slices"
+	"testing"
+
+	"backup-go/internal/config"
+)
+
+func TestDumpCommand(t *testing.T) {
+	t.Setenv("TEST_DB_PASSWORD", "secret")
+	name, args, env, err := dumpCommand(config.DatabaseConfig{
+		Type:        config.DatabaseMySQL,
+		Database:    "app",
+		Host:        "db.internal",
+		Port:        3307,
+		User:        "backup",
+		PasswordEnv: "TEST_DB_PASSWORD",
+		Args:        []string{"--routines"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"--single-transaction", "--host=db.internal", "--port=3307", "--user=backup", "--routines", "app"}
+	if name != "mysqldump" || !slices.Equal(args, want) {
+		t.Errorf("Unexpected command: %s %v", name, args)
+	}
+	// 密码只通过环境变量传递
+	if !slices.Equal(env, []string{"MYSQL_PWD=secret"}) || slices.Contains(args, "secret") {
+		t.Errorf("Unexpected env: %v", env)
+	}
+
+	name, args, env, err = dumpCommand(config.DatabaseConfig{Type: config.DatabasePostgres, Database: "app", Command: "/usr/lib/postgresql/16/bin/pg_dump"})
+	if err != nil || name != "/usr/lib/postgresql/16/bin/pg_dump" || !slices.Equal(args, []string{"--no-password", "app"}) || len(env) != 0 {
+		t.Errorf("Unexpected command: %s %v %v %v", name, args, env, err)
+	}
+
+	if _, _, _, err := dumpCommand(config.DatabaseConfig{Type: config.DatabasePostgres, Database: "app", PasswordEnv: "TEST_DB_UNSET"}); err == nil {
+		t.Error("Expected an error for an unset password variable")
+	}
+}
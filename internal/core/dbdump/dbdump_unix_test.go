@@ -0,0 +1,39 @@
+//go:build unix
+
+package dbdump
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"backup-go/internal/config"
+)
+
+func TestOpenCommand(t *testing.T) {
+	// 以 echo 代替 pg_dump，导出内容即为命令参数
+	r, err := Open(config.DatabaseConfig{Type: config.DatabasePostgres, Database: "app", Command: "echo"}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "--no-password app\n" {
+		t.Errorf("Unexpected dump output: %q", data)
+	}
+
+	// 导出程序失败时 Close 返回错误
+	r, err = Open(config.DatabaseConfig{Type: config.DatabaseMySQL, Database: "app", Command: "false"}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, r)
+	if err := r.Close(); err == nil || !strings.Contains(err.Error(), "导出失败") {
+		t.Errorf("Expected dump failure from Close, got %v", err)
+	}
+}
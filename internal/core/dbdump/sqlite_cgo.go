@@ -0,0 +1,57 @@
+//go:build cgo
+
+package dbdump
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// onlineBackupSupported 启用 cgo 时直接调用 SQLite 在线备份 API
+const onlineBackupSupported = true
+
+// sqliteBusyTimeout 数据库持续被锁定超过该时长时放弃备份
+const sqliteBusyTimeout = 5 * time.Minute
+
+// sqliteOnlineBackup 使用在线备份 API 将数据库复制到 dst，备份期间其他连接仍可读写
+func sqliteOnlineBackup(src, dst string) error {
+	driver := &sqlite3.SQLiteDriver{}
+	srcConn, err := driver.Open(src)
+	if err != nil {
+		return fmt.Errorf("打开 SQLite 数据库失败: %w", err)
+	}
+	defer srcConn.Close()
+	dstConn, err := driver.Open(dst)
+	if err != nil {
+		return fmt.Errorf("创建导出文件失败: %w", err)
+	}
+	defer dstConn.Close()
+
+	backup, err := dstConn.(*sqlite3.SQLiteConn).Backup("main", srcConn.(*sqlite3.SQLiteConn), "main")
+	if err != nil {
+		return fmt.Errorf("开始 SQLite 在线备份失败: %w", err)
+	}
+	// 一次复制全部页面，得到同一时刻的一致快照；数据库被锁定时稍后重试
+	deadline := time.Now().Add(sqliteBusyTimeout)
+	for {
+		done, err := backup.Step(-1)
+		if err != nil {
+			backup.Finish()
+			return fmt.Errorf("SQLite 在线备份失败: %w", err)
+		}
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			backup.Finish()
+			return fmt.Errorf("SQLite 数据库持续被锁定超过 %s，放弃备份", sqliteBusyTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := backup.Finish(); err != nil {
+		return fmt.Errorf("SQLite 在线备份失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+//go:build cgo
+
+package dbdump
+
+import (
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"backup-go/internal/config"
+)
+
+func TestDumpSQLite(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.db")
+	db, err := sql.Open("sqlite3", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		"PRAGMA journal_mode=WAL",
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO items (name) VALUES ('a'), ('b'), ('c')",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 源数据库仍处于打开状态，未写回主文件的 WAL 内容也应包含在备份中
+	tempDir := filepath.Join(dir, "tmp")
+	r, err := Open(config.DatabaseConfig{Type: config.DatabaseSQLite, Database: src}, tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("Temp file should be removed on close, found %d entries", len(entries))
+	}
+	dst := filepath.Join(dir, "dump.db")
+	if err := os.WriteFile(dst, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err := sql.Open("sqlite3", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+	var n int
+	if err := copied.QueryRow("SELECT COUNT(*) FROM items").Scan(&n); err != nil || n != 3 {
+		t.Errorf("Expected 3 rows in backup, got %d (%v)", n, err)
+	}
+
+	if _, err := Open(config.DatabaseConfig{Type: config.DatabaseSQLite, Database: filepath.Join(dir, "missing.db")}, tempDir); err == nil {
+		t.Error("Expected an error for a missing database")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !cgo
+
+package dbdump
+
+import "fmt"
+
+// onlineBackupSupported 未启用 cgo 时通过 sqlite3 命令行执行在线备份
+const onlineBackupSupported = false
+
+func sqliteOnlineBackup(src, dst string) error {
+	return fmt.Errorf("当前构建未启用 cgo，不支持直接调用 SQLite 在线备份 API")
+}
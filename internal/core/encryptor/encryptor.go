@@ -0,0 +1,167 @@
+package encryptor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"backup-go/internal/config"
+)
+
+const (
+	ModeNone       = ""
+	ModeAge        = "age"
+	ModePassphrase = "passphrase"
+)
+
+// ErrAuthentication 密钥错误或数据被篡改
+var ErrAuthentication = errors.New("解密认证失败：密钥错误或数据已损坏/被篡改")
+
+var (
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	ageMagic  = []byte("age-encryption.org/")
+)
+
+// Encryptor 归档加密器
+type Encryptor interface {
+	// Encrypt 返回写入明文、向 w 输出密文的写入器，Close 时写出最后一个数据块（不关闭 w）
+	Encrypt(w io.Writer) (io.WriteCloser, error)
+	// Extension 加密后文件追加的扩展名
+	Extension() string
+}
+
+// New 根据配置创建加密器，未启用加密时返回 nil
+func New(cfg config.EncryptionConfig) (Encryptor, error) {
+	switch cfg.Mode {
+	case ModeNone:
+		return nil, nil
+	case ModeAge:
+		if len(cfg.Recipients) == 0 {
+			return nil, fmt.Errorf("age 加密需要配置 recipients")
+		}
+		var recipients []age.Recipient
+		for _, r := range cfg.Recipients {
+			rcpt, err := age.ParseX25519Recipient(strings.TrimSpace(r))
+			if err != nil {
+				return nil, fmt.Errorf("解析 age 公钥失败: %w", err)
+			}
+			recipients = append(recipients, rcpt)
+		}
+		return &ageEncryptor{recipients: recipients}, nil
+	case ModePassphrase:
+		passphrase, err := loadPassphrase(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &passphraseEncryptor{passphrase: passphrase}, nil
+	default:
+		return nil, fmt.Errorf("不支持的加密方式: %q", cfg.Mode)
+	}
+}
+
+// NewReader 根据数据头自动识别并解密，未加密的 zstd 数据原样返回
+func NewReader(r io.Reader, cfg config.EncryptionConfig) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(ageMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("读取备份文件头失败: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(head, zstdMagic):
+		return br, nil
+	case bytes.HasPrefix(head, ageMagic):
+		identities, err := loadIdentities(cfg)
+		if err != nil {
+			return nil, err
+		}
+		dr, err := age.Decrypt(br, identities...)
+		if err != nil {
+			var noMatch *age.NoIdentityMatchError
+			if errors.As(err, &noMatch) {
+				return nil, fmt.Errorf("%w（私钥与备份的接收方不匹配）", ErrAuthentication)
+			}
+			return nil, fmt.Errorf("age 解密失败: %w", err)
+		}
+		return &authReader{r: dr}, nil
+	case bytes.HasPrefix(head, passphraseMagic):
+		passphrase, err := loadPassphrase(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("备份使用口令加密: %w", err)
+		}
+		return newPassphraseReader(br, passphrase)
+	default:
+		return nil, fmt.Errorf("无法识别的备份文件格式")
+	}
+}
+
+// authReader 将 age 的数据块认证失败统一包装为 ErrAuthentication
+type authReader struct {
+	r io.Reader
+}
+
+func (a *authReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("%w: %v", ErrAuthentication, err)
+	}
+	return n, err
+}
+
+type ageEncryptor struct {
+	recipients []age.Recipient
+}
+
+func (e *ageEncryptor) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	ew, err := age.Encrypt(w, e.recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("创建 age 加密器失败: %w", err)
+	}
+	return ew, nil
+}
+
+func (e *ageEncryptor) Extension() string { return ".age" }
+
+// loadIdentities 读取 age 私钥文件
+func loadIdentities(cfg config.EncryptionConfig) ([]age.Identity, error) {
+	if cfg.IdentityFile == "" {
+		return nil, fmt.Errorf("备份使用 age 加密，但未配置 encryption.identity_file")
+	}
+	f, err := os.Open(cfg.IdentityFile)
+	if err != nil {
+		return nil, fmt.Errorf("打开 age 私钥文件失败: %w", err)
+	}
+	defer f.Close()
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("解析 age 私钥文件失败: %w", err)
+	}
+	return identities, nil
+}
+
+// loadPassphrase 从口令文件或环境变量读取口令
+func loadPassphrase(cfg config.EncryptionConfig) (string, error) {
+	var passphrase string
+	switch {
+	case cfg.PassphraseFile != "":
+		data, err := os.ReadFile(cfg.PassphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("读取口令文件失败: %w", err)
+		}
+		passphrase = strings.TrimRight(string(data), "\r\n")
+	case cfg.PassphraseEnv != "":
+		passphrase = os.Getenv(cfg.PassphraseEnv)
+	default:
+		return "", fmt.Errorf("未配置 encryption.passphrase_file 或 encryption.passphrase_env")
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("加密口令为空")
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,151 @@
+package encryptor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"backup-go/internal/config"
+)
+
+func roundTrip(t *testing.T, enc Encryptor, cfg config.EncryptionConfig, plain []byte) ([]byte, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := enc.Encrypt(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(plain) > 0 && bytes.Contains(buf.Bytes(), plain) {
+		t.Fatal("Ciphertext contains plaintext")
+	}
+
+	r, err := NewReader(&buf, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestPassphraseRoundTrip(t *testing.T) {
+	t.Setenv("TEST_BACKUP_PASSPHRASE", "correct horse battery staple")
+	cfg := config.EncryptionConfig{Mode: ModePassphrase, PassphraseEnv: "TEST_BACKUP_PASSPHRASE"}
+	enc, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 覆盖空数据、不足一块、恰好整块和跨多块的情况
+	for _, size := range []int{0, 100, chunkSize, 3*chunkSize + 17} {
+		plain := bytes.Repeat([]byte("backup-go!"), size/10+1)[:size]
+		got, err := roundTrip(t, enc, cfg, plain)
+		if err != nil {
+			t.Fatalf("size %d: decrypt failed: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: plaintext mismatch", size)
+		}
+	}
+}
+
+func TestPassphraseWrongKey(t *testing.T) {
+	t.Setenv("TEST_BACKUP_PASSPHRASE", "right")
+	cfg := config.EncryptionConfig{Mode: ModePassphrase, PassphraseEnv: "TEST_BACKUP_PASSPHRASE"}
+	enc, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w, _ := enc.Encrypt(&buf)
+	w.Write([]byte("secret data"))
+	w.Close()
+
+	t.Setenv("TEST_BACKUP_PASSPHRASE", "wrong")
+	if _, err := NewReader(bytes.NewReader(buf.Bytes()), cfg); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("Expected ErrAuthentication, got %v", err)
+	}
+}
+
+func TestPassphraseTruncated(t *testing.T) {
+	t.Setenv("TEST_BACKUP_PASSPHRASE", "pass")
+	cfg := config.EncryptionConfig{Mode: ModePassphrase, PassphraseEnv: "TEST_BACKUP_PASSPHRASE"}
+	enc, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w, _ := enc.Encrypt(&buf)
+	w.Write(make([]byte, 2*chunkSize+10))
+	w.Close()
+
+	// 在块边界处截断也必须被发现
+	truncated := buf.Bytes()[:headerSize+chunkSize+16]
+	r, err := NewReader(bytes.NewReader(truncated), cfg)
+	if err == nil {
+		_, err = io.ReadAll(r)
+	}
+	if !errors.Is(err, ErrAuthentication) {
+		t.Errorf("Expected ErrAuthentication for truncated data, got %v", err)
+	}
+}
+
+func TestAgeRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.EncryptionConfig{
+		Mode:         ModeAge,
+		Recipients:   []string{identity.Recipient().String()},
+		IdentityFile: keyFile,
+	}
+	enc, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain := []byte("hello age")
+	got, err := roundTrip(t, enc, cfg, plain)
+	if err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Error("plaintext mismatch")
+	}
+
+	// 使用其他私钥解密
+	other, _ := age.GenerateX25519Identity()
+	otherFile := filepath.Join(t.TempDir(), "other.txt")
+	os.WriteFile(otherFile, []byte(other.String()+"\n"), 0600)
+	cfg.IdentityFile = otherFile
+	if _, err := roundTrip(t, enc, cfg, plain); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("Expected ErrAuthentication, got %v", err)
+	}
+}
+
+func TestNewReaderPlain(t *testing.T) {
+	plain := append([]byte{0x28, 0xb5, 0x2f, 0xfd}, []byte("zstd frame")...)
+	r, err := NewReader(bytes.NewReader(plain), config.EncryptionConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(r)
+	if !bytes.Equal(got, plain) {
+		t.Error("Unencrypted data should pass through unchanged")
+	}
+}
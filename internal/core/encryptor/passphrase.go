@@ -0,0 +1,210 @@
+package encryptor
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 口令加密格式:
+//
+//	header = magic(8) | pbkdf2 迭代次数(4, 大端) | salt(16)
+//	body   = 若干个 AES-256-GCM 数据块，每块明文 64 KiB（最后一块可更短）
+//
+// 每个文件的密钥由随机 salt 派生，nonce 由块序号和“最后一块”标志组成，
+// header 作为附加认证数据，因此截断、重排或篡改都会导致认证失败。
+var passphraseMagic = []byte("BKGOAES1")
+
+const (
+	pbkdf2Iterations = 600000
+	saltSize         = 16
+	chunkSize        = 64 * 1024
+	headerSize       = 8 + 4 + saltSize
+	// 防止恶意文件以极大的迭代次数拖慢解密
+	maxPBKDF2Iterations = 10000000
+)
+
+type passphraseEncryptor struct {
+	passphrase string
+}
+
+func (e *passphraseEncryptor) Extension() string { return ".enc" }
+
+func (e *passphraseEncryptor) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	header := make([]byte, headerSize)
+	copy(header, passphraseMagic)
+	binary.BigEndian.PutUint32(header[8:12], pbkdf2Iterations)
+	if _, err := rand.Read(header[12:]); err != nil {
+		return nil, fmt.Errorf("生成随机 salt 失败: %w", err)
+	}
+
+	aead, err := newAEAD(e.passphrase, header[12:], pbkdf2Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("写入加密头失败: %w", err)
+	}
+	return &chunkWriter{w: w, aead: aead, header: header, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func newAEAD(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("派生加密密钥失败: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建 AES 加密器失败: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce 由块序号和最后一块标志组成 12 字节 nonce
+func chunkNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// chunkWriter 分块加密写入器
+type chunkWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	if c.closed {
+		return 0, errors.New("加密写入器已关闭")
+	}
+	written := 0
+	for len(p) > 0 {
+		// 缓冲区已满且还有后续数据时，才能确定当前块不是最后一块
+		if len(c.buf) == chunkSize {
+			if err := c.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(c.buf[len(c.buf):chunkSize], p)
+		c.buf = c.buf[:len(c.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (c *chunkWriter) flush(last bool) error {
+	sealed := c.aead.Seal(nil, chunkNonce(c.counter, last), c.buf, c.header)
+	if _, err := c.w.Write(sealed); err != nil {
+		return fmt.Errorf("写入加密数据失败: %w", err)
+	}
+	c.counter++
+	c.buf = c.buf[:0]
+	return nil
+}
+
+// Close 写出最后一块，不关闭底层写入器
+func (c *chunkWriter) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.flush(true)
+}
+
+// chunkReader 分块解密读取器
+type chunkReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte // 当前块剩余明文
+	sealed  []byte
+	counter uint64
+	done    bool
+}
+
+func newPassphraseReader(r *bufio.Reader, passphrase string) (io.Reader, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("读取加密头失败: %w", err)
+	}
+	iterations := int(binary.BigEndian.Uint32(header[8:12]))
+	if iterations <= 0 || iterations > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("加密头中的迭代次数无效: %d", iterations)
+	}
+	aead, err := newAEAD(passphrase, header[12:], iterations)
+	if err != nil {
+		return nil, err
+	}
+	c := &chunkReader{
+		r:      r,
+		aead:   aead,
+		header: header,
+		sealed: make([]byte, chunkSize+aead.Overhead()),
+	}
+	// 预先解密第一块，口令错误时立即报错
+	if err := c.next(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// next 读取并解密下一块
+func (c *chunkReader) next() error {
+	n, err := io.ReadFull(c.r, c.sealed)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		last = true
+	case err != nil:
+		return fmt.Errorf("读取加密数据失败: %w", err)
+	default:
+		// 整块读满时，后面没有数据才是最后一块
+		if _, peekErr := c.r.Peek(1); peekErr == io.EOF {
+			last = true
+		}
+	}
+	if n < c.aead.Overhead() {
+		return fmt.Errorf("%w: 加密数据被截断", ErrAuthentication)
+	}
+
+	plain, openErr := c.aead.Open(c.sealed[:0:0], chunkNonce(c.counter, last), c.sealed[:n], c.header)
+	if openErr != nil {
+		if c.counter == 0 {
+			return ErrAuthentication
+		}
+		return fmt.Errorf("%w（第 %d 个数据块）", ErrAuthentication, c.counter+1)
+	}
+	c.counter++
+	c.buf = plain
+	c.done = last
+	return nil
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+	"backup-go/internal/core/archiver"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/logger"
+)
+
+// uploadWorkers 并发上传数据块的协程数
+const uploadWorkers = 4
+
+// BackupStats 一次快照备份的统计
+type BackupStats struct {
+	Chunks    int64 // 快照引用的数据块数（含重复）
+	Bytes     int64 // 文件内容总字节数
+	NewChunks int64 // 新上传的数据块数
+	NewBytes  int64 // 新上传的数据块占用的存储字节数（压缩、加密后）
+}
+
+// Backup 遍历源目录，将文件切分为数据块去重上传，最后保存快照索引，返回快照的对象键
+// opts 中的过滤规则生效，Encryptor 与 Manifest 由仓库自行设置
+func (r *Repository) Backup(sources []archiver.Source, opts archiver.Options) (string, BackupStats, error) {
+	size, err := archiver.CalculateSourcesSize(sources, opts)
+	if err != nil {
+		return "", BackupStats{}, fmt.Errorf("计算源目录大小失败: %w", err)
+	}
+	if size == 0 && len(opts.Streams) == 0 {
+		return "", BackupStats{}, fmt.Errorf("备份源为空，跳过备份")
+	}
+
+	existing, err := r.listChunks()
+	if err != nil {
+		return "", BackupStats{}, err
+	}
+	logger.PrintLog("backup", fmt.Sprintf("仓库中已有 %d 个数据块，开始分块备份 (%s)", len(existing), humanize.Bytes(uint64(size))))
+
+	m := archiver.NewManifest(sources)
+	m.Kind = archiver.KindSnapshot
+	opts.Manifest = m
+	opts.Encryptor = nil
+
+	w := newSnapshotWriter(r, existing)
+	walkErr := archiver.WalkSources(sources, w, opts)
+	if err := w.close(); err != nil {
+		return "", w.stats, err
+	}
+	if walkErr != nil {
+		return "", w.stats, walkErr
+	}
+
+	for i := range m.Files {
+		if m.Files[i].Type == archiver.EntryFile {
+			m.Files[i].Chunks = w.chunks[m.Files[i].Path]
+		}
+	}
+	m.Encrypted = r.enc != nil
+	m.OriginalSize, m.CompressedSize = w.stats.Bytes, w.stats.NewBytes
+
+	key, err := r.saveSnapshot(m)
+	if err != nil {
+		return "", w.stats, err
+	}
+	logger.PrintLog("backup", fmt.Sprintf("快照已保存: %s，共 %d 个数据块，新增 %d 个 (%s)",
+		key, w.stats.Chunks, w.stats.NewChunks, humanize.Bytes(uint64(w.stats.NewBytes))))
+	return key, w.stats, nil
+}
+
+// chunkJob 待上传的数据块
+type chunkJob struct {
+	id   string
+	data []byte
+}
+
+// snapshotWriter 以 archiver.EntryWriter 的形式接收遍历得到的条目，将文件内容分块去重后上传
+type snapshotWriter struct {
+	repo    *Repository
+	known   map[string]bool     // 仓库中已有或本次已提交上传的数据块
+	chunks  map[string][]string // 文件路径 → 数据块 id
+	chunker *Chunker
+	name    string // 正在写入的文件，非普通文件时为空
+
+	jobs  chan chunkJob
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	err   error
+	stats BackupStats
+}
+
+func newSnapshotWriter(r *Repository, existing map[string]storage.ObjectInfo) *snapshotWriter {
+	w := &snapshotWriter{
+		repo:   r,
+		known:  make(map[string]bool, len(existing)),
+		chunks: make(map[string][]string),
+		jobs:   make(chan chunkJob, uploadWorkers),
+	}
+	for id := range existing {
+		w.known[id] = true
+	}
+	w.chunker = NewChunker(w.addChunk)
+
+	w.wg.Add(uploadWorkers)
+	for i := 0; i < uploadWorkers; i++ {
+		go w.upload()
+	}
+	return w
+}
+
+func (w *snapshotWriter) WriteHeader(h *tar.Header) error {
+	if err := w.endFile(); err != nil {
+		return err
+	}
+	if h.Typeflag == tar.TypeReg {
+		w.name = h.Name
+		w.chunks[h.Name] = nil
+	}
+	return w.failed()
+}
+
+func (w *snapshotWriter) Write(p []byte) (int, error) {
+	if w.name == "" {
+		return 0, fmt.Errorf("条目没有内容")
+	}
+	return w.chunker.Write(p)
+}
+
+// endFile 输出当前文件的最后一个数据块
+func (w *snapshotWriter) endFile() error {
+	if w.name == "" {
+		return nil
+	}
+	err := w.chunker.Flush()
+	w.name = ""
+	return err
+}
+
+// addChunk 记录数据块引用，仓库中没有的数据块提交上传
+func (w *snapshotWriter) addChunk(chunk []byte) error {
+	sum := sha256.Sum256(chunk)
+	id := hex.EncodeToString(sum[:])
+	w.chunks[w.name] = append(w.chunks[w.name], id)
+
+	w.mu.Lock()
+	w.stats.Chunks++
+	w.stats.Bytes += int64(len(chunk))
+	w.mu.Unlock()
+
+	if !w.known[id] {
+		w.known[id] = true
+		w.jobs <- chunkJob{id: id, data: bytes.Clone(chunk)}
+	}
+	return w.failed()
+}
+
+func (w *snapshotWriter) upload() {
+	defer w.wg.Done()
+	for job := range w.jobs {
+		if w.failed() != nil {
+			continue
+		}
+		n, err := w.repo.putChunk(job.id, job.data)
+		w.mu.Lock()
+		if err != nil && w.err == nil {
+			w.err = err
+		}
+		if err == nil {
+			w.stats.NewChunks++
+			w.stats.NewBytes += n
+		}
+		w.mu.Unlock()
+	}
+}
+
+// failed 返回上传过程中的第一个错误
+func (w *snapshotWriter) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// close 输出剩余数据并等待所有数据块上传完成
+func (w *snapshotWriter) close() error {
+	err := w.endFile()
+	close(w.jobs)
+	w.wg.Wait()
+	if err != nil {
+		return err
+	}
+	return w.failed()
+}
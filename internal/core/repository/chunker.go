@@ -0,0 +1,73 @@
+package repository
+
+const (
+	// MinChunkSize 数据块最小长度，文件末尾的数据块可以更短
+	MinChunkSize = 256 * 1024
+	// AvgChunkSize 数据块期望平均长度
+	AvgChunkSize = 1024 * 1024
+	// MaxChunkSize 数据块最大长度，超过时强制切分
+	MaxChunkSize = 4 * 1024 * 1024
+
+	// boundaryMask 取滚动哈希的高位判断切分点，命中概率为 1/AvgChunkSize
+	boundaryMask = uint64(1<<20-1) << (64 - 20)
+)
+
+// gearTable Gear 滚动哈希表，由固定种子生成，修改会导致已有数据无法去重
+var gearTable = func() [256]uint64 {
+	var t [256]uint64
+	x := uint64(0x6261636b75702d67) // "backup-g"
+	for i := range t {
+		// splitmix64
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		t[i] = z ^ (z >> 31)
+	}
+	return t
+}()
+
+// Chunker 基于内容的分块器（Gear 滚动哈希）
+// 切分点只取决于附近的内容，文件中间插入或删除数据时，其余位置的数据块保持不变
+type Chunker struct {
+	buf  []byte
+	hash uint64
+	emit func(chunk []byte) error
+}
+
+// NewChunker 创建分块器，每切出一个数据块调用一次 emit，chunk 在 emit 返回后会被复用
+func NewChunker(emit func(chunk []byte) error) *Chunker {
+	return &Chunker{buf: make([]byte, 0, MaxChunkSize), emit: emit}
+}
+
+// Write 写入数据，遇到切分点时输出数据块
+func (c *Chunker) Write(p []byte) (int, error) {
+	for i, b := range p {
+		c.buf = append(c.buf, b)
+		c.hash = c.hash<<1 + gearTable[b]
+		if len(c.buf) < MinChunkSize {
+			continue
+		}
+		if c.hash&boundaryMask == 0 || len(c.buf) >= MaxChunkSize {
+			if err := c.cut(); err != nil {
+				return i + 1, err
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// Flush 输出剩余数据作为最后一个数据块，用于一个文件结束时
+func (c *Chunker) Flush() error {
+	if len(c.buf) == 0 {
+		return nil
+	}
+	return c.cut()
+}
+
+func (c *Chunker) cut() error {
+	err := c.emit(c.buf)
+	c.buf = c.buf[:0]
+	c.hash = 0
+	return err
+}
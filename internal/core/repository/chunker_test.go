@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/rand"
+	"testing"
+)
+
+// chunkSums 返回数据切分后各数据块的哈希
+func chunkSums(t *testing.T, data []byte, writeSize int) [][32]byte {
+	var sums [][32]byte
+	c := NewChunker(func(chunk []byte) error {
+		if len(chunk) > MaxChunkSize {
+			t.Errorf("Chunk too large: %d", len(chunk))
+		}
+		sums = append(sums, sha256.Sum256(chunk))
+		return nil
+	})
+	for len(data) > 0 {
+		n := min(writeSize, len(data))
+		if _, err := c.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return sums
+}
+
+func TestChunker(t *testing.T) {
+	data := make([]byte, 16*1024*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	// 切分结果与写入方式无关
+	a := chunkSums(t, data, 32*1024)
+	b := chunkSums(t, data, 1000003)
+	if len(a) < 4 || len(a) != len(b) {
+		t.Fatalf("Unexpected chunk counts: %d vs %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Chunk %d differs between write sizes", i)
+		}
+	}
+
+	// 在开头插入数据后，后面的数据块大部分保持不变
+	shifted := append([]byte("inserted bytes"), data...)
+	c := chunkSums(t, shifted, 64*1024)
+	seen := make(map[[32]byte]bool)
+	for _, s := range a {
+		seen[s] = true
+	}
+	shared := 0
+	for _, s := range c {
+		if seen[s] {
+			shared++
+		}
+	}
+	if shared < len(a)-2 {
+		t.Errorf("Expected most chunks to survive an insertion, shared %d of %d", shared, len(a))
+	}
+
+	// 全零数据没有切分点时按最大长度切分
+	zeros := chunkSums(t, bytes.Repeat([]byte{0}, 2*MaxChunkSize+1), 1<<20)
+	if len(zeros) != 3 {
+		t.Errorf("Expected 3 chunks for zero data, got %d", len(zeros))
+	}
+}
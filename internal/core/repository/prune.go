@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"backup-go/internal/core/retention"
+	"backup-go/internal/logger"
+	"backup-go/internal/metrics"
+)
+
+// chunkGracePeriod 未被任何快照引用的数据块至少保留该时长，避免删除正在进行的备份刚上传的数据块
+const chunkGracePeriod = 24 * time.Hour
+
+// Prune 删除保留策略之外的快照，再回收不被任何快照引用的数据块
+// 未配置保留策略时不删除快照，只回收数据块；待删除快照超过安全阈值时不做任何删除；DryRun 时只输出清理计划
+func (r *Repository) Prune(policy retention.Policy, opts retention.Options) error {
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, len(snapshots))
+	times := make([]time.Time, len(snapshots))
+	for i, s := range snapshots {
+		keys[i] = s.Key
+		times[i] = s.Time
+	}
+	plan := policy.Plan(keys, times, time.Now())
+	toDelete := retention.CountDeletes(plan)
+	if opts.DryRun {
+		for _, d := range plan {
+			action := "保留"
+			if d.Delete {
+				action = "删除"
+			}
+			logger.PrintLog("dry-run", fmt.Sprintf("%s %s: %s", action, d.Key, d.Reason))
+		}
+		logger.PrintLog("dry-run", fmt.Sprintf("快照 %d 个，将删除 %d 个（未实际删除）", len(plan), toDelete))
+		return opts.CheckThreshold(toDelete, len(plan))
+	}
+	if err := opts.CheckThreshold(toDelete, len(plan)); err != nil {
+		return err
+	}
+
+	var kept []SnapshotInfo
+	var deleted int
+	for i, s := range snapshots {
+		if !plan[i].Delete {
+			kept = append(kept, s)
+			continue
+		}
+		if err := r.store.Delete(s.Key); err != nil {
+			logger.PrintLog("error", fmt.Sprintf("删除快照失败: %s: %v", s.Key, err))
+			kept = append(kept, s)
+			continue
+		}
+		deleted++
+		metrics.RetentionDeleted.Inc()
+		logger.PrintLog("cleanup", fmt.Sprintf("已删除快照: %s (%s)", s.Key, plan[i].Reason))
+	}
+	if deleted > 0 {
+		logger.PrintLog("cleanup", fmt.Sprintf("按保留策略 (%s) 删除快照 %d 个", policy, deleted))
+	}
+	return r.collectGarbage(kept)
+}
+
+// collectGarbage 删除不被 snapshots 引用且超过保护期的数据块
+// 任何一个快照读取失败都会中止回收，宁可多占空间也不能误删数据
+func (r *Repository) collectGarbage(snapshots []SnapshotInfo) error {
+	referenced := make(map[string]bool)
+	for _, s := range snapshots {
+		m, err := r.LoadSnapshot(s.Key)
+		if err != nil {
+			return fmt.Errorf("读取快照 %s 失败，跳过数据块回收: %w", s.Key, err)
+		}
+		for _, f := range m.Files {
+			for _, id := range f.Chunks {
+				referenced[id] = true
+			}
+		}
+	}
+
+	chunks, err := r.listChunks()
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-chunkGracePeriod)
+	var removed, failed, freed int64
+	for id, info := range chunks {
+		if referenced[id] || info.ModTime.After(cutoff) {
+			continue
+		}
+		if err := r.store.Delete(info.Key); err != nil {
+			logger.PrintLog("error", fmt.Sprintf("删除数据块失败: %s: %v", info.Key, err))
+			failed++
+			continue
+		}
+		removed++
+		freed += info.Size
+	}
+	logger.PrintLog("cleanup", fmt.Sprintf("数据块共 %d 个，被引用 %d 个，回收 %d 个 (%s)，失败 %d 个",
+		len(chunks), len(referenced), removed, humanize.Bytes(uint64(freed)), failed))
+	return nil
+}
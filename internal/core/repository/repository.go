@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"backup-go/internal/config"
+	"backup-go/internal/core/archiver"
+	"backup-go/internal/core/encryptor"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/core/uploader"
+)
+
+// 仓库布局（均位于任务前缀下）:
+//
+//	chunks/<id 前两位>/<id>    数据块，id 为明文内容的 SHA-256，内容为 zstd 压缩（加密时再经数据密钥封装）
+//	snapshots/snapshot-<时间>.json  快照索引，即带数据块引用的备份清单（加密时整体加密）
+//	keys/<key id>              数据密钥，由配置的加密方式（age 公钥或口令）加密保存
+//
+// 每次备份生成一个随机数据密钥，数据块用 AES-256-GCM 封装，避免每个数据块都做一次口令派生；
+// 备份时只需要 age 公钥，恢复时才需要私钥解开数据密钥。
+const (
+	chunksDir    = "chunks"
+	snapshotsDir = "snapshots"
+	keysDir      = "keys"
+
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".json"
+	timeLayout     = "20060102-150405"
+
+	keyIDSize = 16
+	keySize   = 32
+)
+
+// sealMagic 加密数据块的文件头: magic(8) | key id(16) | nonce(12) | 密文
+var sealMagic = []byte("BKGOCHK1")
+
+// Repository 基于内容分块去重的备份仓库
+type Repository struct {
+	store  storage.Storage
+	prefix string
+	cfg    config.EncryptionConfig
+	enc    encryptor.Encryptor
+
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+
+	mu       sync.Mutex
+	keys     map[string]cipher.AEAD // 已加载的数据密钥
+	writeKey string                 // 本次写入使用的数据密钥 id，首次写入加密数据块时生成
+}
+
+// SnapshotInfo 仓库中的一个快照
+type SnapshotInfo struct {
+	Key  string
+	Size int64
+	Time time.Time
+}
+
+// Open 打开 prefix 下的仓库，仓库无需初始化，首次备份时自动创建
+func Open(store storage.Storage, prefix string, cfg config.EncryptionConfig) (*Repository, error) {
+	enc, err := encryptor.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("初始化加密失败: %w", err)
+	}
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	if err != nil {
+		return nil, fmt.Errorf("创建 zstd 压缩器失败: %w", err)
+	}
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		encoder.Close()
+		return nil, fmt.Errorf("创建 zstd 解压器失败: %w", err)
+	}
+	return &Repository{
+		store:   store,
+		prefix:  prefix,
+		cfg:     cfg,
+		enc:     enc,
+		encoder: encoder,
+		decoder: decoder,
+		keys:    make(map[string]cipher.AEAD),
+	}, nil
+}
+
+// Close 释放压缩器资源
+func (r *Repository) Close() {
+	r.encoder.Close()
+	r.decoder.Close()
+}
+
+// key 返回仓库内对象的完整键
+func (r *Repository) key(elem ...string) string {
+	return path.Join(append([]string{r.prefix}, elem...)...)
+}
+
+func (r *Repository) chunkKey(id string) string {
+	return r.key(chunksDir, id[:2], id)
+}
+
+// listChunks 列出仓库中已有的数据块
+func (r *Repository) listChunks() (map[string]storage.ObjectInfo, error) {
+	objects, err := r.store.List(r.key(chunksDir) + "/")
+	if err != nil {
+		return nil, fmt.Errorf("列举数据块失败: %w", err)
+	}
+	chunks := make(map[string]storage.ObjectInfo, len(objects))
+	for _, o := range objects {
+		if id := path.Base(o.Key); len(id) == sha256.Size*2 {
+			chunks[id] = o
+		}
+	}
+	return chunks, nil
+}
+
+// put 写入对象，传输受上传限速约束
+func (r *Repository) put(key string, data []byte) error {
+	return r.store.Put(key, uploader.ThrottleUpload(bytes.NewReader(data)), int64(len(data)))
+}
+
+// get 读取对象，传输受下载限速约束
+func (r *Repository) get(key string) (io.ReadCloser, error) {
+	body, err := r.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return uploader.ThrottleDownload(body), nil
+}
+
+// putChunk 压缩（并加密）后写入一个数据块，返回写入的字节数
+func (r *Repository) putChunk(id string, data []byte) (int64, error) {
+	obj := r.encoder.EncodeAll(data, nil)
+	if r.enc != nil {
+		var err error
+		if obj, err = r.seal(id, obj); err != nil {
+			return 0, err
+		}
+	}
+	if err := r.put(r.chunkKey(id), obj); err != nil {
+		return 0, fmt.Errorf("上传数据块 %s 失败: %w", id, err)
+	}
+	return int64(len(obj)), nil
+}
+
+// readChunk 读取一个数据块并校验内容与 id 是否一致
+func (r *Repository) readChunk(id string) ([]byte, error) {
+	body, err := r.get(r.chunkKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("读取数据块 %s 失败: %w", id, err)
+	}
+	obj, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("读取数据块 %s 失败: %w", id, err)
+	}
+
+	if bytes.HasPrefix(obj, sealMagic) {
+		if obj, err = r.open(id, obj); err != nil {
+			return nil, err
+		}
+	}
+	data, err := r.decoder.DecodeAll(obj, nil)
+	if err != nil {
+		return nil, fmt.Errorf("解压数据块 %s 失败: %w", id, err)
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != id {
+		return nil, fmt.Errorf("数据块 %s 内容校验失败", id)
+	}
+	return data, nil
+}
+
+// seal 用本次的数据密钥加密数据块，数据块 id 作为附加认证数据，防止数据块被互相替换
+func (r *Repository) seal(id string, obj []byte) ([]byte, error) {
+	keyID, aead, err := r.currentKey()
+	if err != nil {
+		return nil, err
+	}
+	rawID, _ := hex.DecodeString(keyID)
+	header := make([]byte, 0, len(sealMagic)+keyIDSize+aead.NonceSize())
+	header = append(header, sealMagic...)
+	header = append(header, rawID...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成随机 nonce 失败: %w", err)
+	}
+	header = append(header, nonce...)
+	return aead.Seal(header, nonce, obj, sealAAD(header, id)), nil
+}
+
+// open 解密数据块
+func (r *Repository) open(id string, obj []byte) ([]byte, error) {
+	if len(obj) < len(sealMagic)+keyIDSize {
+		return nil, fmt.Errorf("数据块 %s 已损坏", id)
+	}
+	keyID := hex.EncodeToString(obj[len(sealMagic) : len(sealMagic)+keyIDSize])
+	aead, err := r.loadKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := len(sealMagic) + keyIDSize + aead.NonceSize()
+	if len(obj) < headerSize {
+		return nil, fmt.Errorf("数据块 %s 已损坏", id)
+	}
+	header := obj[:headerSize]
+	plain, err := aead.Open(nil, header[len(header)-aead.NonceSize():], obj[headerSize:], sealAAD(header, id))
+	if err != nil {
+		return nil, fmt.Errorf("数据块 %s: %w", id, encryptor.ErrAuthentication)
+	}
+	return plain, nil
+}
+
+func sealAAD(header []byte, id string) []byte {
+	return append(append([]byte(nil), header...), id...)
+}
+
+// currentKey 返回本次写入使用的数据密钥，首次调用时生成并加密保存到 keys/
+func (r *Repository) currentKey() (string, cipher.AEAD, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writeKey != "" {
+		return r.writeKey, r.keys[r.writeKey], nil
+	}
+
+	raw := make([]byte, keyIDSize+keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("生成数据密钥失败: %w", err)
+	}
+	keyID, key := hex.EncodeToString(raw[:keyIDSize]), raw[keyIDSize:]
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var buf bytes.Buffer
+	w, err := r.enc.Encrypt(&buf)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := w.Write(key); err != nil {
+		return "", nil, fmt.Errorf("加密数据密钥失败: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", nil, fmt.Errorf("加密数据密钥失败: %w", err)
+	}
+	if err := r.put(r.key(keysDir, keyID), buf.Bytes()); err != nil {
+		return "", nil, fmt.Errorf("保存数据密钥失败: %w", err)
+	}
+
+	r.keys[keyID] = aead
+	r.writeKey = keyID
+	return keyID, aead, nil
+}
+
+// loadKey 读取并解密数据密钥
+func (r *Repository) loadKey(keyID string) (cipher.AEAD, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if aead, ok := r.keys[keyID]; ok {
+		return aead, nil
+	}
+
+	body, err := r.get(r.key(keysDir, keyID))
+	if err != nil {
+		return nil, fmt.Errorf("读取数据密钥 %s 失败: %w", keyID, err)
+	}
+	defer body.Close()
+	dr, err := encryptor.NewReader(body, r.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("解密数据密钥 %s 失败: %w", keyID, err)
+	}
+	key, err := io.ReadAll(dr)
+	if err != nil {
+		return nil, fmt.Errorf("解密数据密钥 %s 失败: %w", keyID, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("数据密钥 %s 长度无效", keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	r.keys[keyID] = aead
+	return aead, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建 AES 加密器失败: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Snapshots 列出仓库中的快照（按时间从新到旧排序）
+func (r *Repository) Snapshots() ([]SnapshotInfo, error) {
+	objects, err := r.store.List(r.key(snapshotsDir) + "/")
+	if err != nil {
+		return nil, fmt.Errorf("列举快照失败: %w", err)
+	}
+	var snapshots []SnapshotInfo
+	for _, o := range objects {
+		name := path.Base(o.Key)
+		if !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix)
+		t, err := time.Parse(timeLayout, ts)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, SnapshotInfo{Key: o.Key, Size: o.Size, Time: t})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.After(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// LoadSnapshot 读取快照索引，加密的快照自动解密
+func (r *Repository) LoadSnapshot(key string) (*archiver.Manifest, error) {
+	body, err := r.get(key)
+	if err != nil {
+		return nil, fmt.Errorf("读取快照失败: %w", err)
+	}
+	defer body.Close()
+	m, err := archiver.ReadManifest(body, r.cfg)
+	if err != nil {
+		return nil, err
+	}
+	if m.Kind != archiver.KindSnapshot {
+		return nil, fmt.Errorf("%s 不是快照索引", key)
+	}
+	return m, nil
+}
+
+// saveSnapshot 保存快照索引，返回对象键
+func (r *Repository) saveSnapshot(m *archiver.Manifest) (string, error) {
+	var buf bytes.Buffer
+	if err := m.Encode(&buf, r.enc); err != nil {
+		return "", err
+	}
+	key := r.key(snapshotsDir, snapshotPrefix+m.CreatedAt.Format(timeLayout)+snapshotSuffix)
+	if err := r.put(key, buf.Bytes()); err != nil {
+		return "", fmt.Errorf("上传快照失败: %w", err)
+	}
+	return key, nil
+}
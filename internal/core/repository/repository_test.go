@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/core/archiver"
+	"backup-go/internal/core/encryptor"
+	"backup-go/internal/core/retention"
+	"backup-go/internal/core/storage"
+)
+
+func TestRepository(t *testing.T) {
+	srcDir := t.TempDir()
+	big := make([]byte, 3*1024*1024)
+	rand.New(rand.NewSource(2)).Read(big)
+	if err := os.MkdirAll(filepath.Join(srcDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "sub", "big.bin"), big, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "small.txt"), []byte("small"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("small.txt", filepath.Join(srcDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TEST_BACKUP_PASSPHRASE", "repository-test")
+	cfg := config.EncryptionConfig{Mode: encryptor.ModePassphrase, PassphraseEnv: "TEST_BACKUP_PASSPHRASE"}
+	root := t.TempDir()
+	store, err := storage.NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := Open(store, "job/", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	sources := []archiver.Source{{Path: srcDir}}
+	first, stats, err := repo.Backup(sources, archiver.Options{})
+	if err != nil {
+		t.Fatalf("First backup failed: %v", err)
+	}
+	if stats.NewChunks != stats.Chunks || stats.Bytes != int64(len(big)+5) {
+		t.Errorf("Unexpected first backup stats: %+v", stats)
+	}
+	// 将第一个快照改为很久以前创建，便于测试过期清理
+	old := filepath.Join(root, "job", "snapshots", "snapshot-20200101-000000.json")
+	if err := os.Rename(filepath.Join(root, filepath.FromSlash(first)), old); err != nil {
+		t.Fatal(err)
+	}
+	first = "job/snapshots/snapshot-20200101-000000.json"
+
+	// 修改大文件末尾后再次备份，只有变化的数据块需要上传
+	changed := append(append([]byte(nil), big...), []byte("appended")...)
+	if err := os.WriteFile(filepath.Join(srcDir, "sub", "big.bin"), changed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	second, stats, err := repo.Backup(sources, archiver.Options{})
+	if err != nil {
+		t.Fatalf("Second backup failed: %v", err)
+	}
+	if stats.NewChunks == 0 || stats.NewChunks >= stats.Chunks {
+		t.Errorf("Expected partial dedup on second backup: %+v", stats)
+	}
+
+	snapshots, err := repo.Snapshots()
+	if err != nil || len(snapshots) != 2 || snapshots[0].Key != second {
+		t.Fatalf("Unexpected snapshots: %+v, %v", snapshots, err)
+	}
+
+	for key, want := range map[string][]byte{first: big, second: changed} {
+		dst := t.TempDir()
+		if err := repo.Restore(key, dst); err != nil {
+			t.Fatalf("Restore %s failed: %v", key, err)
+		}
+		got, err := os.ReadFile(filepath.Join(dst, "sub", "big.bin"))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("Restore %s: content mismatch (%v)", key, err)
+		}
+		if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "small.txt" {
+			t.Errorf("Restore %s: symlink = %q, %v", key, link, err)
+		}
+		if info, err := os.Stat(filepath.Join(dst, "small.txt")); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("Restore %s: small.txt = %v, %v", key, info, err)
+		}
+	}
+
+	// 预演清理不删除任何快照
+	if err := repo.Prune(retention.Policy{KeepDays: 30}, retention.Options{DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, _ := repo.Snapshots(); len(snapshots) != 2 {
+		t.Fatalf("Dry run deleted snapshots: %+v", snapshots)
+	}
+
+	// 清理过期快照；刚上传的数据块处于保护期内，不会被回收
+	chunksBefore, _ := repo.listChunks()
+	if err := repo.Prune(retention.Policy{KeepDays: 30}, retention.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, _ := repo.Snapshots(); len(snapshots) != 1 {
+		t.Fatalf("Expected expired snapshot to be deleted, got %+v", snapshots)
+	}
+	if chunks, _ := repo.listChunks(); len(chunks) != len(chunksBefore) {
+		t.Errorf("Chunks within grace period were collected: %d → %d", len(chunksBefore), len(chunks))
+	}
+
+	// 超过保护期后，只被已删除快照引用的数据块被回收
+	past := time.Now().Add(-2 * chunkGracePeriod)
+	for _, info := range chunksBefore {
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(info.Key)), past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Prune(retention.Policy{KeepDays: 30}, retention.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	chunksAfter, _ := repo.listChunks()
+	if len(chunksAfter) == 0 || len(chunksAfter) >= len(chunksBefore) {
+		t.Errorf("Expected unreferenced chunks to be collected: %d → %d", len(chunksBefore), len(chunksAfter))
+	}
+	if _, err := repo.Check(second); err != nil {
+		t.Fatalf("Check after prune failed: %v", err)
+	}
+
+	// 新打开的仓库需要解密数据密钥才能读取，损坏的数据块会被发现
+	reopened, err := Open(store, "job/", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if _, err := reopened.Check(second); err != nil {
+		t.Fatalf("Check with reopened repository failed: %v", err)
+	}
+	for _, info := range chunksAfter {
+		p := filepath.Join(root, filepath.FromSlash(info.Key))
+		data, _ := os.ReadFile(p)
+		data[len(data)-1] ^= 0xff
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		break
+	}
+	if _, err := reopened.Check(second); err == nil || !strings.Contains(err.Error(), "数据块") {
+		t.Errorf("Expected corrupted chunk to be detected, got %v", err)
+	}
+}
+
+func TestRepositoryStreams(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := Open(store, "job/", config.EncryptionConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	// 只有数据流的备份可以恢复出导出文件
+	dump := []byte("CREATE TABLE t (id int);\n")
+	opts := archiver.Options{Streams: []archiver.Stream{{Name: "databases/app.sql", Open: func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(dump)), nil
+	}}}}
+	key, _, err := repo.Backup(nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	if err := repo.Restore(key, dst); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dst, "databases", "app.sql")); err != nil || !bytes.Equal(got, dump) {
+		t.Errorf("Restored dump = %q, %v", got, err)
+	}
+}
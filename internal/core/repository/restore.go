@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+
+	"backup-go/internal/core/archiver"
+	"backup-go/internal/logger"
+)
+
+// Restore 将快照恢复到目标目录
+// 快照按清单重新组装为 tar 数据流，复用归档解压的路径与符号链接安全检查
+func (r *Repository) Restore(key, dstDir string) error {
+	m, err := r.LoadSnapshot(key)
+	if err != nil {
+		return err
+	}
+	logger.PrintLog("restore", fmt.Sprintf("开始恢复快照 %s → %s", key, dstDir))
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(r.writeTar(pw, m))
+	}()
+	err = archiver.ExtractTar(pr, dstDir)
+	pr.CloseWithError(err)
+	return err
+}
+
+// writeTar 按快照清单输出 tar 数据流，文件内容从数据块读取并校验
+func (r *Repository) writeTar(w io.Writer, m *archiver.Manifest) error {
+	tw := tar.NewWriter(w)
+	for _, f := range m.Files {
+		h, err := snapshotHeader(f)
+		if err != nil {
+			return err
+		}
+		if h == nil {
+			continue
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			return err
+		}
+		if f.Type == archiver.EntryFile {
+			if err := r.copyFile(tw, f); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+// copyFile 依次读取文件的数据块写入 w，并核对文件的 SHA-256
+func (r *Repository) copyFile(w io.Writer, f archiver.ManifestFile) error {
+	sum := sha256.New()
+	var n int64
+	for _, id := range f.Chunks {
+		data, err := r.readChunk(id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		sum.Write(data)
+		n += int64(len(data))
+	}
+	if n != f.Size {
+		return fmt.Errorf("%s: 数据块总长度 %d 与文件大小 %d 不一致", f.Path, n, f.Size)
+	}
+	if got := hex.EncodeToString(sum.Sum(nil)); f.SHA256 != "" && got != f.SHA256 {
+		return fmt.Errorf("%s: SHA-256 与快照不一致", f.Path)
+	}
+	return nil
+}
+
+// snapshotHeader 由清单条目生成 tar 头，无法恢复的条目类型返回 nil
+func snapshotHeader(f archiver.ManifestFile) (*tar.Header, error) {
+	mode, err := strconv.ParseInt(f.Mode, 8, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: 无效的权限 %q", f.Path, f.Mode)
+	}
+	h := &tar.Header{Name: f.Path, Mode: mode, ModTime: f.ModTime}
+	switch f.Type {
+	case archiver.EntryFile:
+		h.Typeflag, h.Size = tar.TypeReg, f.Size
+	case archiver.EntryDir:
+		h.Typeflag = tar.TypeDir
+	case archiver.EntrySymlink:
+		h.Typeflag, h.Linkname = tar.TypeSymlink, f.Link
+	default:
+		logger.PrintLog("warn", fmt.Sprintf("跳过不支持的条目类型: %s (%s)", f.Path, f.Type))
+		return nil, nil
+	}
+	return h, nil
+}
+
+// Check 读取快照引用的全部数据块，校验数据块与文件的 SHA-256，不写入任何文件
+func (r *Repository) Check(key string) (archiver.VerifyStats, error) {
+	var stats archiver.VerifyStats
+	m, err := r.LoadSnapshot(key)
+	if err != nil {
+		return stats, err
+	}
+	for _, f := range m.Files {
+		stats.Entries++
+		if f.Type != archiver.EntryFile {
+			continue
+		}
+		if err := r.copyFile(io.Discard, f); err != nil {
+			return stats, err
+		}
+		stats.Files++
+		stats.Bytes += f.Size
+	}
+	return stats, nil
+}
@@ -0,0 +1,40 @@
+package retention
+
+import "fmt"
+
+// DefaultMaxDeletePercent 单次清理默认最多删除的备份比例（百分比）
+const DefaultMaxDeletePercent = 50
+
+// Options 清理过期备份的选项
+type Options struct {
+	DryRun           bool // 只列出将要删除的备份及原因，不实际删除
+	MaxDeletePercent int  // 单次清理最多删除匹配备份的百分比，超过时中止清理；0 使用默认值，100 表示不限制
+}
+
+// CheckThreshold 检查待删除数量是否超过安全阈值，total 为匹配到的备份总数
+// 前缀或保留天数配置错误时可能一次匹配并删除整个目录，超过阈值时返回错误中止清理
+func (o Options) CheckThreshold(toDelete, total int) error {
+	limit := o.MaxDeletePercent
+	if limit <= 0 {
+		limit = DefaultMaxDeletePercent
+	}
+	if limit >= 100 || total == 0 {
+		return nil
+	}
+	if toDelete*100 > total*limit {
+		return fmt.Errorf("将删除 %d/%d 个备份 (%d%%)，超过安全阈值 %d%%，已中止清理；请先用 prune --dry-run 确认清理计划，确认无误后调大 prune_max_percent",
+			toDelete, total, toDelete*100/total, limit)
+	}
+	return nil
+}
+
+// CountDeletes 返回清理计划中待删除的备份数量
+func CountDeletes(plan []Decision) int {
+	n := 0
+	for _, d := range plan {
+		if d.Delete {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,135 @@
+package retention
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Policy 备份保留策略，各项规则保留的备份取并集，0 或负数表示不使用该规则
+// 按天/周/月/年保留时，每个周期保留其中最新的一个备份，从最近的周期开始计数（只统计有备份的周期）
+type Policy struct {
+	KeepDays    int // 保留最近若干天内的全部备份
+	KeepLast    int // 保留最新的若干个备份
+	KeepDaily   int
+	KeepWeekly  int // 按 ISO 周
+	KeepMonthly int
+	KeepYearly  int
+}
+
+// Enabled 是否配置了任何保留规则，未配置时不清理备份
+func (p Policy) Enabled() bool {
+	return p.KeepDays > 0 || p.KeepLast > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0 || p.KeepYearly > 0
+}
+
+// String 返回用于日志的策略描述
+func (p Policy) String() string {
+	var parts []string
+	for _, r := range []struct {
+		name string
+		n    int
+	}{
+		{"keep_days", p.KeepDays},
+		{"keep_last", p.KeepLast},
+		{"keep_daily", p.KeepDaily},
+		{"keep_weekly", p.KeepWeekly},
+		{"keep_monthly", p.KeepMonthly},
+		{"keep_yearly", p.KeepYearly},
+	} {
+		if r.n > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", r.name, r.n))
+		}
+	}
+	if len(parts) == 0 {
+		return "不清理"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Select 返回需要保留的备份，times 为按时间从新到旧排序的备份时间
+// 无论规则如何，最新的一个备份总会保留，避免长时间备份失败后清理掉最后一个可用的备份
+func (p Policy) Select(times []time.Time, now time.Time) []bool {
+	reasons := p.Explain(times, now)
+	keep := make([]bool, len(reasons))
+	for i, r := range reasons {
+		keep[i] = r != ""
+	}
+	return keep
+}
+
+// Explain 与 Select 规则相同，返回每个备份被保留的原因（命中的规则，逗号分隔），不保留的备份为空字符串
+func (p Policy) Explain(times []time.Time, now time.Time) []string {
+	reasons := make([][]string, len(times))
+	if len(times) == 0 {
+		return nil
+	}
+	if !p.Enabled() {
+		out := make([]string, len(times))
+		for i := range out {
+			out[i] = "未配置保留策略"
+		}
+		return out
+	}
+	reasons[0] = append(reasons[0], "最新备份")
+
+	for i := 0; i < len(times) && i < p.KeepLast; i++ {
+		reasons[i] = append(reasons[i], "keep_last")
+	}
+	if p.KeepDays > 0 {
+		cutoff := now.AddDate(0, 0, -p.KeepDays)
+		for i, t := range times {
+			if !t.Before(cutoff) {
+				reasons[i] = append(reasons[i], "keep_days")
+			}
+		}
+	}
+
+	bucket := func(name string, n int, period func(t time.Time) string) {
+		last := ""
+		for i, t := range times {
+			if n <= 0 {
+				return
+			}
+			if key := period(t); key != last {
+				reasons[i] = append(reasons[i], name+" "+key)
+				last = key
+				n--
+			}
+		}
+	}
+	bucket("keep_daily", p.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") })
+	bucket("keep_weekly", p.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	bucket("keep_monthly", p.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") })
+	bucket("keep_yearly", p.KeepYearly, func(t time.Time) string { return t.Format("2006") })
+
+	out := make([]string, len(times))
+	for i, r := range reasons {
+		out[i] = strings.Join(r, ", ")
+	}
+	return out
+}
+
+// Decision 清理计划中单个备份的处理结果
+type Decision struct {
+	Key    string
+	Time   time.Time
+	Delete bool
+	Reason string // 保留时为命中的规则，删除时为过期原因
+}
+
+// Plan 按保留策略生成清理计划，keys 与 times 一一对应并按时间从新到旧排序
+func (p Policy) Plan(keys []string, times []time.Time, now time.Time) []Decision {
+	reasons := p.Explain(times, now)
+	plan := make([]Decision, len(keys))
+	for i, key := range keys {
+		plan[i] = Decision{Key: key, Time: times[i], Reason: reasons[i]}
+		if reasons[i] == "" {
+			plan[i].Delete = true
+			plan[i].Reason = fmt.Sprintf("超出保留策略 (%s)，备份于 %d 天前", p, int(now.Sub(times[i]).Hours()/24))
+		}
+	}
+	return plan
+}
@@ -0,0 +1,108 @@
+package retention
+
+import (
+	"testing"
+	"time"
+)
+
+// dailyBackups 生成从 now 开始每天一个、共 n 个备份的时间（从新到旧）
+func dailyBackups(now time.Time, n int) []time.Time {
+	times := make([]time.Time, n)
+	for i := range times {
+		times[i] = now.AddDate(0, 0, -i)
+	}
+	return times
+}
+
+func countKept(keep []bool) int {
+	n := 0
+	for _, k := range keep {
+		if k {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSelectGFS(t *testing.T) {
+	now := time.Date(2024, 12, 31, 2, 0, 0, 0, time.UTC) // 周二
+	times := dailyBackups(now, 400)
+
+	keep := Policy{KeepDaily: 7, KeepWeekly: 4, KeepMonthly: 12}.Select(times, now)
+	// 7 个每日（12-25 ~ 12-31，覆盖了最近两个 ISO 周）+ 12-22、12-15 两个周日 + 11 个之前月份的月末
+	if got := countKept(keep); got != 7+2+11 {
+		t.Errorf("Expected 20 kept backups, got %d", got)
+	}
+	for i := 0; i < 7; i++ {
+		if !keep[i] {
+			t.Errorf("Daily backup %d not kept", i)
+		}
+	}
+	// 每月保留的是该月最新（最后一天）的备份
+	nov30 := time.Date(2024, 11, 30, 2, 0, 0, 0, time.UTC)
+	for i, ts := range times {
+		if ts.Equal(nov30) && !keep[i] {
+			t.Error("Expected last backup of November to be kept")
+		}
+		if ts.Equal(nov30.AddDate(0, 0, -1)) && keep[i] {
+			t.Error("Expected only one backup of November to be kept")
+		}
+	}
+	if keep[len(keep)-1] {
+		t.Error("Oldest backup should have been pruned")
+	}
+}
+
+func TestSelectKeepsNewest(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	times := dailyBackups(now.AddDate(0, 0, -60), 5)
+
+	keep := Policy{KeepDays: 30}.Select(times, now)
+	if !keep[0] || countKept(keep) != 1 {
+		t.Errorf("Expected only the newest backup to survive, got %v", keep)
+	}
+
+	keep = Policy{KeepLast: 3}.Select(times, now)
+	if countKept(keep) != 3 || keep[3] {
+		t.Errorf("Expected the 3 newest backups to be kept, got %v", keep)
+	}
+
+	if keep := (Policy{}).Select(times, now); countKept(keep) != len(times) {
+		t.Errorf("Expected an empty policy to keep everything, got %v", keep)
+	}
+}
+
+func TestPlanReasons(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	times := dailyBackups(now.AddDate(0, 0, -1), 4)
+	keys := []string{"d", "c", "b", "a"}
+
+	plan := Policy{KeepLast: 1, KeepDaily: 2}.Plan(keys, times, now)
+	if plan[0].Delete || plan[0].Reason != "最新备份, keep_last, keep_daily 2024-05-31" {
+		t.Errorf("Unexpected decision for newest backup: %+v", plan[0])
+	}
+	if plan[1].Delete || plan[1].Reason != "keep_daily 2024-05-30" {
+		t.Errorf("Unexpected decision for second backup: %+v", plan[1])
+	}
+	if !plan[3].Delete || plan[3].Key != "a" || plan[3].Reason != "超出保留策略 (keep_last=1, keep_daily=2)，备份于 4 天前" {
+		t.Errorf("Unexpected decision for oldest backup: %+v", plan[3])
+	}
+	if n := CountDeletes(plan); n != 2 {
+		t.Errorf("Expected 2 deletions, got %d", n)
+	}
+}
+
+func TestCheckThreshold(t *testing.T) {
+	if err := (Options{}).CheckThreshold(5, 10); err != nil {
+		t.Errorf("Deleting 50%% should pass the default threshold: %v", err)
+	}
+	if err := (Options{}).CheckThreshold(6, 10); err == nil {
+		t.Error("Deleting 60% should exceed the default threshold")
+	}
+	if err := (Options{MaxDeletePercent: 80}).CheckThreshold(8, 10); err != nil {
+		t.Errorf("Deleting 80%% should pass an 80%% threshold: %v", err)
+	}
+	if err := (Options{MaxDeletePercent: 100}).CheckThreshold(10, 10); err != nil {
+		t.Errorf("A 100%% threshold should not limit deletions: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tencentyun/cos-go-sdk-v5"
+	"backup-go/internal/config"
+)
+
+// cosStorage 腾讯云 COS 存储
+type cosStorage struct {
+	client *cos.Client
+}
+
+// NewCOS 创建 COS 存储
+func NewCOS(cfg *config.CosConfig) (Storage, error) {
+	bu, err := url.Parse(fmt.Sprintf("https://%s.cos.%s.myqcloud.com", cfg.Bucket, cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("构造 Bucket URL 失败: %w", err)
+	}
+	su, err := url.Parse(fmt.Sprintf("https://cos.%s.myqcloud.com", cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("构造 Service URL 失败: %w", err)
+	}
+	// 加强 HTTP 连接稳健性
+	baseTransport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+	}
+	client := cos.NewClient(
+		&cos.BaseURL{BucketURL: bu, ServiceURL: su},
+		&http.Client{Transport: &cos.AuthorizationTransport{
+			SecretID:  cfg.SecretID,
+			SecretKey: cfg.SecretKey,
+			Transport: baseTransport,
+		}},
+	)
+	return &cosStorage{client: client}, nil
+}
+
+func (s *cosStorage) Put(key string, r io.Reader, size int64) error {
+	opt := &cos.ObjectPutOptions{ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{}}
+	if size >= 0 {
+		opt.ContentLength = size
+	}
+	_, err := s.client.Object.Put(context.Background(), key, r, opt)
+	return err
+}
+
+func (s *cosStorage) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.client.Object.Get(context.Background(), key, nil)
+	if err != nil {
+		if cos.IsNotFoundError(err) {
+			return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *cosStorage) List(prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	marker := ""
+	for {
+		v, _, err := s.client.Bucket.Get(context.Background(), &cos.BucketGetOptions{
+			Prefix:  prefix,
+			Marker:  marker,
+			MaxKeys: 1000,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("列举 COS 对象失败: %w", err)
+		}
+		for _, it := range v.Contents {
+			modTime, _ := time.Parse(time.RFC3339, it.LastModified)
+			objects = append(objects, ObjectInfo{Key: it.Key, Size: it.Size, ModTime: modTime, ETag: trimETag(it.ETag)})
+		}
+		if !v.IsTruncated {
+			return objects, nil
+		}
+		marker = v.NextMarker
+	}
+}
+
+func (s *cosStorage) Delete(key string) error {
+	_, err := s.client.Object.Delete(context.Background(), key)
+	if err != nil && cos.IsNotFoundError(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *cosStorage) Stat(key string) (ObjectInfo, error) {
+	resp, err := s.client.Object.Head(context.Background(), key, nil)
+	if err != nil {
+		if cos.IsNotFoundError(err) {
+			return ObjectInfo{}, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return ObjectInfo{}, err
+	}
+	size, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return ObjectInfo{
+		Key:     key,
+		Size:    size,
+		ModTime: modTime,
+		ETag:    trimETag(resp.Header.Get("ETag")),
+		CRC64:   resp.Header.Get("x-cos-hash-crc64ecma"),
+	}, nil
+}
+
+func (s *cosStorage) InitiateMultipart(key string) (string, error) {
+	res, _, err := s.client.Object.InitiateMultipartUpload(context.Background(), key, nil)
+	if err != nil {
+		return "", err
+	}
+	return res.UploadID, nil
+}
+
+func (s *cosStorage) UploadPart(key, uploadID string, number int, r io.Reader, size int64) (string, error) {
+	resp, err := s.client.Object.UploadPart(context.Background(), key, uploadID, number, r, &cos.ObjectUploadPartOptions{
+		ContentLength: size,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+func (s *cosStorage) CompleteMultipart(key, uploadID string, parts []Part) error {
+	objects := make([]cos.Object, 0, len(parts))
+	for _, p := range parts {
+		objects = append(objects, cos.Object{PartNumber: p.Number, ETag: p.ETag})
+	}
+	_, _, err := s.client.Object.CompleteMultipartUpload(context.Background(), key, uploadID,
+		&cos.CompleteMultipartUploadOptions{Parts: objects})
+	return err
+}
+
+func (s *cosStorage) AbortMultipart(key, uploadID string) error {
+	_, err := s.client.Object.AbortMultipartUpload(context.Background(), key, uploadID)
+	if err != nil && cos.IsNotFoundError(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *cosStorage) ListParts(key, uploadID string) ([]Part, error) {
+	var parts []Part
+	marker := ""
+	for {
+		res, _, err := s.client.Object.ListParts(context.Background(), key, uploadID, &cos.ObjectListPartsOptions{
+			MaxParts:         "1000",
+			PartNumberMarker: marker,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range res.Parts {
+			parts = append(parts, Part{Number: p.PartNumber, ETag: p.ETag})
+		}
+		if !res.IsTruncated {
+			return parts, nil
+		}
+		marker = res.NextPartNumberMarker
+	}
+}
+
+func (s *cosStorage) ListMultipartUploads(prefix string) ([]MultipartUpload, error) {
+	var uploads []MultipartUpload
+	keyMarker, uploadIDMarker := "", ""
+	for {
+		res, _, err := s.client.Bucket.ListMultipartUploads(context.Background(), &cos.ListMultipartUploadsOptions{
+			Prefix:         prefix,
+			KeyMarker:      keyMarker,
+			UploadIDMarker: uploadIDMarker,
+			MaxUploads:     1000,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range res.Uploads {
+			initiated, _ := time.Parse(time.RFC3339, u.Initiated)
+			uploads = append(uploads, MultipartUpload{Key: u.Key, UploadID: u.UploadID, Initiated: initiated})
+		}
+		if !res.IsTruncated {
+			return uploads, nil
+		}
+		keyMarker, uploadIDMarker = res.NextKeyMarker, res.NextUploadIDMarker
+	}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, content := range map[string]string{
+		"backup/a.tar.zst":     "aaa",
+		"backup/sub/b.tar.zst": "bbbb",
+		"other/c.tar.zst":      "c",
+	} {
+		if err := s.Put(key, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("Put %s failed: %v", key, err)
+		}
+	}
+
+	objects, err := s.List("backup/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 2 || objects[0].Key != "backup/a.tar.zst" || objects[1].Key != "backup/sub/b.tar.zst" {
+		t.Errorf("Unexpected list result: %+v", objects)
+	}
+	if objects, _ := s.List("missing/"); len(objects) != 0 {
+		t.Errorf("Expected empty list for missing prefix, got %+v", objects)
+	}
+
+	info, err := s.Stat("backup/sub/b.tar.zst")
+	if err != nil || info.Size != 4 {
+		t.Errorf("Stat = %+v, %v", info, err)
+	}
+
+	r, err := s.Get("backup/a.tar.zst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "aaa" {
+		t.Errorf("Get = %q, want %q", data, "aaa")
+	}
+
+	if err := s.Delete("backup/a.tar.zst"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("backup/a.tar.zst"); err != nil {
+		t.Errorf("Deleting a missing object should succeed: %v", err)
+	}
+	if _, err := s.Stat("backup/a.tar.zst"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if _, err := s.Get("backup/a.tar.zst"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound from Get, got %v", err)
+	}
+	if err := TestConnection(s, "backup/"); err != nil {
+		t.Errorf("TestConnection failed: %v", err)
+	}
+}
+
+func TestLocalStoragePutFailure(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 长度不符时不应留下目标文件或临时文件
+	if err := s.Put("backup/short.tar.zst", strings.NewReader("abc"), 10); err == nil {
+		t.Fatal("Expected error for short write")
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "backup"))
+	if len(entries) != 0 {
+		t.Errorf("Expected no leftover files, got %d", len(entries))
+	}
+
+	for _, key := range []string{"../escape", "backup/../../escape", "/", ""} {
+		if err := s.Put(key, strings.NewReader("x"), 1); err == nil {
+			t.Errorf("Expected error for key %q", key)
+		}
+	}
+}
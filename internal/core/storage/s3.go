@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"backup-go/internal/config"
+)
+
+// s3Storage S3 兼容存储（AWS S3、MinIO、Cloudflare R2 等）
+type s3Storage struct {
+	core   *minio.Core
+	bucket string
+}
+
+// NewS3 创建 S3 兼容存储
+func NewS3(cfg *config.StorageConfig) (Storage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 存储需要配置 storage.endpoint 和 storage.bucket")
+	}
+
+	// endpoint 可带协议前缀，未指定时默认使用 HTTPS
+	endpoint, secure := cfg.Endpoint, true
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("解析 storage.endpoint 失败: %w", err)
+		}
+		endpoint, secure = u.Host, u.Scheme != "http"
+	}
+
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	core, err := minio.NewCore(endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       secure,
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建 S3 客户端失败: %w", err)
+	}
+	return &s3Storage{core: core, bucket: cfg.Bucket}, nil
+}
+
+// s3Error 将对象或分块上传不存在的错误转换为 ErrNotFound
+func s3Error(key string, err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case minio.NoSuchKey, minio.NoSuchUpload:
+		return fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return err
+}
+
+func (s *s3Storage) Put(key string, r io.Reader, size int64) error {
+	_, err := s.core.Client.PutObject(context.Background(), s.bucket, key, r, size, minio.PutObjectOptions{})
+	return err
+}
+
+func (s *s3Storage) Get(key string) (io.ReadCloser, error) {
+	body, _, _, err := s.core.GetObject(context.Background(), s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s3Error(key, err)
+	}
+	return body, nil
+}
+
+func (s *s3Storage) List(prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for obj := range s.core.Client.ListObjects(context.Background(), s.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("列举 S3 对象失败: %w", obj.Err)
+		}
+		objects = append(objects, ObjectInfo{Key: obj.Key, Size: obj.Size, ModTime: obj.LastModified, ETag: trimETag(obj.ETag)})
+	}
+	return objects, nil
+}
+
+func (s *s3Storage) Delete(key string) error {
+	err := s.core.Client.RemoveObject(context.Background(), s.bucket, key, minio.RemoveObjectOptions{})
+	if err != nil && minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+		return nil
+	}
+	return err
+}
+
+func (s *s3Storage) Stat(key string) (ObjectInfo, error) {
+	info, err := s.core.Client.StatObject(context.Background(), s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, s3Error(key, err)
+	}
+	return ObjectInfo{Key: key, Size: info.Size, ModTime: info.LastModified, ETag: trimETag(info.ETag)}, nil
+}
+
+func (s *s3Storage) InitiateMultipart(key string) (string, error) {
+	return s.core.NewMultipartUpload(context.Background(), s.bucket, key, minio.PutObjectOptions{})
+}
+
+func (s *s3Storage) UploadPart(key, uploadID string, number int, r io.Reader, size int64) (string, error) {
+	part, err := s.core.PutObjectPart(context.Background(), s.bucket, key, uploadID, number, r, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return "", err
+	}
+	return part.ETag, nil
+}
+
+func (s *s3Storage) CompleteMultipart(key, uploadID string, parts []Part) error {
+	completed := make([]minio.CompletePart, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, minio.CompletePart{PartNumber: p.Number, ETag: p.ETag})
+	}
+	_, err := s.core.CompleteMultipartUpload(context.Background(), s.bucket, key, uploadID, completed, minio.PutObjectOptions{})
+	return err
+}
+
+func (s *s3Storage) AbortMultipart(key, uploadID string) error {
+	err := s.core.AbortMultipartUpload(context.Background(), s.bucket, key, uploadID)
+	if err != nil && minio.ToErrorResponse(err).Code == minio.NoSuchUpload {
+		return nil
+	}
+	return err
+}
+
+func (s *s3Storage) ListParts(key, uploadID string) ([]Part, error) {
+	var parts []Part
+	marker := 0
+	for {
+		res, err := s.core.ListObjectParts(context.Background(), s.bucket, key, uploadID, marker, 1000)
+		if err != nil {
+			return nil, s3Error(key, err)
+		}
+		for _, p := range res.ObjectParts {
+			parts = append(parts, Part{Number: p.PartNumber, ETag: p.ETag})
+		}
+		if !res.IsTruncated {
+			return parts, nil
+		}
+		marker = res.NextPartNumberMarker
+	}
+}
+
+func (s *s3Storage) ListMultipartUploads(prefix string) ([]MultipartUpload, error) {
+	var uploads []MultipartUpload
+	keyMarker, uploadIDMarker := "", ""
+	for {
+		res, err := s.core.ListMultipartUploads(context.Background(), s.bucket, prefix, keyMarker, uploadIDMarker, "", 1000)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range res.Uploads {
+			uploads = append(uploads, MultipartUpload{Key: u.Key, UploadID: u.UploadID, Initiated: u.Initiated})
+		}
+		if !res.IsTruncated {
+			return uploads, nil
+		}
+		keyMarker, uploadIDMarker = res.NextKeyMarker, res.NextUploadIDMarker
+	}
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"backup-go/internal/config"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("对象不存在")
+
+// ObjectInfo 存储对象信息
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+	ETag    string // 不含引号；本地存储为空
+	CRC64   string // 内容的 CRC64-ECMA（十进制），仅 COS 的 Stat 提供
+}
+
+// Storage 备份存储后端，对象键统一使用 / 分隔
+type Storage interface {
+	// Put 写入对象，size 为 -1 表示长度未知（流式写入）
+	Put(key string, r io.Reader, size int64) error
+	// Get 读取对象，对象不存在时返回 ErrNotFound
+	Get(key string) (io.ReadCloser, error)
+	// List 列出键以 prefix 开头的所有对象
+	List(prefix string) ([]ObjectInfo, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(key string) error
+	// Stat 获取对象信息，对象不存在时返回 ErrNotFound
+	Stat(key string) (ObjectInfo, error)
+}
+
+// Part 已上传的分块
+type Part struct {
+	Number int
+	ETag   string
+}
+
+// MultipartUpload 未完成的分块上传
+type MultipartUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+// Multipart 支持分块上传的存储后端（COS、S3），用于大文件续传和流式上传
+type Multipart interface {
+	InitiateMultipart(key string) (uploadID string, err error)
+	UploadPart(key, uploadID string, number int, r io.Reader, size int64) (etag string, err error)
+	CompleteMultipart(key, uploadID string, parts []Part) error
+	AbortMultipart(key, uploadID string) error
+	// ListParts 列出分块上传中服务端已有的分块
+	ListParts(key, uploadID string) ([]Part, error)
+	// ListMultipartUploads 列出前缀下未完成的分块上传
+	ListMultipartUploads(prefix string) ([]MultipartUpload, error)
+}
+
+// New 根据配置创建存储后端，未配置 [storage] 时使用 [cos]
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.Storage.Type {
+	case "", config.StorageCOS:
+		return NewCOS(&cfg.Cos)
+	case config.StorageLocal:
+		return NewLocal(cfg.Storage.Path)
+	case config.StorageS3:
+		return NewS3(&cfg.Storage)
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %q", cfg.Storage.Type)
+	}
+}
+
+// TestConnection 通过查询一个不存在的对象检查存储是否可访问、凭据是否有效
+func TestConnection(s Storage, prefix string) error {
+	_, err := s.Stat(path.Join(prefix, ".backup-go-check"))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func trimETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
@@ -0,0 +1,134 @@
+package uploader
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"backup-go/internal/core/storage"
+)
+
+// ChecksumSuffix 校验信息文件后缀，与归档存放在同一前缀下
+const ChecksumSuffix = ".sum"
+
+// Checksum 归档的校验信息
+type Checksum struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	MD5    string `json:"md5"`       // 与普通上传的 ETag 一致
+	CRC64  string `json:"crc64ecma"` // 十进制，与 COS 的 x-cos-hash-crc64ecma 一致
+}
+
+// Hasher 在写入数据的同时计算校验信息
+type Hasher struct {
+	size   int64
+	sha256 hash.Hash
+	md5    hash.Hash
+	crc64  hash.Hash64
+}
+
+// NewHasher 创建校验信息计算器
+func NewHasher() *Hasher {
+	return &Hasher{
+		sha256: sha256.New(),
+		md5:    md5.New(),
+		crc64:  crc64.New(crc64.MakeTable(crc64.ECMA)),
+	}
+}
+
+func (h *Hasher) Write(p []byte) (int, error) {
+	h.size += int64(len(p))
+	h.sha256.Write(p)
+	h.md5.Write(p)
+	h.crc64.Write(p)
+	return len(p), nil
+}
+
+// Sum 返回已写入数据的校验信息
+func (h *Hasher) Sum() Checksum {
+	return Checksum{
+		Size:   h.size,
+		SHA256: hex.EncodeToString(h.sha256.Sum(nil)),
+		MD5:    hex.EncodeToString(h.md5.Sum(nil)),
+		CRC64:  strconv.FormatUint(h.crc64.Sum64(), 10),
+	}
+}
+
+// FileChecksum 计算本地文件的校验信息
+func FileChecksum(localFile string) (Checksum, error) {
+	f, err := os.Open(localFile)
+	if err != nil {
+		return Checksum{}, fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer f.Close()
+
+	h := NewHasher()
+	if _, err := io.Copy(h, f); err != nil {
+		return Checksum{}, fmt.Errorf("读取本地文件失败: %w", err)
+	}
+	return h.Sum(), nil
+}
+
+// PutChecksum 将校验信息写入 <key>.sum
+func PutChecksum(store storage.Storage, key string, sum Checksum) error {
+	data, err := json.MarshalIndent(sum, "", "  ")
+	if err != nil {
+		return fmt.Errorf("编码校验信息失败: %w", err)
+	}
+	if err := store.Put(key+ChecksumSuffix, bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("上传校验信息失败: %w", err)
+	}
+	return nil
+}
+
+// GetChecksum 读取归档的校验信息，不存在时返回 storage.ErrNotFound
+func GetChecksum(store storage.Storage, key string) (Checksum, error) {
+	r, err := store.Get(key + ChecksumSuffix)
+	if err != nil {
+		return Checksum{}, err
+	}
+	defer r.Close()
+
+	var sum Checksum
+	if err := json.NewDecoder(r).Decode(&sum); err != nil {
+		return Checksum{}, fmt.Errorf("解析校验信息失败: %w", err)
+	}
+	return sum, nil
+}
+
+// CheckRemote 比对远端对象的大小、ETag 与 CRC64 是否与校验信息一致
+// 分块上传的 ETag 不是内容的 MD5，此时只比较大小和 CRC64（存储提供时）
+func CheckRemote(store storage.Storage, key string, sum Checksum) error {
+	info, err := store.Stat(key)
+	if err != nil {
+		return err
+	}
+	if info.Size != sum.Size {
+		return fmt.Errorf("远端对象大小不一致: 期望 %d 字节，实际 %d 字节", sum.Size, info.Size)
+	}
+	if isMD5ETag(info.ETag) && sum.MD5 != "" && !strings.EqualFold(info.ETag, sum.MD5) {
+		return fmt.Errorf("远端对象 ETag 不一致: 期望 %s，实际 %s", sum.MD5, info.ETag)
+	}
+	if info.CRC64 != "" && sum.CRC64 != "" && info.CRC64 != sum.CRC64 {
+		return fmt.Errorf("远端对象 CRC64 不一致: 期望 %s，实际 %s", sum.CRC64, info.CRC64)
+	}
+	return nil
+}
+
+// isMD5ETag 普通上传的 ETag 为 32 位十六进制 MD5，分块上传的 ETag 带有 "-分块数" 后缀
+func isMD5ETag(etag string) bool {
+	if len(etag) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(etag)
+	return err == nil
+}
@@ -0,0 +1,65 @@
+package uploader
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"backup-go/internal/core/storage"
+	"backup-go/internal/logger"
+)
+
+// Download 从存储下载对象到本地文件
+func Download(store storage.Storage, key, localFile string) error {
+	logger.PrintLog("download", fmt.Sprintf("开始下载文件: %s → %s", key, localFile))
+	interval := progressInterval()
+
+	var lastErr error
+	for attempt := 1; attempt <= 3; attempt++ {
+		err := downloadOnce(store, key, localFile, interval)
+		if err == nil {
+			logger.PrintLog("download", "文件下载完成")
+			return nil
+		}
+
+		lastErr = err
+		if attempt < 3 {
+			backoff := time.Duration(1<<uint(attempt-1)) * 500 * time.Millisecond
+			logger.PrintLog("warn", fmt.Sprintf("下载失败，准备重试（第 %d/3 次，%s 后重试）：%v", attempt, backoff, err))
+			time.Sleep(backoff)
+		}
+	}
+	_ = os.Remove(localFile)
+	return fmt.Errorf("下载文件失败（已重试 3 次）：%w", lastErr)
+}
+
+func downloadOnce(store storage.Storage, key, localFile string, interval time.Duration) error {
+	info, err := store.Stat(key)
+	if err != nil {
+		return err
+	}
+	body, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	f, err := os.Create(localFile)
+	if err != nil {
+		return fmt.Errorf("创建本地文件失败: %w", err)
+	}
+
+	pr := newProgressReader(body, info.Size, interval, downloadLimiter, progressLogger("download"))
+	n, err := io.Copy(f, pr)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if n != info.Size {
+		return fmt.Errorf("下载内容不完整: 期望 %d 字节，实际 %d 字节", info.Size, n)
+	}
+	return nil
+}
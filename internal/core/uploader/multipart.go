@@ -0,0 +1,250 @@
+package uploader
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/logger"
+)
+
+const (
+	// DefaultMultipartThreshold 超过该大小的文件使用分块上传
+	DefaultMultipartThreshold = 64 * 1024 * 1024
+	// StateFileSuffix 分块上传状态文件后缀，与本地归档放在同一目录
+	StateFileSuffix = ".upload.json"
+)
+
+// UploadOptions 上传选项
+type UploadOptions struct {
+	PartSize           int64 // 分块大小，默认 DefaultPartSize
+	MultipartThreshold int64 // 分块上传阈值，默认 DefaultMultipartThreshold
+}
+
+func (o UploadOptions) normalize() UploadOptions {
+	if o.PartSize < MinPartSize {
+		o.PartSize = DefaultPartSize
+	}
+	if o.MultipartThreshold <= 0 {
+		o.MultipartThreshold = DefaultMultipartThreshold
+	}
+	return o
+}
+
+// UploadState 分块上传的本地持久化状态，进程中断后据此续传
+type UploadState struct {
+	Key      string         `json:"key"`
+	UploadID string         `json:"upload_id"`
+	FileSize int64          `json:"file_size"`
+	ModTime  time.Time      `json:"mod_time"`
+	PartSize int64          `json:"part_size"`
+	Parts    []UploadedPart `json:"parts"`
+}
+
+// UploadedPart 已完成的分块
+type UploadedPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// StatePath 返回本地文件对应的上传状态文件路径
+func StatePath(localFile string) string {
+	return localFile + StateFileSuffix
+}
+
+// LoadUploadState 读取上传状态文件
+func LoadUploadState(statePath string) (*UploadState, error) {
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return nil, err
+	}
+	var state UploadState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("解析上传状态文件失败: %w", err)
+	}
+	return &state, nil
+}
+
+// ResetUploadState 为本地文件写入不含分块的上传状态，下次续传时重新上传整个文件
+// 用于上传完成后校验失败的归档：保留在临时目录中等待重传
+func ResetUploadState(localFile, key string) error {
+	fi, err := os.Stat(localFile)
+	if err != nil {
+		return err
+	}
+	state := &UploadState{Key: key, FileSize: fi.Size(), ModTime: fi.ModTime()}
+	return state.save(StatePath(localFile))
+}
+
+// save 原子地写入状态文件
+func (s *UploadState) save(statePath string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("编码上传状态失败: %w", err)
+	}
+	tmp := statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入上传状态文件失败: %w", err)
+	}
+	return os.Rename(tmp, statePath)
+}
+
+// uploadMultipart 以可续传的分块方式上传本地文件
+func uploadMultipart(mp storage.Multipart, localFile, key string, fi os.FileInfo, opts UploadOptions, interval time.Duration) error {
+	statePath := StatePath(localFile)
+	state := resumeState(mp, statePath, key, fi)
+	partSize := opts.PartSize
+	if state != nil {
+		partSize = state.PartSize
+	}
+	// 在发起上传前检查分块数，避免在存储上留下无法完成的上传
+	totalParts := int((fi.Size() + partSize - 1) / partSize)
+	if totalParts > MaxParts {
+		if state != nil {
+			AbortUpload(mp, state.Key, state.UploadID)
+			_ = os.Remove(statePath)
+		}
+		return fmt.Errorf("分块数 %d 超过上限 %d，请增大 part_size_mb", totalParts, MaxParts)
+	}
+	if state == nil {
+		uploadID, err := mp.InitiateMultipart(key)
+		if err != nil {
+			return fmt.Errorf("初始化分块上传失败: %w", err)
+		}
+		state = &UploadState{
+			Key:      key,
+			UploadID: uploadID,
+			FileSize: fi.Size(),
+			ModTime:  fi.ModTime(),
+			PartSize: opts.PartSize,
+		}
+		if err := state.save(statePath); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(localFile)
+	if err != nil {
+		return fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer f.Close()
+
+	done := make(map[int]bool, len(state.Parts))
+	for _, p := range state.Parts {
+		done[p.Number] = true
+	}
+	logger.PrintLog("upload", fmt.Sprintf("分块上传: 共 %d 块（每块 %s），已完成 %d 块",
+		totalParts, humanize.IBytes(uint64(state.PartSize)), len(done)))
+
+	buf := make([]byte, state.PartSize)
+	for partNumber := 1; partNumber <= totalParts; partNumber++ {
+		if done[partNumber] {
+			continue
+		}
+		offset := int64(partNumber-1) * state.PartSize
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("读取本地文件失败: %w", err)
+		}
+
+		etag, err := uploadPartWithRetry(mp, key, state.UploadID, partNumber, buf[:n], interval)
+		if err != nil {
+			return err
+		}
+		state.Parts = append(state.Parts, UploadedPart{Number: partNumber, ETag: etag})
+		if err := state.save(statePath); err != nil {
+			return err
+		}
+		logger.PrintLog("upload", fmt.Sprintf("分块 %d/%d 上传完成 (%d%%)", partNumber, totalParts, len(state.Parts)*100/totalParts))
+	}
+
+	sort.Slice(state.Parts, func(i, j int) bool { return state.Parts[i].Number < state.Parts[j].Number })
+	parts := make([]storage.Part, 0, len(state.Parts))
+	for _, p := range state.Parts {
+		parts = append(parts, storage.Part{Number: p.Number, ETag: p.ETag})
+	}
+	if err := mp.CompleteMultipart(key, state.UploadID, parts); err != nil {
+		return fmt.Errorf("完成分块上传失败: %w", err)
+	}
+	_ = os.Remove(statePath)
+	return nil
+}
+
+// resumeState 加载可续传的状态，并以服务端已有的分块为准校正；无法续传时返回 nil
+func resumeState(mp storage.Multipart, statePath, key string, fi os.FileInfo) *UploadState {
+	state, err := LoadUploadState(statePath)
+	if err != nil || state.UploadID == "" {
+		return nil
+	}
+	if state.Key != key || state.FileSize != fi.Size() || !state.ModTime.Equal(fi.ModTime()) || state.PartSize < MinPartSize {
+		logger.PrintLog("warn", "本地文件与上传状态不一致，重新开始分块上传")
+		AbortUpload(mp, state.Key, state.UploadID)
+		return nil
+	}
+
+	remoteParts, err := mp.ListParts(key, state.UploadID)
+	if err != nil {
+		logger.PrintLog("warn", fmt.Sprintf("查询已上传分块失败，重新开始分块上传: %v", err))
+		AbortUpload(mp, state.Key, state.UploadID)
+		return nil
+	}
+	remote := make(map[int]string, len(remoteParts))
+	for _, p := range remoteParts {
+		remote[p.Number] = p.ETag
+	}
+	var parts []UploadedPart
+	for _, p := range state.Parts {
+		if remote[p.Number] == p.ETag {
+			parts = append(parts, p)
+		}
+	}
+	state.Parts = parts
+	logger.PrintLog("upload", fmt.Sprintf("发现未完成的分块上传 %s，已上传 %d 块，继续续传", state.UploadID, len(parts)))
+	return state
+}
+
+// AbortUpload 中止分块上传并丢弃已上传的分块
+func AbortUpload(mp storage.Multipart, key, uploadID string) {
+	if err := mp.AbortMultipart(key, uploadID); err != nil {
+		logger.PrintLog("warn", fmt.Sprintf("中止分块上传失败: %s (%s): %v", key, uploadID, err))
+	}
+}
+
+// PendingUploads 查找 dir 下各任务临时目录中留存的上传状态文件
+func PendingUploads(dir string) ([]string, error) {
+	return filepath.Glob(filepath.Join(dir, "*", "*"+StateFileSuffix))
+}
+
+// AbortStaleUploads 中止前缀下发起时间早于 maxAge 且不在 keep 中的未完成分块上传
+// 存储不支持分块上传时直接返回
+func AbortStaleUploads(store storage.Storage, prefix string, maxAge time.Duration, keep map[string]bool) error {
+	mp, ok := store.(storage.Multipart)
+	if !ok {
+		return nil
+	}
+	uploads, err := mp.ListMultipartUploads(prefix)
+	if err != nil {
+		return fmt.Errorf("列举未完成的分块上传失败: %w", err)
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	var aborted int
+	for _, u := range uploads {
+		if keep[u.UploadID] || u.Initiated.IsZero() || u.Initiated.After(cutoff) {
+			continue
+		}
+		AbortUpload(mp, u.Key, u.UploadID)
+		aborted++
+		logger.PrintLog("cleanup", fmt.Sprintf("已中止过期的分块上传: %s (%s，发起于 %s)", u.Key, u.UploadID, u.Initiated.Format("2006-01-02 15:04:05")))
+	}
+	if aborted > 0 {
+		logger.PrintLog("cleanup", fmt.Sprintf("共中止 %d 个过期的分块上传", aborted))
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package uploader
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"backup-go/internal/core/storage"
+)
+
+// fakeMultipart 在本地存储之上模拟分块上传，记录每个分块的上传次数与被中止的上传
+type fakeMultipart struct {
+	storage.Storage
+	mu        sync.Mutex
+	uploads   map[string]map[int][]byte
+	initiated int
+	aborted   []string
+	uploaded  map[int]int // 分块编号 → 上传次数
+	failPart  int         // 大于 0 时上传该编号的分块会失败
+	listErr   error       // ListParts 返回的错误
+}
+
+func newFakeMultipart(t *testing.T) *fakeMultipart {
+	local, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeMultipart{Storage: local, uploads: make(map[string]map[int][]byte), uploaded: make(map[int]int)}
+}
+
+func (f *fakeMultipart) InitiateMultipart(key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.initiated++
+	id := fmt.Sprintf("upload-%d", f.initiated)
+	f.uploads[id] = make(map[int][]byte)
+	return id, nil
+}
+
+func (f *fakeMultipart) UploadPart(key, uploadID string, number int, r io.Reader, size int64) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if number == f.failPart {
+		return "", errors.New("connection reset")
+	}
+	parts, ok := f.uploads[uploadID]
+	if !ok {
+		return "", errors.New("NoSuchUpload")
+	}
+	parts[number] = data
+	f.uploaded[number]++
+	return etag(data), nil
+}
+
+func (f *fakeMultipart) CompleteMultipart(key, uploadID string, parts []storage.Part) error {
+	f.mu.Lock()
+	uploaded, ok := f.uploads[uploadID]
+	delete(f.uploads, uploadID)
+	f.mu.Unlock()
+	if !ok {
+		return errors.New("NoSuchUpload")
+	}
+	var buf bytes.Buffer
+	for _, p := range parts {
+		data, ok := uploaded[p.Number]
+		if !ok || etag(data) != p.ETag {
+			return fmt.Errorf("InvalidPart: %d", p.Number)
+		}
+		buf.Write(data)
+	}
+	return f.Put(key, &buf, int64(buf.Len()))
+}
+
+func (f *fakeMultipart) AbortMultipart(key, uploadID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.uploads, uploadID)
+	f.aborted = append(f.aborted, uploadID)
+	return nil
+}
+
+func (f *fakeMultipart) ListParts(key, uploadID string) ([]storage.Part, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	var parts []storage.Part
+	for n, data := range f.uploads[uploadID] {
+		parts = append(parts, storage.Part{Number: n, ETag: etag(data)})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+func (f *fakeMultipart) ListMultipartUploads(prefix string) ([]storage.MultipartUpload, error) {
+	return nil, nil
+}
+
+// pending 返回尚未完成或中止的上传数
+func (f *fakeMultipart) pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.uploads)
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func randomData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(rand.IntN(256))
+	}
+	return data
+}
+
+// readObject 读取存储中的对象内容
+func readObject(t *testing.T, store storage.Storage, key string) []byte {
+	t.Helper()
+	rc, err := store.Get(key)
+	if err != nil {
+		t.Fatalf("Get %s failed: %v", key, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+var testOptions = UploadOptions{PartSize: MinPartSize, MultipartThreshold: MinPartSize}
+
+// interruptedUpload 写入 2.5 个分块大小的本地文件，并模拟只上传了第一个分块后中断的分块上传
+func interruptedUpload(t *testing.T, store *fakeMultipart, key string) (string, []byte) {
+	t.Helper()
+	data := randomData(MinPartSize * 5 / 2)
+	localFile := filepath.Join(t.TempDir(), "backup.tar.zst")
+	if err := os.WriteFile(localFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(localFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploadID, err := store.InitiateMultipart(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := store.UploadPart(key, uploadID, 1, bytes.NewReader(data[:MinPartSize]), MinPartSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &UploadState{
+		Key:      key,
+		UploadID: uploadID,
+		FileSize: fi.Size(),
+		ModTime:  fi.ModTime(),
+		PartSize: MinPartSize,
+		Parts:    []UploadedPart{{Number: 1, ETag: tag}},
+	}
+	if err := state.save(StatePath(localFile)); err != nil {
+		t.Fatal(err)
+	}
+	return localFile, data
+}
+
+func TestUploadMultipart(t *testing.T) {
+	store := newFakeMultipart(t)
+	data := randomData(MinPartSize * 5 / 2)
+	localFile := filepath.Join(t.TempDir(), "backup.tar.zst")
+	if err := os.WriteFile(localFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Upload(store, localFile, "backup/a.tar.zst", testOptions); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, store, "backup/a.tar.zst"); !bytes.Equal(got, data) {
+		t.Error("Uploaded object does not match the local file")
+	}
+	if store.initiated != 1 || len(store.uploaded) != 3 {
+		t.Errorf("Expected one multipart upload with 3 parts, got %d uploads, parts %v", store.initiated, store.uploaded)
+	}
+	if _, err := os.Stat(StatePath(localFile)); !os.IsNotExist(err) {
+		t.Errorf("State file should be removed after completing, got %v", err)
+	}
+}
+
+func TestUploadMultipartResume(t *testing.T) {
+	store := newFakeMultipart(t)
+	localFile, data := interruptedUpload(t, store, "backup/a.tar.zst")
+
+	if err := Upload(store, localFile, "backup/a.tar.zst", testOptions); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, store, "backup/a.tar.zst"); !bytes.Equal(got, data) {
+		t.Error("Uploaded object does not match the local file")
+	}
+	if store.initiated != 1 {
+		t.Errorf("Resume should reuse the saved upload, got %d uploads", store.initiated)
+	}
+	if store.uploaded[1] != 1 || store.uploaded[2] != 1 || store.uploaded[3] != 1 {
+		t.Errorf("Only the missing parts should be uploaded again, got %v", store.uploaded)
+	}
+	if _, err := os.Stat(StatePath(localFile)); !os.IsNotExist(err) {
+		t.Errorf("State file should be removed after completing, got %v", err)
+	}
+}
+
+func TestUploadMultipartInterrupted(t *testing.T) {
+	store := newFakeMultipart(t)
+	store.failPart = 2
+	data := randomData(MinPartSize * 3)
+	localFile := filepath.Join(t.TempDir(), "backup.tar.zst")
+	if err := os.WriteFile(localFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Upload(store, localFile, "backup/a.tar.zst", testOptions); err == nil {
+		t.Fatal("Expected the upload to fail")
+	}
+	state, err := LoadUploadState(StatePath(localFile))
+	if err != nil {
+		t.Fatalf("State file should be kept for resuming: %v", err)
+	}
+	if len(state.Parts) != 1 || state.Parts[0].Number != 1 {
+		t.Errorf("Expected part 1 in the saved state, got %+v", state.Parts)
+	}
+	if store.pending() != 1 {
+		t.Errorf("Interrupted upload should not be aborted, got %d pending", store.pending())
+	}
+}
+
+func TestResumeStateDropsMissingParts(t *testing.T) {
+	store := newFakeMultipart(t)
+	localFile, _ := interruptedUpload(t, store, "backup/a.tar.zst")
+	state, err := LoadUploadState(StatePath(localFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 状态文件中记录了服务端没有的分块，以及 ETag 与服务端不一致的分块
+	state.Parts = append(state.Parts, UploadedPart{Number: 2, ETag: "missing"})
+	store.uploads[state.UploadID][3] = []byte("other")
+	state.Parts = append(state.Parts, UploadedPart{Number: 3, ETag: "stale"})
+	if err := state.save(StatePath(localFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(localFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := resumeState(store, StatePath(localFile), "backup/a.tar.zst", fi)
+	if resumed == nil {
+		t.Fatal("Expected the upload to be resumable")
+	}
+	if len(resumed.Parts) != 1 || resumed.Parts[0].Number != 1 {
+		t.Errorf("Expected only part 1 to be kept, got %+v", resumed.Parts)
+	}
+}
+
+func TestResumeStateMismatch(t *testing.T) {
+	for name, change := range map[string]func(t *testing.T, localFile string){
+		"mtime": func(t *testing.T, localFile string) {
+			if err := os.Chtimes(localFile, time.Now(), time.Now().Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"size": func(t *testing.T, localFile string) {
+			f, err := os.OpenFile(localFile, os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.Write([]byte("more")); err != nil {
+				t.Fatal(err)
+			}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := newFakeMultipart(t)
+			localFile, _ := interruptedUpload(t, store, "backup/a.tar.zst")
+			change(t, localFile)
+			data, err := os.ReadFile(localFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Upload(store, localFile, "backup/a.tar.zst", testOptions); err != nil {
+				t.Fatal(err)
+			}
+			if len(store.aborted) != 1 || store.aborted[0] != "upload-1" {
+				t.Errorf("Stale upload should be aborted, got %v", store.aborted)
+			}
+			if store.initiated != 2 || store.uploaded[1] != 2 {
+				t.Errorf("Expected a new upload from part 1, got %d uploads, parts %v", store.initiated, store.uploaded)
+			}
+			if got := readObject(t, store, "backup/a.tar.zst"); !bytes.Equal(got, data) {
+				t.Error("Uploaded object does not match the changed local file")
+			}
+		})
+	}
+}
+
+func TestResumeStateListPartsError(t *testing.T) {
+	store := newFakeMultipart(t)
+	localFile, _ := interruptedUpload(t, store, "backup/a.tar.zst")
+	store.listErr = errors.New("service unavailable")
+
+	fi, err := os.Stat(localFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := resumeState(store, StatePath(localFile), "backup/a.tar.zst", fi); state != nil {
+		t.Fatalf("Expected no resumable state, got %+v", state)
+	}
+	if len(store.aborted) != 1 || store.pending() != 0 {
+		t.Errorf("Upload that cannot be resumed should be aborted, aborted %v, pending %d", store.aborted, store.pending())
+	}
+}
+
+// sizedFileInfo 替换文件大小的 FileInfo，用于模拟超大文件
+type sizedFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (f sizedFileInfo) Size() int64 { return f.size }
+
+func TestUploadMultipartMaxParts(t *testing.T) {
+	store := newFakeMultipart(t)
+	localFile := filepath.Join(t.TempDir(), "backup.tar.zst")
+	if err := os.WriteFile(localFile, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(localFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi = sizedFileInfo{FileInfo: fi, size: int64(MaxParts+1) * MinPartSize}
+	err = uploadMultipart(store, localFile, "backup/a.tar.zst", fi, testOptions, time.Second)
+	if err == nil {
+		t.Fatal("Expected an error when exceeding MaxParts")
+	}
+	if store.initiated != 0 || len(store.uploaded) != 0 {
+		t.Errorf("No upload should be started, got %d uploads, parts %v", store.initiated, store.uploaded)
+	}
+}
@@ -0,0 +1,119 @@
+package uploader
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"backup-go/internal/core/retention"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/logger"
+	"backup-go/internal/metrics"
+)
+
+// PlanPrune 按保留策略生成清理计划，backups 按时间从新到旧排序
+// 增量链中只要有一个备份被保留，链上更早的备份（直到完整备份）都会保留
+func PlanPrune(backups []BackupObject, policy retention.Policy, now time.Time) []retention.Decision {
+	keys := make([]string, len(backups))
+	times := make([]time.Time, len(backups))
+	for i, b := range backups {
+		keys[i] = b.Key
+		times[i] = b.Time
+	}
+	plan := policy.Plan(keys, times, now)
+
+	// 从新到旧遍历，记录当前链上最早被保留的增量备份
+	dependent := ""
+	for i, b := range backups {
+		if plan[i].Delete && dependent != "" {
+			plan[i].Delete = false
+			plan[i].Reason = "被保留的增量备份 " + dependent + " 依赖"
+		}
+		switch {
+		case !b.Incremental:
+			dependent = ""
+		case !plan[i].Delete:
+			dependent = b.Key
+		}
+	}
+	return plan
+}
+
+// DeleteExpiredBackups 删除保留策略之外的备份
+// 待删除数量超过 opts 中的安全阈值时不删除任何备份并返回错误；DryRun 时只输出清理计划
+func DeleteExpiredBackups(store storage.Storage, prefix string, policy retention.Policy, opts retention.Options) error {
+	if !policy.Enabled() {
+		logger.PrintLog("info", "未配置保留天数或分级保留规则，跳过过期文件清理")
+		return nil
+	}
+	logger.PrintLog("cleanup", "开始清理过期备份文件...")
+	logger.PrintLog("cleanup", "保留策略: "+policy.String())
+
+	backups, err := ListBackups(store, prefix)
+	if err != nil {
+		return err
+	}
+	plan := PlanPrune(backups, policy, time.Now())
+	toDelete := retention.CountDeletes(plan)
+
+	if opts.DryRun {
+		for _, d := range plan {
+			action := "保留"
+			if d.Delete {
+				action = "删除"
+			}
+			logger.PrintLog("dry-run", fmt.Sprintf("%s %s: %s", action, d.Key, d.Reason))
+		}
+		logger.PrintLog("dry-run", fmt.Sprintf("匹配备份 %d 个，将删除 %d 个（未实际删除）", len(plan), toDelete))
+		return opts.CheckThreshold(toDelete, len(plan))
+	}
+	if err := opts.CheckThreshold(toDelete, len(plan)); err != nil {
+		return err
+	}
+
+	const workers = 5
+	tasks := make(chan retention.Decision, 256)
+
+	var wg sync.WaitGroup
+	var deleted, failed int64
+	var mu sync.Mutex
+
+	workerFn := func() {
+		defer wg.Done()
+		for d := range tasks {
+			if err := store.Delete(d.Key); err != nil {
+				logger.PrintLog("error", fmt.Sprintf("删除对象失败: %s: %v", d.Key, err))
+				mu.Lock()
+				failed++
+				mu.Unlock()
+				continue
+			}
+			// 同时删除归档旁边的附属文件
+			for _, suffix := range sidecarSuffixes {
+				if err := store.Delete(d.Key + suffix); err != nil {
+					logger.PrintLog("warn", fmt.Sprintf("删除对象失败: %s: %v", d.Key+suffix, err))
+				}
+			}
+			mu.Lock()
+			deleted++
+			mu.Unlock()
+			metrics.RetentionDeleted.Inc()
+			logger.PrintLog("cleanup", fmt.Sprintf("已删除: %s (%s)", d.Key, d.Reason))
+		}
+	}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go workerFn()
+	}
+	for _, d := range plan {
+		if d.Delete {
+			tasks <- d
+		}
+	}
+	close(tasks)
+	wg.Wait()
+
+	logger.PrintLog("cleanup", fmt.Sprintf("匹配备份 %d 个，待删除 %d 个；实际删除 %d 个，失败 %d 个",
+		len(plan), toDelete, deleted, failed))
+	return nil
+}
@@ -0,0 +1,186 @@
+package uploader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/logger"
+	"backup-go/internal/metrics"
+)
+
+const (
+	// DefaultPartSize 默认分块大小
+	DefaultPartSize = 32 * 1024 * 1024
+	// MinPartSize 除最后一块外的分块大小下限，取各后端中最严格的 S3 要求（5MB）
+	MinPartSize = 5 * 1024 * 1024
+	// MaxParts 单次分块上传的最大分块数
+	MaxParts = 10000
+)
+
+// UploadStream 将数据流以分块上传的方式写入存储，内存中同时只缓存一个分块
+// 数据不足一个分块时退化为普通上传；每个分块独立重试，失败时中止分块上传
+// 不支持分块上传的存储（如本地目录）直接流式写入
+func UploadStream(store storage.Storage, r io.Reader, key string, partSize int64) (int64, error) {
+	if partSize < MinPartSize {
+		partSize = DefaultPartSize
+	}
+	logger.PrintLog("upload", fmt.Sprintf("开始流式上传: → %s (分块大小 %s)", key, humanize.IBytes(uint64(partSize))))
+	interval := progressInterval()
+	buf := make([]byte, partSize)
+
+	// 先读满第一个分块，数据源出错（如源目录为空）时不会在存储上留下未完成的上传
+	n, eof, err := readPart(r, buf)
+	if err != nil {
+		return 0, err
+	}
+	if eof {
+		if err := putWithRetry(store, key, buf[:n], interval); err != nil {
+			return 0, err
+		}
+		logger.PrintLog("upload", fmt.Sprintf("文件上传完成 (%s)", humanize.Bytes(uint64(n))))
+		return int64(n), nil
+	}
+
+	mp, ok := store.(storage.Multipart)
+	if !ok {
+		cr := &countingReader{r: io.MultiReader(bytes.NewReader(buf[:n]), r)}
+		if err := store.Put(key, cr, -1); err != nil {
+			return 0, fmt.Errorf("写入数据流失败: %w", err)
+		}
+		logger.PrintLog("upload", fmt.Sprintf("文件上传完成 (%s)", humanize.Bytes(uint64(cr.n))))
+		return cr.n, nil
+	}
+
+	uploadID, err := mp.InitiateMultipart(key)
+	if err != nil {
+		return 0, fmt.Errorf("初始化分块上传失败: %w", err)
+	}
+
+	var parts []storage.Part
+	var total int64
+	abort := func(cause error) (int64, error) {
+		if err := mp.AbortMultipart(key, uploadID); err != nil {
+			logger.PrintLog("warn", fmt.Sprintf("中止分块上传失败: %s: %v", uploadID, err))
+		}
+		return 0, cause
+	}
+
+	for partNumber := 1; ; partNumber++ {
+		if partNumber > MaxParts {
+			return abort(fmt.Errorf("分块数超过上限 %d，请增大 part_size_mb", MaxParts))
+		}
+		etag, err := uploadPartWithRetry(mp, key, uploadID, partNumber, buf[:n], interval)
+		if err != nil {
+			return abort(err)
+		}
+		parts = append(parts, storage.Part{Number: partNumber, ETag: etag})
+		total += int64(n)
+		logger.PrintLog("upload", fmt.Sprintf("分块 %d 上传完成，累计 %s", partNumber, humanize.Bytes(uint64(total))))
+
+		if eof {
+			break
+		}
+		if n, eof, err = readPart(r, buf); err != nil {
+			return abort(err)
+		}
+		if n == 0 && eof {
+			break
+		}
+	}
+
+	if err := mp.CompleteMultipart(key, uploadID, parts); err != nil {
+		return abort(fmt.Errorf("完成分块上传失败: %w", err))
+	}
+	logger.PrintLog("upload", fmt.Sprintf("文件上传完成 (%d 个分块，%s)", len(parts), humanize.Bytes(uint64(total))))
+	return total, nil
+}
+
+// countingReader 统计读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readPart 读满一个分块，eof 表示数据流已经结束
+func readPart(r io.Reader, buf []byte) (int, bool, error) {
+	n, err := io.ReadFull(r, buf)
+	switch {
+	case err == nil:
+		return n, false, nil
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return n, true, nil
+	default:
+		return n, false, fmt.Errorf("读取数据流失败: %w", err)
+	}
+}
+
+// uploadPartWithRetry 上传单个分块，失败时按指数退避重试
+func uploadPartWithRetry(mp storage.Multipart, key, uploadID string, partNumber int, data []byte, interval time.Duration) (string, error) {
+	var lastErr error
+	for attempt := 1; attempt <= 3; attempt++ {
+		pr := newProgressReader(bytes.NewReader(data), int64(len(data)), interval, uploadLimiter, partProgressLogger(partNumber))
+		etag, err := mp.UploadPart(key, uploadID, partNumber, pr, int64(len(data)))
+		if err == nil {
+			return etag, nil
+		}
+
+		lastErr = err
+		if attempt < 3 {
+			backoff := time.Duration(1<<uint(attempt-1)) * 500 * time.Millisecond
+			logger.PrintLog("warn", fmt.Sprintf("分块 %d 上传失败，准备重试（第 %d/3 次，%s 后重试）：%v", partNumber, attempt, backoff, err))
+			metrics.UploadRetries.Inc()
+			time.Sleep(backoff)
+		}
+	}
+	return "", fmt.Errorf("上传分块 %d 失败（已重试 3 次）：%w", partNumber, lastErr)
+}
+
+// putWithRetry 以普通上传方式写入内存中的数据
+func putWithRetry(store storage.Storage, key string, data []byte, interval time.Duration) error {
+	var lastErr error
+	for attempt := 1; attempt <= 3; attempt++ {
+		pr := newProgressReader(bytes.NewReader(data), int64(len(data)), interval, uploadLimiter, progressLogger("upload"))
+		err := store.Put(key, pr, int64(len(data)))
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		if attempt < 3 {
+			backoff := time.Duration(1<<uint(attempt-1)) * 500 * time.Millisecond
+			logger.PrintLog("warn", fmt.Sprintf("上传失败，准备重试（第 %d/3 次，%s 后重试）：%v", attempt, backoff, err))
+			metrics.UploadRetries.Inc()
+			time.Sleep(backoff)
+		}
+	}
+	return fmt.Errorf("上传文件失败（已重试 3 次）：%w", lastErr)
+}
+
+// partProgressLogger 打印单个分块的上传进度
+func partProgressLogger(partNumber int) func(read, total, rate int64, eta time.Duration) {
+	return func(read, total, rate int64, eta time.Duration) {
+		percent := 0
+		if total > 0 {
+			percent = int(float64(read) / float64(total) * 100)
+		}
+		logger.PrintLog("upload", fmt.Sprintf("分块 %d 进度: %3d%% (%s/%s) %s/s ETA %s",
+			partNumber,
+			percent,
+			humanize.Bytes(uint64(read)),
+			humanize.Bytes(uint64(total)),
+			humanize.Bytes(uint64(rate)),
+			friendlyDuration(eta),
+		))
+	}
+}
@@ -0,0 +1,112 @@
+package uploader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"backup-go/internal/core/storage"
+)
+
+func TestUploadStreamSinglePart(t *testing.T) {
+	store := newFakeMultipart(t)
+	data := []byte("small archive")
+
+	n, err := UploadStream(store, bytes.NewReader(data), "backup/a.tar.zst", MinPartSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("Expected %d bytes, got %d", len(data), n)
+	}
+	if store.initiated != 0 {
+		t.Errorf("Data smaller than a part should not use multipart upload, got %d uploads", store.initiated)
+	}
+	if got := readObject(t, store, "backup/a.tar.zst"); !bytes.Equal(got, data) {
+		t.Errorf("Unexpected object content: %q", got)
+	}
+}
+
+func TestUploadStreamMultipart(t *testing.T) {
+	store := newFakeMultipart(t)
+	data := randomData(MinPartSize * 5 / 2)
+
+	n, err := UploadStream(store, bytes.NewReader(data), "backup/a.tar.zst", MinPartSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("Expected %d bytes, got %d", len(data), n)
+	}
+	if store.initiated != 1 || len(store.uploaded) != 3 {
+		t.Errorf("Expected one multipart upload with 3 parts, got %d uploads, parts %v", store.initiated, store.uploaded)
+	}
+	if got := readObject(t, store, "backup/a.tar.zst"); !bytes.Equal(got, data) {
+		t.Error("Uploaded object does not match the stream")
+	}
+}
+
+func TestUploadStreamExactParts(t *testing.T) {
+	store := newFakeMultipart(t)
+	data := randomData(MinPartSize * 2)
+
+	if _, err := UploadStream(store, bytes.NewReader(data), "backup/a.tar.zst", MinPartSize); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.uploaded) != 2 {
+		t.Errorf("Stream ending on a part boundary should not upload an empty part, got %v", store.uploaded)
+	}
+	if got := readObject(t, store, "backup/a.tar.zst"); !bytes.Equal(got, data) {
+		t.Error("Uploaded object does not match the stream")
+	}
+}
+
+func TestUploadStreamSourceError(t *testing.T) {
+	store := newFakeMultipart(t)
+	errSource := errors.New("archive failed")
+	r := io.MultiReader(bytes.NewReader(randomData(MinPartSize*3/2)), &errReader{err: errSource})
+
+	if _, err := UploadStream(store, r, "backup/a.tar.zst", MinPartSize); !errors.Is(err, errSource) {
+		t.Fatalf("Expected the source error, got %v", err)
+	}
+	if len(store.aborted) != 1 || store.pending() != 0 {
+		t.Errorf("Multipart upload should be aborted, aborted %v, pending %d", store.aborted, store.pending())
+	}
+	if _, err := store.Stat("backup/a.tar.zst"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("No object should be written, got %v", err)
+	}
+}
+
+func TestUploadStreamSourceErrorBeforeFirstPart(t *testing.T) {
+	store := newFakeMultipart(t)
+	errSource := errors.New("source dir is empty")
+
+	if _, err := UploadStream(store, &errReader{err: errSource}, "backup/a.tar.zst", MinPartSize); !errors.Is(err, errSource) {
+		t.Fatalf("Expected the source error, got %v", err)
+	}
+	if store.initiated != 0 {
+		t.Errorf("No upload should be started, got %d uploads", store.initiated)
+	}
+}
+
+func TestUploadStreamPartError(t *testing.T) {
+	store := newFakeMultipart(t)
+	store.failPart = 2
+
+	if _, err := UploadStream(store, bytes.NewReader(randomData(MinPartSize * 5 / 2)), "backup/a.tar.zst", MinPartSize); err == nil {
+		t.Fatal("Expected the upload to fail")
+	}
+	if len(store.aborted) != 1 || store.pending() != 0 {
+		t.Errorf("Multipart upload should be aborted, aborted %v, pending %d", store.aborted, store.pending())
+	}
+}
+
+// errReader 读取时返回指定错误的数据源
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
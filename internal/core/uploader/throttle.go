@@ -0,0 +1,97 @@
+package uploader
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// throttleChunk 限速时单次读取的最大字节数，避免一次读取大块数据后长时间停顿
+const throttleChunk = 64 * 1024
+
+// limiter 按时刻变化的传输限速，同时进行的多个传输共用同一个 limiter 时共享限速
+type limiter struct {
+	mu   sync.Mutex
+	rate func(time.Time) int64 // 返回指定时刻的每秒字节数，0 表示不限速
+	next time.Time             // 已发放的传输额度用完的时间
+}
+
+var (
+	uploadLimiter   = &limiter{}
+	downloadLimiter = &limiter{}
+)
+
+// SetBandwidth 设置上传与下载限速，函数返回指定时刻的每秒字节数，nil 或返回 0 表示不限速
+// 对正在进行的传输同样生效
+func SetBandwidth(upload, download func(time.Time) int64) {
+	uploadLimiter.setRate(upload)
+	downloadLimiter.setRate(download)
+}
+
+func (l *limiter) setRate(rate func(time.Time) int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+}
+
+// current 返回当前的限速，0 表示不限速
+func (l *limiter) current(now time.Time) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate == nil {
+		return 0
+	}
+	return max(l.rate(now), 0)
+}
+
+// wait 为已读取的 n 个字节申请传输额度，额度不足时等待
+func (l *limiter) wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	now := time.Now()
+	rate := l.current(now)
+	if rate <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / rate))
+	l.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// ThrottleUpload 返回受上传限速约束的 Reader，用于不经过 Upload 直接写入存储的数据（如分块仓库的数据块）
+func ThrottleUpload(r io.Reader) io.Reader {
+	return &throttledReader{r: r, limiter: uploadLimiter}
+}
+
+// ThrottleDownload 返回受下载限速约束的 ReadCloser，用于不经过 Download 直接读取的对象
+func ThrottleDownload(rc io.ReadCloser) io.ReadCloser {
+	return struct {
+		io.Reader
+		io.Closer
+	}{&throttledReader{r: rc, limiter: downloadLimiter}, rc}
+}
+
+// throttledReader 按限速读取数据
+type throttledReader struct {
+	r       io.Reader
+	limiter *limiter
+}
+
+func (t *throttledReader) Read(b []byte) (int, error) {
+	if len(b) > throttleChunk && t.limiter.current(time.Now()) > 0 {
+		b = b[:throttleChunk]
+	}
+	n, err := t.r.Read(b)
+	t.limiter.wait(n)
+	return n, err
+}
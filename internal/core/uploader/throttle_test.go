@@ -0,0 +1,33 @@
+package uploader
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestThrottleUpload(t *testing.T) {
+	SetBandwidth(func(time.Time) int64 { return 256 * 1024 }, nil)
+	t.Cleanup(func() { SetBandwidth(nil, nil) })
+
+	// 512KB 按 256KB/s 限速，最后一次读取的额度不需要等待，至少耗时约 1.75 秒
+	data := make([]byte, 512*1024)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, ThrottleUpload(bytes.NewReader(data)))
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("Copy returned %d, %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond {
+		t.Errorf("Expected the upload to be throttled, took %v", elapsed)
+	}
+
+	// 未设置下载限速时不等待
+	start = time.Now()
+	if _, err := io.Copy(io.Discard, ThrottleDownload(io.NopCloser(bytes.NewReader(data)))); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Download without a limit should not be throttled, took %v", elapsed)
+	}
+}
@@ -1,64 +1,21 @@
 package uploader
 
 import (
-	"context"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/dustin/go-humanize"
-	"github.com/tencentyun/cos-go-sdk-v5"
-	"backup-go/internal/config"
+	"backup-go/internal/core/archiver"
+	"backup-go/internal/core/storage"
 	"backup-go/internal/logger"
+	"backup-go/internal/metrics"
 )
 
-// NewClient 创建 COS 客户端
-func NewClient(cfg *config.CosConfig) (*cos.Client, error) {
-	bu, err := url.Parse(fmt.Sprintf("https://%s.cos.%s.myqcloud.com", cfg.Bucket, cfg.Region))
-	if err != nil {
-		return nil, fmt.Errorf("构造 Bucket URL 失败: %w", err)
-	}
-	su, err := url.Parse(fmt.Sprintf("https://cos.%s.myqcloud.com", cfg.Region))
-	if err != nil {
-		return nil, fmt.Errorf("构造 Service URL 失败: %w", err)
-	}
-	// 加强 HTTP 连接稳健性
-	baseTransport := &http.Transport{
-		Proxy:                 http.ProxyFromEnvironment,
-		MaxIdleConns:          100,
-		MaxIdleConnsPerHost:   10,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		ResponseHeaderTimeout: 60 * time.Second,
-	}
-	return cos.NewClient(
-		&cos.BaseURL{BucketURL: bu, ServiceURL: su},
-		&http.Client{Transport: &cos.AuthorizationTransport{
-			SecretID:  cfg.SecretID,
-			SecretKey: cfg.SecretKey,
-			Transport: baseTransport,
-		}},
-	), nil
-}
-
-// TestConnection 测试连接
-func TestConnection(client *cos.Client, bucket string) error {
-	logger.PrintLog("check", "正在测试 COS 连接...")
-	_, _, err := client.Bucket.Get(context.Background(), &cos.BucketGetOptions{})
-	if err != nil {
-		return fmt.Errorf("COS 连接测试失败: %w", err)
-	}
-	logger.PrintLog("check", "COS 连接成功")
-	return nil
-}
-
 func friendlyDuration(d time.Duration) string {
 	if d <= 0 {
 		return "-"
@@ -92,14 +49,16 @@ type progressReader struct {
 	interval time.Duration
 	onTick   func(read, total, rate int64, eta time.Duration)
 	finished bool
+	limiter  *limiter // 传输限速，nil 表示不限速
 }
 
-func newProgressReader(r io.Reader, total int64, interval time.Duration, onTick func(read, total, rate int64, eta time.Duration)) *progressReader {
+func newProgressReader(r io.Reader, total int64, interval time.Duration, limiter *limiter, onTick func(read, total, rate int64, eta time.Duration)) *progressReader {
 	return &progressReader{
 		r:        r,
 		total:    total,
 		interval: interval,
 		onTick:   onTick,
+		limiter:  limiter,
 	}
 }
 
@@ -107,9 +66,13 @@ func (p *progressReader) Read(b []byte) (int, error) {
 	if p.finished {
 		return 0, io.EOF
 	}
+	if p.limiter != nil && len(b) > throttleChunk && p.limiter.current(time.Now()) > 0 {
+		b = b[:throttleChunk]
+	}
 	n, err := p.r.Read(b)
 	if n > 0 {
 		p.read += int64(n)
+		p.limiter.wait(n)
 	}
 	now := time.Now()
 
@@ -142,14 +105,8 @@ func (p *progressReader) Read(b []byte) (int, error) {
 	return n, err
 }
 
-// Upload 上传文件到 COS
-func Upload(client *cos.Client, localFile, cosPath string) error {
-	fi, err := os.Stat(localFile)
-	if err != nil {
-		return fmt.Errorf("获取本地文件信息失败: %w", err)
-	}
-	logger.PrintLog("upload", fmt.Sprintf("开始上传文件: %s → %s", localFile, cosPath))
-
+// progressInterval 读取进度打印间隔（环境变量 PROGRESS_INTERVAL）
+func progressInterval() time.Duration {
 	interval := time.Second
 	if v := os.Getenv("PROGRESS_INTERVAL"); v != "" {
 		if len(v) > 20 {
@@ -160,6 +117,44 @@ func Upload(client *cos.Client, localFile, cosPath string) error {
 			logger.PrintLog("warn", "环境变量 PROGRESS_INTERVAL 格式无效或超出范围(1ms-60s)，使用默认值")
 		}
 	}
+	return interval
+}
+
+// progressLogger 返回按指定日志级别打印传输进度的回调
+func progressLogger(level string) func(read, total, rate int64, eta time.Duration) {
+	return func(read, total, rate int64, eta time.Duration) {
+		percent := 0
+		if total > 0 {
+			percent = int(float64(read) / float64(total) * 100)
+		}
+		logger.PrintLog(level, fmt.Sprintf("进度: %3d%% (%s/%s) %s/s ETA %s",
+			percent,
+			humanize.Bytes(uint64(read)),
+			humanize.Bytes(uint64(total)),
+			humanize.Bytes(uint64(rate)),
+			friendlyDuration(eta),
+		))
+	}
+}
+
+// Upload 上传本地文件到存储
+// 存储支持分块上传且文件超过分块阈值时使用分块上传，进度持久化到状态文件，中断后再次调用会续传
+func Upload(store storage.Storage, localFile, key string, opts UploadOptions) error {
+	fi, err := os.Stat(localFile)
+	if err != nil {
+		return fmt.Errorf("获取本地文件信息失败: %w", err)
+	}
+	logger.PrintLog("upload", fmt.Sprintf("开始上传文件: %s → %s", localFile, key))
+
+	interval := progressInterval()
+	opts = opts.normalize()
+	if mp, ok := store.(storage.Multipart); ok && fi.Size() > opts.MultipartThreshold {
+		if err := uploadMultipart(mp, localFile, key, fi, opts, interval); err != nil {
+			return fmt.Errorf("分块上传失败（已保存进度，可续传）：%w", err)
+		}
+		logger.PrintLog("upload", "文件上传完成")
+		return nil
+	}
 
 	var lastErr error
 	for attempt := 1; attempt <= 3; attempt++ {
@@ -168,23 +163,8 @@ func Upload(client *cos.Client, localFile, cosPath string) error {
 			return fmt.Errorf("打开本地文件失败: %w", err)
 		}
 
-		pr := newProgressReader(f, fi.Size(), interval, func(read, total, rate int64, eta time.Duration) {
-			percent := 0
-			if total > 0 {
-				percent = int(float64(read) / float64(total) * 100)
-			}
-			logger.PrintLog("upload", fmt.Sprintf("进度: %3d%% (%s/%s) %s/s ETA %s",
-				percent,
-				humanize.Bytes(uint64(read)),
-				humanize.Bytes(uint64(total)),
-				humanize.Bytes(uint64(rate)),
-				friendlyDuration(eta),
-			))
-		})
-
-		_, err = client.Object.Put(context.Background(), cosPath, pr, &cos.ObjectPutOptions{
-			ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{ContentLength: fi.Size()},
-		})
+		pr := newProgressReader(f, fi.Size(), interval, uploadLimiter, progressLogger("upload"))
+		err = store.Put(key, pr, fi.Size())
 		_ = f.Close()
 
 		if err == nil {
@@ -196,112 +176,82 @@ func Upload(client *cos.Client, localFile, cosPath string) error {
 		if attempt < 3 {
 			backoff := time.Duration(1<<uint(attempt-1)) * 500 * time.Millisecond
 			logger.PrintLog("warn", fmt.Sprintf("上传失败，准备重试（第 %d/3 次，%s 后重试）：%v", attempt, backoff, err))
+			metrics.UploadRetries.Inc()
 			time.Sleep(backoff)
 		}
 	}
-	return fmt.Errorf("上传文件到 COS 失败（已重试 3 次）：%w", lastErr)
+	return fmt.Errorf("上传文件失败（已重试 3 次）：%w", lastErr)
+}
+
+// IncrementalSuffix 增量备份文件名中时间戳之后的标记，如 backup-20060102-150405-incr.tar.zst
+const IncrementalSuffix = "-incr"
+
+// backupSuffixes 备份文件扩展名（未加密、age 加密、口令加密）
+var backupSuffixes = []string{".tar.zst", ".tar.zst.age", ".tar.zst.enc"}
+
+// sidecarSuffixes 与归档一同存放、随归档一起清理的附属文件后缀
+var sidecarSuffixes = []string{ChecksumSuffix, archiver.ManifestSuffix}
+
+func backupSuffix(name string) (string, bool) {
+	for _, suffix := range backupSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return suffix, true
+		}
+	}
+	return "", false
 }
 
 func isBackupObject(key string) bool {
 	name := filepath.Base(key)
-	return strings.HasPrefix(name, "backup-") && strings.HasSuffix(name, ".tar.zst")
+	_, ok := backupSuffix(name)
+	return strings.HasPrefix(name, "backup-") && ok
+}
+
+// isIncremental 判断备份对象是否为增量备份
+func isIncremental(key string) bool {
+	name := filepath.Base(key)
+	suffix, _ := backupSuffix(name)
+	return strings.HasSuffix(strings.TrimSuffix(name, suffix), IncrementalSuffix)
 }
 
 func parseBackupTime(key string) (time.Time, bool) {
 	name := filepath.Base(key)
-	ts := strings.TrimSuffix(strings.TrimPrefix(name, "backup-"), ".tar.zst")
+	suffix, _ := backupSuffix(name)
+	ts := strings.TrimSuffix(strings.TrimPrefix(name, "backup-"), suffix)
+	ts = strings.TrimSuffix(ts, IncrementalSuffix)
 	t, err := time.Parse("20060102-150405", ts)
 	return t, err == nil
 }
 
-// DeleteExpiredBackups 删除过期备份
-func DeleteExpiredBackups(client *cos.Client, bucket string, cosBasePath string, keepDays int) error {
-	if keepDays <= 0 {
-		logger.PrintLog("info", "保留天数为 0 或负数，跳过过期文件清理")
-		return nil
-	}
-	logger.PrintLog("cleanup", "开始清理过期备份文件...")
-	expire := time.Now().AddDate(0, 0, -keepDays)
-	logger.PrintLog("cleanup", fmt.Sprintf("删除 %s 之前创建的备份文件", expire.Format("2006-01-02")))
-
-	const workers = 5
-	type task struct{ key string }
-	tasks := make(chan task, 256)
-
-	var wg sync.WaitGroup
-	var deleted, failed int64
-	var mu sync.Mutex
+// BackupObject 远端备份文件信息
+type BackupObject struct {
+	Key         string
+	Size        int64
+	Time        time.Time
+	Incremental bool // 增量备份，恢复时需要先恢复其所基于的备份
+}
 
-	workerFn := func() {
-		defer wg.Done()
-		for t := range tasks {
-			_, err := client.Object.Delete(context.Background(), t.key)
-			if err != nil {
-				logger.PrintLog("error", fmt.Sprintf("删除 COS 对象失败: %s: %v", t.key, err))
-				mu.Lock()
-				failed++
-				mu.Unlock()
-				continue
-			}
-			mu.Lock()
-			deleted++
-			mu.Unlock()
-			logger.PrintLog("cleanup", "已删除: "+t.key)
-		}
-	}
-	wg.Add(workers)
-	for i := 0; i < workers; i++ {
-		go workerFn()
+// ListBackups 列出前缀下所有符合备份命名的对象（按备份时间从新到旧排序）
+func ListBackups(store storage.Storage, prefix string) ([]BackupObject, error) {
+	objects, err := store.List(prefix)
+	if err != nil {
+		return nil, err
 	}
 
-	listedRaw := 0
-	listedFiles := 0
-	matched := 0
-	toDelete := 0
-
-	marker := ""
-	for {
-		v, _, err := client.Bucket.Get(context.Background(), &cos.BucketGetOptions{
-			Prefix:  cosBasePath,
-			Marker:  marker,
-			MaxKeys: 1000,
-		})
-		if err != nil {
-			close(tasks)
-			wg.Wait()
-			return fmt.Errorf("列举 COS 对象失败: %w", err)
+	var backups []BackupObject
+	for _, it := range objects {
+		if strings.HasSuffix(it.Key, "/") || !isBackupObject(it.Key) {
+			continue
 		}
-
-		for _, it := range v.Contents {
-			listedRaw++
-			if strings.HasSuffix(it.Key, "/") {
-				continue
-			}
-			listedFiles++
-
-			if !isBackupObject(it.Key) {
-				continue
-			}
-			matched++
-
-			if ts, ok := parseBackupTime(it.Key); ok && ts.Before(expire) {
-				toDelete++
-				tasks <- task{key: it.Key}
-			}
+		ts, ok := parseBackupTime(it.Key)
+		if !ok {
+			continue
 		}
-
-		if !v.IsTruncated {
-			break
-		}
-		marker = v.NextMarker
+		backups = append(backups, BackupObject{Key: it.Key, Size: it.Size, Time: ts, Incremental: isIncremental(it.Key)})
 	}
 
-	close(tasks)
-	wg.Wait()
-
-	logger.PrintLog("cleanup", fmt.Sprintf(
-		"列举总数 %d 个（有效对象 %d 个），其中匹配备份命名 %d 个，待删除 %d 个；实际删除 %d 个，失败 %d 个",
-		listedRaw, listedFiles, matched, toDelete, deleted, failed,
-	))
-	return nil
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
 }
@@ -0,0 +1,169 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Path 运行历史文件（JSON Lines，每行一条记录），与 logs、tmp 一样位于工作目录下
+var Path = filepath.Join("state", "history.jsonl")
+
+const (
+	// maxFileSize 历史文件超过该大小时压缩：保留最近的记录，不超过 keepRecords 条且总大小不超过 maxFileSize 的一半，
+	// 留出余量避免记录较大时之后每次追加都触发压缩
+	maxFileSize = 1 << 20
+	keepRecords = 1000
+	// maxLineSize 超过该长度的行读取时直接跳过
+	maxLineSize = 1 << 20
+)
+
+// 触发方式
+const (
+	TriggerManual   = "manual"   // 手动执行（命令行 once 或交互式菜单）
+	TriggerSchedule = "schedule" // 定时执行
+	TriggerCatchUp  = "catch-up" // 补跑服务停止或系统休眠期间错过的定时备份
+)
+
+// Record 一次任务运行的记录
+type Record struct {
+	Job            string    `json:"job"`
+	Trigger        string    `json:"trigger"`
+	Attempt        int       `json:"attempt,omitempty"` // 失败重试时为第几次尝试，从 1 开始
+	Status         string    `json:"status"`            // success、failure、skipped
+	StartTime      time.Time `json:"start_time"`
+	EndTime        time.Time `json:"end_time"`
+	Key            string    `json:"key,omitempty"`
+	OriginalSize   int64     `json:"original_size"`
+	CompressedSize int64     `json:"compressed_size"` // 本次写入存储的字节数
+	FilesProcessed int64     `json:"files_processed"`
+	FilesSkipped   int64     `json:"files_skipped"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// Success 本次运行是否成功（跳过视为成功）
+func (r Record) Success() bool {
+	return r.Error == ""
+}
+
+var mu sync.Mutex
+
+// Append 追加一条运行记录
+func Append(r Record) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(Path), 0755); err != nil {
+		return fmt.Errorf("创建历史目录失败: %w", err)
+	}
+	f, err := os.OpenFile(Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开历史文件失败: %w", err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入历史文件失败: %w", err)
+	}
+
+	if info, err := os.Stat(Path); err == nil && info.Size() > maxFileSize {
+		return compact()
+	}
+	return nil
+}
+
+// Load 读取运行记录（按时间从新到旧），job 为空时返回全部任务，limit <= 0 表示不限制条数
+// 无法解析的行（如写入中断留下的半行）会被忽略
+func Load(job string, limit int) ([]Record, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	records, err := readAll()
+	if err != nil {
+		return nil, err
+	}
+	var result []Record
+	for i := len(records) - 1; i >= 0; i-- {
+		if job != "" && records[i].Job != job {
+			continue
+		}
+		result = append(result, records[i])
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// Last 返回最近一次运行记录，job 为空时不区分任务
+func Last(job string) (Record, bool) {
+	records, err := Load(job, 1)
+	if err != nil || len(records) == 0 {
+		return Record{}, false
+	}
+	return records[0], true
+}
+
+// readAll 按写入顺序读取全部记录，文件不存在时返回空；过长或无法解析的行被跳过
+func readAll() ([]Record, error) {
+	data, err := os.ReadFile(Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取历史文件失败: %w", err)
+	}
+	var records []Record
+	for len(data) > 0 {
+		var line []byte
+		line, data, _ = bytes.Cut(data, []byte{'\n'})
+		if len(line) > maxLineSize {
+			continue
+		}
+		var r Record
+		if json.Unmarshal(line, &r) == nil {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// compact 从最新的记录往前保留，直到达到 keepRecords 条或 maxFileSize 的一半（最新的一条总会保留），写入临时文件后原子替换
+func compact() error {
+	records, err := readAll()
+	if err != nil {
+		return err
+	}
+	var lines [][]byte
+	size := 0
+	for i := len(records) - 1; i >= 0 && len(lines) < keepRecords; i-- {
+		data, err := json.Marshal(records[i])
+		if err != nil {
+			return err
+		}
+		if len(lines) > 0 && size+len(data)+1 > maxFileSize/2 {
+			break
+		}
+		lines = append(lines, data)
+		size += len(data) + 1
+	}
+	var buf bytes.Buffer
+	for i := len(lines) - 1; i >= 0; i-- {
+		buf.Write(append(lines[i], '\n'))
+	}
+	tmp := Path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("压缩历史文件失败: %w", err)
+	}
+	return os.Rename(tmp, Path)
+}
@@ -0,0 +1,122 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useTempPath(t *testing.T) {
+	old := Path
+	Path = filepath.Join(t.TempDir(), "state", "history.jsonl")
+	t.Cleanup(func() { Path = old })
+}
+
+func TestAppendAndLoad(t *testing.T) {
+	useTempPath(t)
+	if _, ok := Last(""); ok {
+		t.Fatal("Expected no records before the first run")
+	}
+
+	start := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	for i, job := range []string{"www", "db", "www"} {
+		r := Record{Job: job, Trigger: TriggerSchedule, Status: "success", StartTime: start.Add(time.Duration(i) * time.Hour)}
+		if i == 2 {
+			r.Status, r.Error = "failure", "上传失败"
+		}
+		if err := Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 写入中断留下的半行不影响读取
+	f, err := os.OpenFile(Path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"job":"www","sta`)
+	f.Close()
+
+	records, err := Load("www", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Error != "上传失败" || records[0].Success() || !records[1].Success() {
+		t.Errorf("Unexpected www records: %+v", records)
+	}
+	if last, ok := Last(""); !ok || last.Job != "www" || !last.StartTime.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("Unexpected last record: %+v", last)
+	}
+	if records, _ := Load("", 2); len(records) != 2 || records[1].Job != "db" {
+		t.Errorf("Expected 2 newest records, got %+v", records)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	useTempPath(t)
+	for i := 0; i < keepRecords+10; i++ {
+		if err := Append(Record{Job: "www", Status: "success", StartTime: time.Unix(int64(i), 0)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := compact(); err != nil {
+		t.Fatal(err)
+	}
+	records, err := Load("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != keepRecords || records[len(records)-1].StartTime.Unix() != 10 {
+		t.Errorf("Expected the newest %d records to be kept, got %d (oldest %v)", keepRecords, len(records), records[len(records)-1].StartTime)
+	}
+}
+
+func TestCompactBySize(t *testing.T) {
+	useTempPath(t)
+	// 每条记录约 100KB，按条数压缩后文件仍会超过上限
+	big := strings.Repeat("x", 100*1024)
+	for i := 0; i < 30; i++ {
+		if err := Append(Record{Job: "www", Status: "failure", Error: big, StartTime: time.Unix(int64(i), 0)}); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > maxFileSize {
+			t.Fatalf("History file should stay under %d bytes, got %d", maxFileSize, info.Size())
+		}
+	}
+	records, err := Load("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 || len(records) > 10 || records[0].StartTime.Unix() != 29 {
+		t.Errorf("Expected only the newest records to be kept, got %d (newest %v)", len(records), records[0].StartTime)
+	}
+}
+
+func TestLoadSkipsLongLine(t *testing.T) {
+	useTempPath(t)
+	if err := Append(Record{Job: "www", Status: "success", StartTime: time.Unix(1, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(Path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"job":"www","error":"` + strings.Repeat("x", maxLineSize) + "\"}\n")
+	f.Close()
+	if err := Append(Record{Job: "db", Status: "success", StartTime: time.Unix(2, 0)}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := Load("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Job != "db" || records[1].Job != "www" {
+		t.Errorf("Expected the over-long line to be skipped, got %+v", records)
+	}
+}
@@ -0,0 +1,206 @@
+// Package lock 防止同一任务的多次备份同时运行：本机使用锁文件，多台主机共用存储前缀时使用存储中的锁对象
+package lock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"backup-go/internal/logger"
+)
+
+// Dir 本机锁文件目录，与 logs、tmp 一样位于工作目录下
+var Dir = filepath.Join("state", "locks")
+
+// ErrLocked 任务已被另一次运行持有
+var ErrLocked = errors.New("任务正在运行")
+
+// Info 锁持有者信息
+type Info struct {
+	Job       string    `json:"job"`
+	Host      string    `json:"host"`
+	PID       int       `json:"pid"`
+	Trigger   string    `json:"trigger,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	UpdatedAt time.Time `json:"updated_at"`           // 远程锁最后一次刷新的时间
+	Token     string    `json:"token"`                // 区分不同的持有者，释放时只删除自己的锁
+	ProcStart string    `json:"proc_start,omitempty"` // 持有进程的启动标识，用于识别被复用的 PID（仅 Linux）
+}
+
+// NewInfo 返回当前进程作为持有者的信息
+func NewInfo(job, trigger string) Info {
+	host, _ := os.Hostname()
+	b := make([]byte, 8)
+	rand.Read(b)
+	now := time.Now()
+	procStart, _ := processStart(os.Getpid())
+	return Info{Job: job, Host: host, PID: os.Getpid(), Trigger: trigger, StartTime: now, UpdatedAt: now, Token: hex.EncodeToString(b), ProcStart: procStart}
+}
+
+// LockedError 锁已被其他运行持有
+type LockedError struct {
+	Holder Info
+	Where  string // 锁文件路径或锁对象键
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("任务 [%s] 正在运行（主机 %s，PID %d，开始于 %s，锁: %s）",
+		e.Holder.Job, e.Holder.Host, e.Holder.PID, e.Holder.StartTime.Format("2006-01-02 15:04:05"), e.Where)
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// FileLock 本机锁文件
+type FileLock struct {
+	path  string
+	token string
+}
+
+// AcquireFile 创建任务的锁文件，锁被仍在运行的进程持有时返回 *LockedError
+// 持有进程已退出（或锁文件无法解析）的残留锁会被清除后重新获取
+func AcquireFile(info Info) (*FileLock, error) {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建锁目录失败: %w", err)
+	}
+	path := filepath.Join(Dir, fileName(info.Job))
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		created, err := createExclusive(path, data)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			return &FileLock{path: path, token: info.Token}, nil
+		}
+
+		holder, err := readFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // 持有者刚刚释放
+		}
+		if err == nil && !holder.dead() {
+			return nil, &LockedError{Holder: holder, Where: path}
+		}
+		if err := removeStale(path, info.Token, holder); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("获取锁文件失败: %s", path)
+}
+
+// removeStale 清除残留的锁文件，holder 为判断为残留时读到的持有者（锁文件无法解析时为零值）
+// 多个进程可能同时判断同一个锁文件为残留，因此先原子地改名为本进程独有的文件名再确认内容：
+// 只有一个进程能改名成功，改名得到的若已是其他进程新建的锁则放回原处
+func removeStale(path, token string, holder Info) error {
+	stale := path + ".stale-" + token
+	if err := os.Rename(path, stale); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil // 已被其他进程清除
+		}
+		return fmt.Errorf("清除残留锁文件失败: %w", err)
+	}
+	defer os.Remove(stale)
+
+	current, err := readFile(stale)
+	switch {
+	case err != nil:
+		logger.PrintLog("warn", fmt.Sprintf("清除无法读取的锁文件: %v", err))
+	case current.Token != holder.Token && !current.dead():
+		restoreLock(stale, path)
+	default:
+		logger.PrintLog("warn", fmt.Sprintf("清除残留的锁文件 %s（PID %d 已不在运行）", path, current.PID))
+	}
+	return nil
+}
+
+// restoreLock 将误改名的他人锁文件放回原处，原处已有新锁时保留新锁
+func restoreLock(stale, path string) {
+	if err := os.Link(stale, path); err != nil && !errors.Is(err, os.ErrExist) {
+		logger.PrintLog("warn", fmt.Sprintf("恢复锁文件 %s 失败: %v", path, err))
+	}
+}
+
+// createExclusive 先写临时文件再硬链接到 path，保证其他进程读到的锁文件内容总是完整的
+// path 已存在时返回 false
+func createExclusive(path string, data []byte) (bool, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".lock-*")
+	if err != nil {
+		return false, fmt.Errorf("创建锁文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("写入锁文件失败: %w", err)
+	}
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("创建锁文件失败: %w", err)
+	}
+	return true, nil
+}
+
+// Release 删除锁文件；锁已被他人清除并重新获取时不做任何操作
+func (l *FileLock) Release() error {
+	holder, err := readFile(l.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if holder.Token != l.token {
+		return nil
+	}
+	return os.Remove(l.path)
+}
+
+func readFile(path string) (Info, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Info{}, err
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return Info{}, fmt.Errorf("解析锁文件 %s 失败: %w", path, err)
+	}
+	return info, nil
+}
+
+// dead 持有进程是否已确定退出，其他主机的进程无法检查，视为仍在运行
+// PID 仍存在但启动标识与锁中记录的不同时，说明 PID 已被其他进程复用（如系统重启后），同样视为已退出
+func (i Info) dead() bool {
+	if i.PID <= 0 {
+		return true
+	}
+	if host, _ := os.Hostname(); i.Host != host {
+		return false
+	}
+	if !processAlive(i.PID) {
+		return true
+	}
+	if i.ProcStart == "" {
+		return false
+	}
+	start, ok := processStart(i.PID)
+	return ok && start != i.ProcStart
+}
+
+// fileName 返回任务的锁文件名，任务名称已在加载配置时校验不含路径分隔符
+func fileName(job string) string {
+	return job + ".lock"
+}
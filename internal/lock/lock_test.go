@@ -0,0 +1,182 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"backup-go/internal/core/storage"
+)
+
+func useTempDir(t *testing.T) {
+	old := Dir
+	Dir = filepath.Join(t.TempDir(), "locks")
+	t.Cleanup(func() { Dir = old })
+}
+
+func TestFileLock(t *testing.T) {
+	useTempDir(t)
+	l, err := AcquireFile(NewInfo("www", "manual"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = AcquireFile(NewInfo("www", "schedule"))
+	var locked *LockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrLocked) || locked.Holder.PID != os.Getpid() {
+		t.Fatalf("Expected the second acquire to report the holder, got %v", err)
+	}
+	if _, err := AcquireFile(NewInfo("db", "manual")); err != nil {
+		t.Errorf("Different jobs should not share a lock: %v", err)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+	l, err = AcquireFile(NewInfo("www", "schedule"))
+	if err != nil {
+		t.Fatalf("Expected lock to be free after release: %v", err)
+	}
+	l.Release()
+}
+
+func TestFileLockStale(t *testing.T) {
+	useTempDir(t)
+	os.MkdirAll(Dir, 0755)
+
+	// 同一主机上已退出的进程留下的锁会被清除
+	dead := NewInfo("www", "schedule")
+	dead.PID = 1 << 30 // 超出系统 PID 上限，不可能存在
+	writeLockFile(t, dead)
+	l, err := AcquireFile(NewInfo("www", "manual"))
+	if err != nil {
+		t.Fatalf("Expected stale lock to be replaced: %v", err)
+	}
+	l.Release()
+
+	// PID 被复用：进程存在但启动标识不同
+	if _, ok := processStart(os.Getpid()); ok {
+		reused := NewInfo("www", "schedule")
+		reused.ProcStart = "previous-boot:12345"
+		writeLockFile(t, reused)
+		l, err := AcquireFile(NewInfo("www", "manual"))
+		if err != nil {
+			t.Fatalf("Expected lock with a reused PID to be replaced: %v", err)
+		}
+		l.Release()
+	}
+
+	// 其他主机的锁无法检查进程，视为仍被持有
+	remote := dead
+	remote.Host = "other-host"
+	writeLockFile(t, remote)
+	if _, err := AcquireFile(NewInfo("www", "manual")); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected lock held by another host to block, got %v", err)
+	}
+}
+
+func TestFileLockStaleConcurrent(t *testing.T) {
+	useTempDir(t)
+	os.MkdirAll(Dir, 0755)
+
+	for round := 0; round < 20; round++ {
+		dead := NewInfo("www", "schedule")
+		dead.PID = 1 << 30
+		writeLockFile(t, dead)
+
+		// 多个进程同时发现同一个残留锁时，只能有一个获取成功
+		var wg sync.WaitGroup
+		locks := make(chan *FileLock, 8)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				l, err := AcquireFile(NewInfo("www", "manual"))
+				if err == nil {
+					locks <- l
+				} else if !errors.Is(err, ErrLocked) {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		close(locks)
+		if len(locks) != 1 {
+			t.Fatalf("Round %d: expected exactly one holder, got %d", round, len(locks))
+		}
+		(<-locks).Release()
+	}
+}
+
+func TestRemoveStaleKeepsNewLock(t *testing.T) {
+	useTempDir(t)
+	os.MkdirAll(Dir, 0755)
+
+	// 另一个进程读到残留锁后，锁已被清除并由本进程重新获取，此时不能删除新锁
+	dead := NewInfo("www", "schedule")
+	dead.PID = 1 << 30
+	l, err := AcquireFile(NewInfo("www", "manual"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+	path := filepath.Join(Dir, fileName("www"))
+	if err := removeStale(path, "other-process", dead); err != nil {
+		t.Fatal(err)
+	}
+	holder, err := readFile(path)
+	if err != nil || holder.Token != l.token {
+		t.Errorf("Live lock should be restored, got %+v (%v)", holder, err)
+	}
+	if matches, _ := filepath.Glob(path + ".stale-*"); len(matches) != 0 {
+		t.Errorf("Renamed lock files should be removed, got %v", matches)
+	}
+}
+
+func writeLockFile(t *testing.T, info Info) {
+	t.Helper()
+	data, _ := json.Marshal(info)
+	if err := os.WriteFile(filepath.Join(Dir, fileName(info.Job)), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteLock(t *testing.T) {
+	old := SettleDelay
+	SettleDelay = 0
+	t.Cleanup(func() { SettleDelay = old })
+
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "backup/www/" + RemoteName
+	l, err := AcquireRemote(store, key, NewInfo("www", "manual"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcquireRemote(store, key, NewInfo("www", "schedule"), time.Minute); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected remote lock to be held, got %v", err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Stat(key); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected lock object to be deleted, got %v", err)
+	}
+
+	// 长时间未刷新的锁视为残留
+	stale := NewInfo("www", "schedule")
+	stale.UpdatedAt = time.Now().Add(-time.Hour)
+	if err := writeRemote(store, key, stale); err != nil {
+		t.Fatal(err)
+	}
+	l, err = AcquireRemote(store, key, NewInfo("www", "manual"), time.Minute)
+	if err != nil {
+		t.Fatalf("Expected stale remote lock to be replaced: %v", err)
+	}
+	l.Release()
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package lock
+
+import "os"
+
+// processAlive 检查进程是否存在，Windows 上查找不存在的进程会返回错误
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
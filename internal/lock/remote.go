@@ -0,0 +1,130 @@
+package lock
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"backup-go/internal/core/storage"
+	"backup-go/internal/logger"
+)
+
+// RemoteName 远程锁对象在任务前缀下的名称
+const RemoteName = "backup.lock"
+
+// SettleDelay 写入远程锁后等待多久再读回核对持有者
+var SettleDelay = 2 * time.Second
+
+// RemoteLock 存储中的锁对象，持有期间定期刷新 updated_at
+type RemoteLock struct {
+	store storage.Storage
+	key   string
+	info  Info
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// AcquireRemote 在存储中写入锁对象，锁被其他运行持有且在 stale 时长内刷新过时返回 *LockedError
+// 对象存储没有原子的条件写入，写入后等待片刻再读回核对持有者，尽量避免两台主机同时获得锁
+func AcquireRemote(store storage.Storage, key string, info Info, stale time.Duration) (*RemoteLock, error) {
+	holder, err := readRemote(store, key)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+	case err != nil:
+		return nil, err
+	case time.Since(holder.UpdatedAt) < stale:
+		return nil, &LockedError{Holder: holder, Where: key}
+	default:
+		logger.PrintLog("warn", fmt.Sprintf("清除残留的远程锁 %s（主机 %s，最后刷新于 %s）",
+			key, holder.Host, holder.UpdatedAt.Format("2006-01-02 15:04:05")))
+	}
+
+	info.UpdatedAt = time.Now()
+	if err := writeRemote(store, key, info); err != nil {
+		return nil, err
+	}
+	time.Sleep(SettleDelay)
+	holder, err = readRemote(store, key)
+	if err != nil {
+		return nil, err
+	}
+	if holder.Token != info.Token {
+		return nil, &LockedError{Holder: holder, Where: key}
+	}
+
+	l := &RemoteLock{store: store, key: key, info: info, stop: make(chan struct{})}
+	l.wg.Add(1)
+	go l.refresh(stale / 3)
+	return l, nil
+}
+
+// refresh 定期刷新锁对象，避免长时间运行的备份被其他主机当作残留锁
+func (l *RemoteLock) refresh(interval time.Duration) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.info.UpdatedAt = time.Now()
+			if err := writeRemote(l.store, l.key, l.info); err != nil {
+				logger.PrintLog("warn", fmt.Sprintf("刷新远程锁失败: %v", err))
+			}
+		}
+	}
+}
+
+// Release 停止刷新并删除锁对象；锁已被其他主机接管时不删除
+func (l *RemoteLock) Release() error {
+	close(l.stop)
+	l.wg.Wait()
+	holder, err := readRemote(l.store, l.key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if holder.Token != l.info.Token {
+		return nil
+	}
+	return l.store.Delete(l.key)
+}
+
+// readRemote 读取锁对象，无法解析的锁对象视为早已过期
+func readRemote(store storage.Storage, key string) (Info, error) {
+	rc, err := store.Get(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return Info{}, err
+		}
+		return Info{}, fmt.Errorf("读取远程锁失败: %w", err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return Info{}, fmt.Errorf("读取远程锁失败: %w", err)
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return Info{}, nil
+	}
+	return info, nil
+}
+
+func writeRemote(store storage.Storage, key string, info Info) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := store.Put(key, bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("写入远程锁失败: %w", err)
+	}
+	return nil
+}
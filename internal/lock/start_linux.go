@@ -0,0 +1,30 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// processStart 返回进程的启动标识：系统启动 ID 加上进程启动时刻（/proc/<pid>/stat 中的 starttime，单位为时钟周期）
+// PID 在系统重启或进程退出后可能被复用，启动标识可以区分复用 PID 的新进程
+func processStart(pid int) (string, bool) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", false
+	}
+	// 第 2 个字段为括号中的进程名，可能包含空格，从最后一个右括号之后开始按空格拆分（第 3 个字段起）
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return "", false
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return "", false
+	}
+	start := fields[19] // 第 22 个字段 starttime
+	if bootID, err := os.ReadFile("/proc/sys/kernel/random/boot_id"); err == nil {
+		start = strings.TrimSpace(string(bootID)) + ":" + start
+	}
+	return start, true
+}
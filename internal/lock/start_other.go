@@ -0,0 +1,8 @@
+//go:build !linux
+
+package lock
+
+// processStart 返回进程的启动标识，当前平台无法获取时返回 false，只按 PID 判断进程是否存在
+func processStart(pid int) (string, bool) {
+	return "", false
+}
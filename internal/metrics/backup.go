@@ -0,0 +1,31 @@
+package metrics
+
+// 备份相关指标，在进程内累计，服务模式下通过 [metrics] listen 暴露给 Prometheus
+var (
+	LastRunTimestamp = NewGauge("backup_go_last_run_timestamp_seconds",
+		"任务上次运行结束的 Unix 时间", "job")
+	LastSuccessTimestamp = NewGauge("backup_go_last_success_timestamp_seconds",
+		"任务上次成功运行结束的 Unix 时间", "job")
+	LastRunDuration = NewGauge("backup_go_last_run_duration_seconds",
+		"任务上次运行的耗时（秒）", "job")
+	LastRunSuccess = NewGauge("backup_go_last_run_success",
+		"任务上次运行是否成功（1 成功，0 失败），跳过的运行不更新", "job")
+	LastSkippedTimestamp = NewGauge("backup_go_last_skipped_timestamp_seconds",
+		"任务上次因源目录为空或任务正在运行而跳过的 Unix 时间", "job")
+	NextRunTimestamp = NewGauge("backup_go_next_run_timestamp_seconds",
+		"任务下次定时运行的 Unix 时间", "job")
+
+	Runs = NewCounter("backup_go_runs_total",
+		"按结果统计的任务运行次数", "job", "status")
+	ArchivedBytes = NewCounter("backup_go_archived_bytes_total",
+		"打包的原始数据字节数", "job")
+	UploadedBytes = NewCounter("backup_go_uploaded_bytes_total",
+		"写入存储的字节数（归档或新上传的数据块）", "job")
+	FilesSkipped = NewCounter("backup_go_files_skipped_total",
+		"因读取错误或危险路径跳过的文件数", "job")
+
+	UploadRetries = NewCounter("backup_go_upload_retries_total",
+		"上传与分块上传的重试次数")
+	RetentionDeleted = NewCounter("backup_go_retention_deleted_total",
+		"按保留策略删除的备份与快照数")
+)
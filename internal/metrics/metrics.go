@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 指标类型
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+)
+
+// Metric 一个带标签的指标，按标签值区分多个时间序列
+type Metric struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64 // 键为按 \xff 连接的标签值
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*Metric
+)
+
+// NewCounter 注册一个只增不减的计数器
+func NewCounter(name, help string, labels ...string) *Metric {
+	return register(&Metric{name: name, help: help, typ: typeCounter, labels: labels})
+}
+
+// NewGauge 注册一个可任意设置的仪表
+func NewGauge(name, help string, labels ...string) *Metric {
+	return register(&Metric{name: name, help: help, typ: typeGauge, labels: labels})
+}
+
+func register(m *Metric) *Metric {
+	m.values = make(map[string]float64)
+	registryMu.Lock()
+	registry = append(registry, m)
+	registryMu.Unlock()
+	return m
+}
+
+// Set 设置指定标签值对应序列的值
+func (m *Metric) Set(v float64, labelValues ...string) {
+	key := m.key(labelValues)
+	m.mu.Lock()
+	m.values[key] = v
+	m.mu.Unlock()
+}
+
+// Add 为指定标签值对应的序列增加 v
+func (m *Metric) Add(v float64, labelValues ...string) {
+	key := m.key(labelValues)
+	m.mu.Lock()
+	m.values[key] += v
+	m.mu.Unlock()
+}
+
+// Inc 为指定标签值对应的序列加一
+func (m *Metric) Inc(labelValues ...string) {
+	m.Add(1, labelValues...)
+}
+
+// Reset 删除所有序列，用于任务列表变化后清除已删除任务的序列
+func (m *Metric) Reset() {
+	m.mu.Lock()
+	m.values = make(map[string]float64)
+	m.mu.Unlock()
+}
+
+func (m *Metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("指标 %s 需要 %d 个标签值，实际 %d 个", m.name, len(m.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// write 以 Prometheus 文本格式输出指标
+func (m *Metric) write(w io.Writer) {
+	m.mu.Lock()
+	keys := make([]string, 0, len(m.values))
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]float64, len(keys))
+	for i, k := range keys {
+		values[i] = m.values[k]
+	}
+	m.mu.Unlock()
+
+	// 无标签的计数器即使没有数据也输出 0，便于告警规则引用
+	if len(keys) == 0 && len(m.labels) == 0 {
+		keys, values = []string{""}, []float64{0}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+	for i, k := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", m.name, m.formatLabels(k), strconv.FormatFloat(values[i], 'g', -1, 64))
+	}
+}
+
+func (m *Metric) formatLabels(key string) string {
+	if len(m.labels) == 0 {
+		return ""
+	}
+	values := strings.Split(key, "\xff")
+	pairs := make([]string, len(m.labels))
+	for i, l := range m.labels {
+		pairs[i] = l + `="` + escapeLabel(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// Handler 返回输出全部指标的 HTTP 处理器
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		registryMu.Lock()
+		metrics := append([]*Metric(nil), registry...)
+		registryMu.Unlock()
+		for _, m := range metrics {
+			m.write(w)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteTextFormat(t *testing.T) {
+	m := &Metric{name: "test_runs_total", help: "测试", typ: typeCounter, labels: []string{"job", "status"}, values: map[string]float64{}}
+	m.Inc("web", "success")
+	m.Add(2, "web", "success")
+	m.Inc(`a"b\c`, "failure")
+
+	var sb strings.Builder
+	m.write(&sb)
+	want := "# HELP test_runs_total 测试\n# TYPE test_runs_total counter\n" +
+		`test_runs_total{job="a\"b\\c",status="failure"} 1` + "\n" +
+		`test_runs_total{job="web",status="success"} 3` + "\n"
+	if sb.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", sb.String(), want)
+	}
+
+	m.Reset()
+	sb.Reset()
+	m.write(&sb)
+	if sb.Len() != 0 {
+		t.Errorf("Expected no output for an empty labeled metric, got %q", sb.String())
+	}
+}
+
+func TestHandlerIncludesUnlabeledZero(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "\nbackup_go_upload_retries_total 0\n") {
+		t.Errorf("Expected unlabeled counter to be exported as 0, got:\n%s", rec.Body.String())
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Unexpected content type %q", rec.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"backup-go/internal/config"
+	"backup-go/internal/logger"
+)
+
+// sendTimeout 单个渠道发送通知的超时时间
+const sendTimeout = 15 * time.Second
+
+// Event 一次任务运行的结果，generic webhook 直接以 JSON 发送
+type Event struct {
+	Job       string    `json:"job"`
+	Status    string    `json:"status"` // success、failure、skipped
+	Host      string    `json:"host"`
+	Archive   string    `json:"archive,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	Size      int64     `json:"size"`      // 本次写入存储的字节数
+	DataSize  int64     `json:"data_size"` // 备份的原始数据字节数
+	Files     int64     `json:"files"`
+	Duration  float64   `json:"duration_seconds"`
+	Error     string    `json:"error,omitempty"`
+	StartTime time.Time `json:"start_time"`
+	Attempt   int       `json:"attempt,omitempty"` // 失败重试时为第几次尝试
+}
+
+// NewEvent 创建通知事件，填充主机名
+func NewEvent(job, status string) Event {
+	host, _ := os.Hostname()
+	return Event{Job: job, Status: status, Host: host}
+}
+
+// Failed 本次备份是否失败
+func (e Event) Failed() bool {
+	return e.Error != ""
+}
+
+// Send 向所有配置的渠道发送通知，某个渠道发送失败只记录日志，不影响其他渠道和备份结果
+func Send(cfg config.NotifyConfig, e Event) {
+	for _, w := range cfg.Webhooks {
+		if w.OnFailureOnly && !e.Failed() {
+			continue
+		}
+		if err := sendWebhook(w, e); err != nil {
+			logger.PrintLog("warn", fmt.Sprintf("发送 %s 通知失败: %v", w.Type, err))
+		}
+	}
+	for _, c := range cfg.Emails {
+		if c.OnFailureOnly && !e.Failed() {
+			continue
+		}
+		if err := sendEmail(c, e); err != nil {
+			logger.PrintLog("warn", fmt.Sprintf("发送邮件通知失败 (%s): %v", c.Host, err))
+		}
+	}
+}
+
+// title 返回通知标题
+func (e Event) title() string {
+	result := "成功"
+	switch {
+	case e.Failed():
+		result = "失败"
+	case e.Status == "skipped":
+		result = "跳过"
+	}
+	return fmt.Sprintf("[backup-go] 任务 %s 备份%s", e.Job, result)
+}
+
+// lines 返回通知正文的各行
+func (e Event) lines() []string {
+	lines := []string{
+		"任务: " + e.Job,
+		"状态: " + e.Status,
+		"主机: " + e.Host,
+		"开始时间: " + e.StartTime.Format("2006-01-02 15:04:05"),
+		"耗时: " + (time.Duration(e.Duration * float64(time.Second))).Round(time.Second).String(),
+	}
+	if e.Attempt > 1 {
+		lines = append(lines, fmt.Sprintf("尝试: 第 %d 次", e.Attempt))
+	}
+	if e.Key != "" {
+		lines = append(lines,
+			"备份: "+e.Key,
+			fmt.Sprintf("大小: %s（原始数据 %s，%d 个条目）", humanize.Bytes(uint64(e.Size)), humanize.Bytes(uint64(e.DataSize)), e.Files))
+	}
+	if e.Error != "" {
+		lines = append(lines, "错误: "+e.Error)
+	}
+	return lines
+}
@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"backup-go/internal/config"
+)
+
+func testEvent(failed bool) Event {
+	e := Event{Job: "db", Status: "success", Host: "host-1", Key: "db/backup-20240101-020000.tar.zst", Size: 1024, DataSize: 4096, Files: 3, Duration: 62}
+	if failed {
+		e.Status, e.Error = "failure", "上传失败"
+	}
+	return e
+}
+
+func TestSendWebhookFiltering(t *testing.T) {
+	var generic, failureOnly atomic.Int32
+	var payload Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/failure-only" {
+			failureOnly.Add(1)
+			return
+		}
+		generic.Add(1)
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Missing custom header")
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer srv.Close()
+
+	cfg := config.NotifyConfig{Webhooks: []config.WebhookConfig{
+		{Type: config.WebhookGeneric, URL: srv.URL + "/all", Headers: map[string]string{"Authorization": "Bearer token"}},
+		{Type: config.WebhookGeneric, URL: srv.URL + "/failure-only", OnFailureOnly: true},
+	}}
+	Send(cfg, testEvent(false))
+	if generic.Load() != 1 || failureOnly.Load() != 0 {
+		t.Fatalf("Success event: generic=%d failure-only=%d", generic.Load(), failureOnly.Load())
+	}
+	if payload.Job != "db" || payload.Size != 1024 || payload.Duration != 62 {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+
+	Send(cfg, testEvent(true))
+	if generic.Load() != 2 || failureOnly.Load() != 1 {
+		t.Errorf("Failure event: generic=%d failure-only=%d", generic.Load(), failureOnly.Load())
+	}
+	if payload.Status != "failure" || payload.Error != "上传失败" {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+}
+
+func TestSendWebhookChatFormats(t *testing.T) {
+	var body string
+	var query string
+	errcode := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body, query = string(data), r.URL.RawQuery
+		json.NewEncoder(w).Encode(map[string]any{"errcode": errcode, "errmsg": "invalid webhook url"})
+	}))
+	defer srv.Close()
+
+	if err := sendWebhook(config.WebhookConfig{Type: config.WebhookWeCom, URL: srv.URL}, testEvent(true)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, `"msgtype":"markdown"`) || !strings.Contains(body, "错误: 上传失败") {
+		t.Errorf("Unexpected WeCom body: %s", body)
+	}
+
+	// 钉钉加签参数追加到原有查询参数之后
+	t.Setenv("TEST_DINGTALK_SECRET", "SEC123")
+	if err := sendWebhook(config.WebhookConfig{Type: config.WebhookDingTalk, URL: srv.URL + "?access_token=abc", SecretEnv: "TEST_DINGTALK_SECRET"}, testEvent(false)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(query, "access_token=abc") || !strings.Contains(query, "sign=") || !strings.Contains(query, "timestamp=") {
+		t.Errorf("Unexpected DingTalk query: %s", query)
+	}
+
+	errcode = 93000
+	if err := sendWebhook(config.WebhookConfig{Type: config.WebhookWeCom, URL: srv.URL}, testEvent(true)); err == nil || !strings.Contains(err.Error(), "93000") {
+		t.Errorf("Expected errcode to be reported, got %v", err)
+	}
+
+	if err := sendWebhook(config.WebhookConfig{Type: config.WebhookSlack, URL: srv.URL}, testEvent(false)); err != nil {
+		t.Fatal(err)
+	}
+	var slack map[string]string
+	if err := json.Unmarshal([]byte(body), &slack); err != nil || !strings.HasPrefix(slack["text"], "*[backup-go] 任务 db 备份成功*") {
+		t.Errorf("Unexpected Slack body: %s", body)
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	e := testEvent(true)
+	msg := string(buildMessage("backup@example.com", []string{"a@example.com", "b@example.com"}, e.title(), strings.Join(e.lines(), "\n"), time.Now()))
+	header, encoded, ok := strings.Cut(msg, "\r\n\r\n")
+	if !ok {
+		t.Fatal("Missing header separator")
+	}
+	if !strings.Contains(header, "To: a@example.com, b@example.com") || !strings.Contains(header, "Subject: =?UTF-8?b?") {
+		t.Errorf("Unexpected header: %s", header)
+	}
+	body, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(encoded, "\r\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "错误: 上传失败") || !strings.Contains(string(body), "耗时: 1m2s") {
+		t.Errorf("Unexpected body: %s", body)
+	}
+}
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"backup-go/internal/config"
+)
+
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// sendWebhook 按渠道类型组装消息并发送
+func sendWebhook(w config.WebhookConfig, e Event) error {
+	target := w.URL
+	var body any
+	switch w.Type {
+	case config.WebhookWeCom:
+		body = map[string]any{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"content": "**" + e.title() + "**\n" + strings.Join(e.lines(), "\n")},
+		}
+	case config.WebhookDingTalk:
+		body = map[string]any{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"title": e.title(), "text": "#### " + e.title() + "\n\n" + strings.Join(e.lines(), "\n\n")},
+		}
+		if w.SecretEnv != "" {
+			secret := os.Getenv(w.SecretEnv)
+			if secret == "" {
+				return fmt.Errorf("环境变量 %s 未设置", w.SecretEnv)
+			}
+			signed, err := dingTalkSign(target, secret, time.Now())
+			if err != nil {
+				return err
+			}
+			target = signed
+		}
+	case config.WebhookSlack:
+		body = map[string]string{"text": "*" + e.title() + "*\n" + strings.Join(e.lines(), "\n")}
+	default:
+		body = e
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	// 企业微信与钉钉在 HTTP 200 的响应体中返回错误码
+	if w.Type == config.WebhookWeCom || w.Type == config.WebhookDingTalk {
+		var result struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}
+		if err := json.Unmarshal(respBody, &result); err == nil && result.ErrCode != 0 {
+			return fmt.Errorf("errcode %d: %s", result.ErrCode, result.ErrMsg)
+		}
+	}
+	return nil
+}
+
+// dingTalkSign 为钉钉机器人地址追加加签参数
+func dingTalkSign(target, secret string, now time.Time) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+
+	q := u.Query()
+	q.Set("timestamp", timestamp)
+	q.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -2,14 +2,21 @@ package scheduler
 
 import (
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"backup-go/internal/config"
+	"backup-go/internal/control"
+	"backup-go/internal/history"
 	"backup-go/internal/logger"
+	"backup-go/internal/metrics"
 	"backup-go/internal/task"
 )
 
@@ -23,9 +30,17 @@ func Run(cfgPath string) {
 	}
 
 	logger.PrintLog("daemon", "=== 启动备份服务 (Server Mode) ===")
-	logger.PrintLog("daemon", fmt.Sprintf("定时任务配置: 每天 %02d:%02d",
-		cfg.Backup.Schedule.Hour, cfg.Backup.Schedule.Minute))
-	logger.PrintLog("daemon", fmt.Sprintf("时区: %s", cfg.Backup.Schedule.Timezone))
+	logJobs(cfg)
+	startMetricsServer(cfg.Metrics.Listen)
+
+	d := newDaemon(cfgPath, cfg)
+	d.state = loadState()
+	// 控制接口地址修改后需要重启服务
+	if stop, err := control.Serve(cfg.Control, d); err != nil {
+		logger.PrintLog("error", fmt.Sprintf("控制接口未启用: %v", err))
+	} else {
+		defer stop()
+	}
 	logger.PrintLog("daemon", "配置文件监控已启用")
 
 	// 创建配置文件监控器
@@ -69,47 +84,392 @@ func Run(cfgPath string) {
 		}
 	}()
 
+	// 系统休眠唤醒或时间调整后重新计算定时并检查错过的运行
+	clockJump := make(chan time.Duration, 1)
+	go watchClock(clockJump)
+
 	// 主循环
 	for {
-		nextRunTime := config.CalculateNextRunTime(cfg.Backup.Schedule)
+		cfg := d.config()
+		d.catchUp(cfg)
+		updateNextRuns(cfg.JobList())
+		nextRunTime, dueJobs := config.NextJobRun(cfg.JobList())
 		now := time.Now()
 
-		if cfg.Backup.Schedule.Enabled {
+		// 没有启用定时的任务时只等待配置变化
+		var timer <-chan time.Time
+		if len(dueJobs) > 0 {
 			duration := nextRunTime.Sub(now)
-			logger.PrintLog("daemon", fmt.Sprintf("下次执行时间: %s (等待 %v)",
-				nextRunTime.Format("2006-01-02 15:04:05"), duration))
-
-			select {
-			case <-time.After(duration):
-				logger.PrintLog("daemon", "开始执行定时备份...")
-				if err := task.RunBackup(cfg); err != nil {
-					logger.PrintLog("error", fmt.Sprintf("定时备份执行失败: %v", err))
-				}
-			case <-configReloadChan:
-				cfg = reloadConfigSafe(cfgPath, cfg)
-			case <-sigChan:
-				logger.PrintLog("daemon", "收到停止信号，正在退出...")
-				return
+			timer = time.After(duration)
+			msg := fmt.Sprintf("下次执行时间: %s [%s] (等待 %v)", nextRunTime.Format("2006-01-02 15:04:05"), jobNames(dueJobs), duration)
+			if d.paused() {
+				msg += "，定时调度已暂停"
 			}
+			logger.PrintLog("daemon", msg)
 		} else {
 			logger.PrintLog("daemon", "定时任务未启用，等待配置文件变化...")
+		}
+
+		select {
+		case <-timer:
+			d.fire(cfg, dueJobs, nextRunTime)
+		case drift := <-clockJump:
+			logger.PrintLog("daemon", fmt.Sprintf("检测到系统时间跳变 %v（休眠唤醒或时间调整），重新计算定时", drift.Round(time.Second)))
+		case <-configReloadChan:
+			if err := d.Reload(); err != nil {
+				logger.PrintLog("error", err.Error())
+			}
+		case <-d.changed:
+		case <-sigChan:
+			logger.PrintLog("daemon", "收到停止信号，正在退出...")
+			return
+		}
+	}
+}
+
+// daemon 后台服务的运行状态，主循环与控制接口共享
+type daemon struct {
+	cfgPath string
+	runner  *jobRunner
+	changed chan struct{}  // 配置或调度状态被控制接口修改后唤醒主循环
+	state   *scheduleState // 只在主循环中访问
+
+	mu       sync.Mutex
+	cfg      *config.Config
+	isPaused bool
+}
+
+func newDaemon(cfgPath string, cfg *config.Config) *daemon {
+	return &daemon{cfgPath: cfgPath, cfg: cfg, runner: newJobRunner(), changed: make(chan struct{}, 1)}
+}
+
+func (d *daemon) config() *config.Config {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cfg
+}
+
+func (d *daemon) paused() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.isPaused
+}
+
+// notify 唤醒主循环重新计算下次运行时间
+func (d *daemon) notify() {
+	select {
+	case d.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Status 返回服务状态，包括各任务的下次运行时间与正在执行的进度
+func (d *daemon) Status() control.Status {
+	cfg := d.config()
+	running := make(map[string]task.Progress)
+	for _, p := range task.Running() {
+		running[p.Job] = p
+	}
+
+	st := control.Status{PID: os.Getpid(), Paused: d.paused()}
+	for _, job := range cfg.JobList() {
+		js := control.JobStatus{Name: job.Name, Running: d.runner.isRunning(job.Name)}
+		if job.Schedule.Enabled {
+			js.NextRun = config.CalculateNextRunTime(job.Schedule)
+		}
+		if p, ok := running[job.Name]; ok {
+			js.Progress = &p
+			js.RetryPending = p.Phase == task.PhaseRetryWait
+		}
+		st.Jobs = append(st.Jobs, js)
+	}
+	return st
+}
+
+// Run 在后台立即执行任务，job 为空时执行全部任务
+func (d *daemon) Run(job string) (control.RunResult, error) {
+	cfg := d.config()
+	jobs := cfg.JobList()
+	if job != "" {
+		j, err := cfg.FindJob(job)
+		if err != nil {
+			return control.RunResult{}, err
+		}
+		jobs = []config.JobConfig{j}
+	}
+
+	res := control.RunResult{Started: []string{}, Skipped: []string{}}
+	for _, j := range jobs {
+		if d.runner.start(cfg, j, history.TriggerManual) {
+			res.Started = append(res.Started, j.Name)
+		} else {
+			res.Skipped = append(res.Skipped, j.Name)
+		}
+	}
+	return res, nil
+}
+
+// Reload 重新加载配置文件，失败时继续使用当前配置
+func (d *daemon) Reload() error {
+	newCfg, err := config.LoadConfig(d.cfgPath)
+	if err != nil {
+		return fmt.Errorf("配置重载失败: %w", err)
+	}
+	d.mu.Lock()
+	d.cfg = newCfg
+	d.mu.Unlock()
+	logger.PrintLog("daemon", "配置重载成功")
+	logJobs(newCfg)
+	d.notify()
+	return nil
+}
+
+// SetPaused 暂停或恢复定时调度，暂停状态不会持久化，服务重启后恢复调度
+func (d *daemon) SetPaused(paused bool) {
+	d.mu.Lock()
+	changed := d.isPaused != paused
+	d.isPaused = paused
+	d.mu.Unlock()
+	if !changed {
+		return
+	}
+	if paused {
+		logger.PrintLog("daemon", "定时调度已暂停")
+	} else {
+		logger.PrintLog("daemon", "定时调度已恢复")
+	}
+	d.notify()
+}
+
+// fire 处理定时器触发：记录并启动到期的任务
+// 定时器按单调时钟计时，系统休眠期间不走，唤醒后才会延迟触发：启用 catch_up 的任务交给补跑策略处理（受 max_lateness 限制），
+// 未启用的任务仍按定时执行，不会因为延迟而被跳过
+func (d *daemon) fire(cfg *config.Config, due []config.JobConfig, at time.Time) {
+	var jobs []config.JobConfig
+	if late := time.Since(at); late > clockJumpThreshold {
+		var deferred []config.JobConfig
+		for _, job := range due {
+			if job.Schedule.CatchUp == config.CatchUpOnce {
+				deferred = append(deferred, job)
+			} else {
+				jobs = append(jobs, job)
+			}
+		}
+		msg := fmt.Sprintf("定时触发延迟了 %v（系统可能曾休眠）", late.Round(time.Second))
+		if len(deferred) > 0 {
+			msg += fmt.Sprintf("，任务 [%s] 按 catch_up 策略处理", jobNames(deferred))
+		}
+		logger.PrintLog("daemon", msg)
+	} else {
+		jobs = due
+	}
+	if len(jobs) == 0 {
+		return
+	}
+
+	for _, job := range jobs {
+		d.state.Jobs[job.Name] = jobState{LastRun: at, Schedule: job.Schedule.Describe()}
+	}
+	d.state.save()
+	if d.paused() {
+		logger.PrintLog("daemon", fmt.Sprintf("定时调度已暂停，跳过本次定时备份 [%s]", jobNames(jobs)))
+		return
+	}
+	for _, job := range jobs {
+		d.runner.start(cfg, job, history.TriggerSchedule)
+	}
+}
+
+// catchUp 检查各任务在上一次定时运行之后是否错过了定时运行（服务停止或系统休眠），按 catch_up 策略补跑一次或记录警告
+func (d *daemon) catchUp(cfg *config.Config) {
+	now := time.Now()
+	changed := false
+	for _, job := range cfg.JobList() {
+		if !job.Schedule.Enabled {
+			continue
+		}
+		desc := job.Schedule.Describe()
+		js, ok := d.state.Jobs[job.Name]
+		if !ok || js.Schedule != desc || js.LastRun.After(now) {
+			// 新任务、定时配置变化或系统时间回拨后，从现在开始计算
+			d.state.Jobs[job.Name] = jobState{LastRun: now, Schedule: desc}
+			changed = true
+			continue
+		}
+		missed, ok := config.MissedRun(job.Schedule, js.LastRun, now)
+		if !ok {
+			continue
+		}
+		d.state.Jobs[job.Name] = jobState{LastRun: missed, Schedule: desc}
+		changed = true
+
+		late := now.Sub(missed).Round(time.Minute)
+		slot := missed.Format("2006-01-02 15:04")
+		switch {
+		case d.paused():
+			logger.PrintLog("daemon", fmt.Sprintf("定时调度已暂停，不补跑任务 [%s] 错过的 %s 定时备份", job.Name, slot))
+		case job.Schedule.CatchUp != config.CatchUpOnce:
+			logger.PrintLog("warn", fmt.Sprintf("任务 [%s] 错过了 %s 的定时备份（已过 %v），未启用 catch_up，不补跑", job.Name, slot, late))
+		case job.Schedule.MaxLateness() > 0 && late > job.Schedule.MaxLateness():
+			logger.PrintLog("warn", fmt.Sprintf("任务 [%s] 错过了 %s 的定时备份（已过 %v），超过最大延迟 %v，不补跑",
+				job.Name, slot, late, job.Schedule.MaxLateness()))
+		default:
+			logger.PrintLog("daemon", fmt.Sprintf("任务 [%s] 错过了 %s 的定时备份（已过 %v），立即补跑", job.Name, slot, late))
+			d.runner.start(cfg, job, history.TriggerCatchUp)
+		}
+	}
+	if changed {
+		d.state.save()
+	}
+}
+
+// clockJumpThreshold 挂钟时间与单调时钟的差异超过该值时视为时间跳变
+const clockJumpThreshold = time.Minute
+
+// watchClock 每分钟比较一次挂钟时间与单调时钟的流逝，系统休眠期间单调时钟不走，唤醒后两者出现差异
+func watchClock(jumps chan<- time.Duration) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	last := time.Now()
+	for range ticker.C {
+		now := time.Now()
+		if drift := now.Round(0).Sub(last.Round(0)) - now.Sub(last); drift > clockJumpThreshold || drift < -clockJumpThreshold {
 			select {
-			case <-configReloadChan:
-				cfg = reloadConfigSafe(cfgPath, cfg)
-			case <-sigChan:
-				logger.PrintLog("daemon", "收到停止信号，正在退出...")
-				return
+			case jumps <- drift:
+			default:
 			}
 		}
+		last = now
+	}
+}
+
+// 执行任务与取消重试等待的函数，测试中替换
+var (
+	runJob          = task.RunJob
+	runJobWithRetry = task.RunJobWithRetry
+	cancelRetry     = task.CancelRetry
+)
+
+// jobRunner 在独立协程中执行任务，并保证同一任务不会重叠执行
+type jobRunner struct {
+	mu      sync.Mutex
+	running map[string]bool
+	queued  map[string]queuedRun // 取消重试等待后，在当前执行结束时立即启动的手动备份
+}
+
+// queuedRun 等待启动的任务
+type queuedRun struct {
+	cfg *config.Config
+	job config.JobConfig
+}
+
+func newJobRunner() *jobRunner {
+	return &jobRunner{running: make(map[string]bool), queued: make(map[string]queuedRun)}
+}
+
+func (r *jobRunner) isRunning(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running[name]
+}
+
+// start 启动任务并返回 true；若该任务上一次执行尚未结束则跳过本次并返回 false
+func (r *jobRunner) start(cfg *config.Config, job config.JobConfig, trigger string) bool {
+	kind := "定时备份"
+	switch trigger {
+	case history.TriggerManual:
+		kind = "手动备份"
+	case history.TriggerCatchUp:
+		kind = "补跑备份"
+	}
+
+	r.mu.Lock()
+	if r.running[job.Name] {
+		// 任务正在等待重试时，手动备份取消等待并在其结束后立即执行
+		if trigger == history.TriggerManual && cancelRetry(job.Name) {
+			r.queued[job.Name] = queuedRun{cfg: cfg, job: job}
+			r.mu.Unlock()
+			logger.PrintLog("daemon", fmt.Sprintf("任务 [%s] 正在等待重试，取消等待并立即执行%s", job.Name, kind))
+			return true
+		}
+		r.mu.Unlock()
+		logger.PrintLog("warn", fmt.Sprintf("任务 [%s] 上一次执行尚未结束，跳过本次%s", job.Name, kind))
+		return false
+	}
+	r.running[job.Name] = true
+	r.mu.Unlock()
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.running, job.Name)
+			next, ok := r.queued[job.Name]
+			delete(r.queued, job.Name)
+			r.mu.Unlock()
+			if ok {
+				r.start(next.cfg, next.job, history.TriggerManual)
+			}
+		}()
+
+		logger.PrintLog("daemon", fmt.Sprintf("开始执行%s [%s]...", kind, job.Name))
+		// 定时与补跑备份失败后按 retry 策略重试，手动备份失败直接返回
+		run := runJobWithRetry
+		if trigger == history.TriggerManual {
+			run = runJob
+		}
+		if err := run(cfg, job, trigger); err != nil {
+			logger.PrintLog("error", fmt.Sprintf("%s执行失败 [%s]: %v", kind, job.Name, err))
+		}
+	}()
+	return true
+}
+
+// logJobs 打印各任务的定时配置
+func logJobs(cfg *config.Config) {
+	for _, job := range cfg.JobList() {
+		if !job.Schedule.Enabled {
+			logger.PrintLog("daemon", fmt.Sprintf("任务 [%s]: 定时未启用", job.Name))
+			continue
+		}
+		logger.PrintLog("daemon", fmt.Sprintf("任务 [%s]: %s", job.Name, job.Schedule.Describe()))
+	}
+}
+
+// startMetricsServer 在后台启动 Prometheus 指标监听，修改监听地址需要重启服务
+func startMetricsServer(addr string) {
+	if addr == "" {
+		return
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.PrintLog("error", fmt.Sprintf("启动监控指标监听失败: %v", err))
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	logger.PrintLog("daemon", fmt.Sprintf("监控指标已启用: http://%s/metrics", ln.Addr()))
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			logger.PrintLog("error", fmt.Sprintf("监控指标服务异常退出: %v", err))
+		}
+	}()
+}
+
+// updateNextRuns 更新各任务下次定时运行时间的指标
+func updateNextRuns(jobs []config.JobConfig) {
+	metrics.NextRunTimestamp.Reset()
+	for _, job := range jobs {
+		if job.Schedule.Enabled {
+			metrics.NextRunTimestamp.Set(float64(config.CalculateNextRunTime(job.Schedule).Unix()), job.Name)
+		}
 	}
 }
 
-func reloadConfigSafe(cfgPath string, currentCfg *config.Config) *config.Config {
-	newCfg, err := config.LoadConfig(cfgPath)
-	if err == nil {
-		logger.PrintLog("daemon", "配置重载成功")
-		return newCfg
+func jobNames(jobs []config.JobConfig) string {
+	names := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		names = append(names, job.Name)
 	}
-	logger.PrintLog("error", fmt.Sprintf("配置重载失败: %v", err))
-	return currentCfg
+	return strings.Join(names, ", ")
 }
@@ -0,0 +1,195 @@
+package scheduler
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/history"
+)
+
+// startedRun 记录被启动的任务及其触发方式
+type startedRun struct {
+	job     string
+	trigger string
+}
+
+// stubRuns 替换任务执行函数，启动的任务写入返回的通道，release 关闭前任务保持运行
+func stubRuns(t *testing.T, release <-chan struct{}) <-chan startedRun {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	oldRun, oldRetry, oldCancel, oldState := runJob, runJobWithRetry, cancelRetry, StatePath
+	t.Cleanup(func() { runJob, runJobWithRetry, cancelRetry, StatePath = oldRun, oldRetry, oldCancel, oldState })
+	StatePath = filepath.Join(t.TempDir(), "schedule.json")
+
+	started := make(chan startedRun, 10)
+	run := func(cfg *config.Config, job config.JobConfig, trigger string) error {
+		started <- startedRun{job: job.Name, trigger: trigger}
+		if release != nil {
+			<-release
+		}
+		return nil
+	}
+	runJob, runJobWithRetry = run, run
+	cancelRetry = func(string) bool { return false }
+	return started
+}
+
+// expectRuns 等待指定的任务启动（不要求顺序），之后确认没有其他任务启动
+func expectRuns(t *testing.T, started <-chan startedRun, want ...startedRun) {
+	t.Helper()
+	pending := make(map[startedRun]int)
+	for _, w := range want {
+		pending[w]++
+	}
+	for range want {
+		select {
+		case got := <-started:
+			if pending[got] == 0 {
+				t.Errorf("Unexpected run %+v, want %+v", got, want)
+			}
+			pending[got]--
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected %+v to start", want)
+		}
+	}
+	select {
+	case got := <-started:
+		t.Errorf("Unexpected run %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func newTestDaemon(jobs ...config.JobConfig) (*daemon, *config.Config) {
+	cfg := &config.Config{Jobs: jobs}
+	d := newDaemon("", cfg)
+	d.state = &scheduleState{Jobs: make(map[string]jobState)}
+	return d, cfg
+}
+
+// dailyJob 每天在 at 所在的时分运行的任务
+func dailyJob(name string, at time.Time, catchUp string, maxLateness int) config.JobConfig {
+	job := config.JobConfig{Name: name}
+	job.Schedule = config.ScheduleConfig{Enabled: true, Hour: at.Hour(), Minute: at.Minute(), CatchUp: catchUp, MaxLatenessMinutes: maxLateness}
+	return job
+}
+
+func TestFireOnTime(t *testing.T) {
+	started := stubRuns(t, nil)
+	at := time.Now()
+	a, b := dailyJob("a", at, "", 0), dailyJob("b", at, config.CatchUpOnce, 0)
+	d, cfg := newTestDaemon(a, b)
+
+	d.fire(cfg, []config.JobConfig{a, b}, at)
+	expectRuns(t, started, startedRun{"a", history.TriggerSchedule}, startedRun{"b", history.TriggerSchedule})
+	for _, name := range []string{"a", "b"} {
+		if got := d.state.Jobs[name].LastRun; !got.Equal(at) {
+			t.Errorf("Job %s last run = %v, want %v", name, got, at)
+		}
+	}
+}
+
+func TestFireLate(t *testing.T) {
+	started := stubRuns(t, nil)
+	at := time.Now().Add(-2 * time.Hour)
+	a := dailyJob("a", at, "", 0)
+	b := dailyJob("b", at, config.CatchUpOnce, 60)
+	d, cfg := newTestDaemon(a, b)
+	d.state.Jobs["b"] = jobState{LastRun: at.Add(-24 * time.Hour), Schedule: b.Schedule.Describe()}
+
+	// 未启用 catch_up 的任务延迟触发后仍然执行，启用的任务交给补跑策略
+	d.fire(cfg, []config.JobConfig{a, b}, at)
+	expectRuns(t, started, startedRun{"a", history.TriggerSchedule})
+	if got := d.state.Jobs["b"].LastRun; got.Equal(at) {
+		t.Error("Job with catch_up should be left to the catch-up check")
+	}
+
+	// 延迟超过 max_lateness_minutes，补跑策略不再补跑
+	d.catchUp(cfg)
+	expectRuns(t, started)
+	if got := d.state.Jobs["b"].LastRun; !got.Equal(at.Truncate(time.Minute)) {
+		t.Errorf("Missed slot should be recorded, got %v", got)
+	}
+}
+
+func TestFirePaused(t *testing.T) {
+	started := stubRuns(t, nil)
+	at := time.Now()
+	a := dailyJob("a", at, "", 0)
+	d, cfg := newTestDaemon(a)
+	d.SetPaused(true)
+
+	d.fire(cfg, []config.JobConfig{a}, at)
+	expectRuns(t, started)
+	if got := d.state.Jobs["a"].LastRun; !got.Equal(at) {
+		t.Errorf("Paused run should still be recorded, got %v", got)
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	started := stubRuns(t, nil)
+	slot := time.Now().Add(-2 * time.Hour)
+	jobs := []config.JobConfig{
+		dailyJob("none", slot, "", 0),
+		dailyJob("once", slot, config.CatchUpOnce, 0),
+		dailyJob("too-late", slot, config.CatchUpOnce, 60),
+		dailyJob("new", slot, config.CatchUpOnce, 0),
+	}
+	d, cfg := newTestDaemon(jobs...)
+	for _, job := range jobs[:3] {
+		d.state.Jobs[job.Name] = jobState{LastRun: slot.Add(-24 * time.Hour), Schedule: job.Schedule.Describe()}
+	}
+
+	d.catchUp(cfg)
+	expectRuns(t, started, startedRun{"once", history.TriggerCatchUp})
+	for _, job := range jobs[:3] {
+		if got := d.state.Jobs[job.Name].LastRun; !got.Equal(slot.Truncate(time.Minute)) {
+			t.Errorf("Job %s should record the missed slot, got %v", job.Name, got)
+		}
+	}
+	// 新任务从当前时间开始计算，不补跑
+	if got := d.state.Jobs["new"].LastRun; time.Since(got) > time.Minute {
+		t.Errorf("New job should start from now, got %v", got)
+	}
+
+	// 已处理的错过运行不会重复补跑
+	d.catchUp(cfg)
+	expectRuns(t, started)
+}
+
+func TestJobRunner(t *testing.T) {
+	release := make(chan struct{})
+	started := stubRuns(t, release)
+	cfg := &config.Config{}
+	job := config.JobConfig{Name: "a"}
+	r := newJobRunner()
+
+	if !r.start(cfg, job, history.TriggerSchedule) {
+		t.Fatal("Expected the first run to start")
+	}
+	expectRuns(t, started, startedRun{"a", history.TriggerSchedule})
+
+	// 上一次执行尚未结束时跳过
+	if r.start(cfg, job, history.TriggerSchedule) {
+		t.Error("Overlapping scheduled run should be skipped")
+	}
+	if r.start(cfg, job, history.TriggerManual) {
+		t.Error("Manual run should be skipped when the job is not waiting to retry")
+	}
+
+	// 等待重试时，手动备份取消等待并在当前执行结束后启动
+	cancelRetry = func(string) bool { return true }
+	if !r.start(cfg, job, history.TriggerManual) {
+		t.Error("Manual run should cancel the retry wait")
+	}
+	expectRuns(t, started)
+	close(release)
+	expectRuns(t, started, startedRun{"a", history.TriggerManual})
+	for deadline := time.Now().Add(2 * time.Second); r.isRunning("a"); {
+		if time.Now().After(deadline) {
+			t.Fatal("Job should finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
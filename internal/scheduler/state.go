@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"backup-go/internal/logger"
+)
+
+// StatePath 记录各任务上一次定时运行时间的文件，服务重启或系统休眠唤醒后据此检查错过的定时备份
+var StatePath = filepath.Join("state", "schedule.json")
+
+// scheduleState 各任务的定时状态，只在主循环中访问
+type scheduleState struct {
+	Jobs map[string]jobState `json:"jobs"`
+}
+
+// jobState 任务上一次已处理（执行、补跑或确认跳过）的定时运行
+type jobState struct {
+	LastRun  time.Time `json:"last_run"`
+	Schedule string    `json:"schedule"` // 定时配置的描述，配置变化后从当前时间重新开始计算
+}
+
+// loadState 读取定时状态，文件不存在或损坏时返回空状态
+func loadState() *scheduleState {
+	s := &scheduleState{Jobs: make(map[string]jobState)}
+	data, err := os.ReadFile(StatePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.PrintLog("warn", fmt.Sprintf("读取定时状态失败: %v", err))
+		}
+		return s
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		logger.PrintLog("warn", fmt.Sprintf("定时状态文件损坏，已忽略: %v", err))
+	}
+	if s.Jobs == nil {
+		s.Jobs = make(map[string]jobState)
+	}
+	return s
+}
+
+// save 先写临时文件再重命名，避免写入中断留下不完整的状态
+func (s *scheduleState) save() {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(StatePath), 0755)
+	}
+	if err == nil {
+		tmp := StatePath + ".tmp"
+		if err = os.WriteFile(tmp, data, 0644); err == nil {
+			err = os.Rename(tmp, StatePath)
+		}
+	}
+	if err != nil {
+		logger.PrintLog("warn", fmt.Sprintf("保存定时状态失败: %v", err))
+	}
+}
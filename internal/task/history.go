@@ -0,0 +1,81 @@
+package task
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"backup-go/internal/history"
+)
+
+// DefaultHistoryLimit history 命令默认显示的记录条数
+const DefaultHistoryLimit = 20
+
+// RunHistory 打印最近的运行记录，job 为空时显示全部任务
+func RunHistory(job string, limit int) error {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	records, err := history.Load(job, limit)
+	if err != nil {
+		return err
+	}
+	return PrintHistory(os.Stdout, records)
+}
+
+// PrintHistory 以表格形式输出运行记录
+func PrintHistory(out io.Writer, records []history.Record) error {
+	if len(records) == 0 {
+		fmt.Fprintln(out, "暂无运行记录")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "开始时间\t任务\t触发\t状态\t耗时\t原始大小\t写入大小\t文件 (跳过)\t备份")
+	for _, r := range records {
+		trigger := triggerText(r.Trigger)
+		if r.Attempt > 1 {
+			trigger += fmt.Sprintf(" (第 %d 次)", r.Attempt)
+		}
+		key := "-"
+		if r.Key != "" {
+			key = path.Base(r.Key)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d (%d)\t%s\n",
+			r.StartTime.Format("2006-01-02 15:04:05"), r.Job, trigger, statusText(r.Status),
+			r.EndTime.Sub(r.StartTime).Round(time.Second), humanize.Bytes(uint64(r.OriginalSize)), humanize.Bytes(uint64(r.CompressedSize)),
+			r.FilesProcessed, r.FilesSkipped, key)
+		if r.Error != "" {
+			fmt.Fprintf(w, "\t错误: %s\n", r.Error)
+		}
+	}
+	return w.Flush()
+}
+
+func triggerText(trigger string) string {
+	switch trigger {
+	case history.TriggerManual:
+		return "手动"
+	case history.TriggerSchedule:
+		return "定时"
+	case history.TriggerCatchUp:
+		return "补跑"
+	}
+	return trigger
+}
+
+func statusText(status string) string {
+	switch status {
+	case StatusSuccess:
+		return "✅ 成功"
+	case StatusFailure:
+		return "❌ 失败"
+	case StatusSkipped:
+		return "○ 跳过"
+	}
+	return status
+}
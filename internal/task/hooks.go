@@ -0,0 +1,109 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/logger"
+)
+
+// 钩子执行阶段，通过 BACKUP_HOOK 传给命令
+const (
+	HookPre     = "pre"
+	HookPost    = "post"
+	HookFailure = "on_failure"
+)
+
+// maxHookOutput 每个钩子命令记录到日志的输出上限
+const maxHookOutput = 4096
+
+// env 返回传给钩子命令的环境变量
+func (r *RunInfo) env(stage string) []string {
+	errMsg := ""
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+	return []string{
+		"BACKUP_HOOK=" + stage,
+		"BACKUP_JOB=" + r.Job,
+		"BACKUP_PREFIX=" + r.Prefix,
+		"BACKUP_STATUS=" + r.Status,
+		"BACKUP_ARCHIVE=" + r.Archive,
+		"BACKUP_KEY=" + r.Key,
+		"BACKUP_SIZE=" + strconv.FormatInt(r.Size, 10),
+		"BACKUP_DATA_SIZE=" + strconv.FormatInt(r.DataSize, 10),
+		"BACKUP_FILES=" + strconv.FormatInt(r.Stats.Processed, 10),
+		"BACKUP_ERROR=" + errMsg,
+		"BACKUP_START=" + r.StartTime.Format(time.RFC3339),
+		"BACKUP_DURATION=" + strconv.Itoa(int(time.Since(r.StartTime).Seconds())),
+	}
+}
+
+// runHooks 依次执行一个阶段的钩子命令，任一命令失败时不再执行后续命令
+func runHooks(stage string, commands []string, hooks config.HooksConfig, info *RunInfo) error {
+	for _, command := range commands {
+		if err := runHook(stage, command, hooks.HookTimeout(), info.env(stage)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHook 执行单个钩子命令，超时后结束命令及其启动的子进程
+func runHook(stage, command string, timeout time.Duration, env []string) error {
+	logger.PrintLog("hook", fmt.Sprintf("执行 %s 钩子: %s", stage, command))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := hookCommand(ctx, command)
+	cmd.Env = append(os.Environ(), env...)
+	out := &limitedBuffer{limit: maxHookOutput}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	// 子进程结束后仍占用输出管道时，不无限等待
+	cmd.WaitDelay = 5 * time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	if output := strings.TrimSpace(out.String()); output != "" {
+		logger.PrintLog("hook", "输出:\n"+output)
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%s 钩子执行超时 (%s): %s", stage, timeout, command)
+	case err != nil:
+		return fmt.Errorf("%s 钩子执行失败: %s: %w", stage, command, err)
+	}
+	logger.PrintLog("hook", fmt.Sprintf("%s 钩子执行完成，耗时 %s", stage, time.Since(start).Round(time.Millisecond)))
+	return nil
+}
+
+// limitedBuffer 只保留前 limit 字节的输出，超出部分丢弃
+type limitedBuffer struct {
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if n := b.limit - len(b.buf); n < len(p) {
+		b.buf = append(b.buf, p[:max(n, 0)]...)
+		b.truncated = true
+	} else {
+		b.buf = append(b.buf, p...)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return string(b.buf) + "\n...（输出过长，已截断）"
+	}
+	return string(b.buf)
+}
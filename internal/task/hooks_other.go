@@ -0,0 +1,13 @@
+//go:build !unix
+
+package task
+
+import (
+	"context"
+	"os/exec"
+)
+
+// hookCommand 通过 cmd /C 执行命令，超时时只能结束命令解释器本身
+func hookCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", command)
+}
@@ -0,0 +1,52 @@
+//go:build unix
+
+package task
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"backup-go/internal/config"
+)
+
+func TestRunHooksEnv(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "env.txt")
+	info := &RunInfo{Job: "db", Status: StatusFailure, Archive: "backup-20240101-020000.tar.zst", Size: 42, Err: errors.New("上传失败"), StartTime: time.Now()}
+	hooks := config.HooksConfig{}
+
+	cmd := `echo "$BACKUP_HOOK $BACKUP_JOB $BACKUP_STATUS $BACKUP_ARCHIVE $BACKUP_SIZE $BACKUP_ERROR" > ` + out
+	if err := runHooks(HookFailure, []string{cmd}, hooks, info); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "on_failure db failure backup-20240101-020000.tar.zst 42 上传失败\n"; string(got) != want {
+		t.Errorf("Hook env = %q, want %q", got, want)
+	}
+
+	// 失败的命令中止后续命令
+	if err := runHooks(HookPre, []string{"exit 3", "touch " + out + ".next"}, hooks, info); err == nil {
+		t.Error("Expected failing hook to return an error")
+	}
+	if _, err := os.Stat(out + ".next"); !os.IsNotExist(err) {
+		t.Error("Hooks after a failing command should not run")
+	}
+}
+
+func TestRunHookTimeout(t *testing.T) {
+	start := time.Now()
+	// 后台子进程同样会被结束，不会拖住命令的输出管道
+	err := runHook(HookPre, "sleep 30 & sleep 30", 200*time.Millisecond, nil)
+	if err == nil || !strings.Contains(err.Error(), "超时") {
+		t.Fatalf("Expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Timed out hook took %s to return", elapsed)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package task
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// hookCommand 通过 sh -c 执行命令，命令在独立的进程组中运行，超时时整组结束
+func hookCommand(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	return cmd
+}
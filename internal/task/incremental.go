@@ -0,0 +1,87 @@
+package task
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/core/archiver"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/core/uploader"
+	"backup-go/internal/logger"
+)
+
+// incrementalBase 选择增量备份所基于的上一个备份，返回其清单与文件名
+// 未开启增量、没有可用的上一个备份或距上次完整备份已超过间隔时返回 nil，表示执行完整备份
+func incrementalBase(cfg *config.Config, store storage.Storage, job config.JobConfig, sources []archiver.Source, encrypted bool) (*archiver.Manifest, string) {
+	if !job.Incremental {
+		return nil, ""
+	}
+	backups, err := uploader.ListBackups(store, job.Prefix)
+	if err != nil {
+		logger.PrintLog("warn", fmt.Sprintf("获取备份列表失败，执行完整备份: %v", err))
+		return nil, ""
+	}
+
+	// 最新备份所在增量链的完整备份
+	full := -1
+	for i, b := range backups {
+		if !b.Incremental {
+			full = i
+			break
+		}
+	}
+	if full < 0 {
+		logger.PrintLog("backup", "没有可用的完整备份，执行完整备份")
+		return nil, ""
+	}
+	if interval := job.FullInterval(); backups[full].Time.Before(time.Now().Add(-interval)) {
+		logger.PrintLog("backup", fmt.Sprintf("距上次完整备份已超过 %d 天，执行完整备份", int(interval.Hours()/24)))
+		return nil, ""
+	}
+
+	latest := backups[0]
+	base, err := loadManifest(cfg, store, latest.Key)
+	if err != nil {
+		logger.PrintLog("warn", fmt.Sprintf("读取上一个备份的清单失败，执行完整备份: %v", err))
+		return nil, ""
+	}
+	if base.Encrypted != encrypted || !slices.Equal(base.Sources, sources) {
+		logger.PrintLog("backup", "备份源或加密配置已变化，执行完整备份")
+		return nil, ""
+	}
+	return base, path.Base(latest.Key)
+}
+
+// chainItem 增量链中的一个备份及其相对上一个备份删除的条目
+type chainItem struct {
+	backup  uploader.BackupObject
+	deleted []string
+}
+
+// backupChain 返回恢复指定备份需要依次解压的备份，从完整备份开始，到 target 结束
+func backupChain(cfg *config.Config, store storage.Storage, backups []uploader.BackupObject, target uploader.BackupObject) ([]chainItem, error) {
+	var chain []chainItem
+	b := target
+	for b.Incremental {
+		if len(chain) >= len(backups) {
+			return nil, fmt.Errorf("增量链存在循环引用: %s", target.Key)
+		}
+		m, err := loadManifest(cfg, store, b.Key)
+		if err != nil {
+			return nil, fmt.Errorf("读取增量备份 %s 的清单失败: %w", b.Key, err)
+		}
+		chain = append(chain, chainItem{backup: b, deleted: m.Deleted})
+
+		i := slices.IndexFunc(backups, func(o uploader.BackupObject) bool { return path.Base(o.Key) == m.Base })
+		if i < 0 {
+			return nil, fmt.Errorf("增量链不完整，缺少 %s 所基于的备份 %s", path.Base(b.Key), m.Base)
+		}
+		b = backups[i]
+	}
+	chain = append(chain, chainItem{backup: b})
+	slices.Reverse(chain)
+	return chain, nil
+}
@@ -0,0 +1,71 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/lock"
+	"backup-go/internal/logger"
+)
+
+// lockJob 获取任务的本机锁，配置了 lock.remote 时再获取存储中的远程锁，返回释放锁的函数
+// 锁被占用时 wait 模式等待直到超时，skip 与 fail 模式直接返回包含持有者信息的 *lock.LockedError
+func lockJob(cfg *config.Config, job config.JobConfig, info *RunInfo) (func(), error) {
+	deadline := time.Now().Add(cfg.Lock.WaitTimeout())
+	waiting := false
+	for {
+		release, err := acquireLocks(cfg, job, info)
+		if err == nil || !errors.Is(err, lock.ErrLocked) || cfg.Lock.OnConflict != config.LockWait {
+			return release, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("等待超时 (%s): %w", cfg.Lock.WaitTimeout(), err)
+		}
+		if !waiting {
+			logger.PrintLog("lock", fmt.Sprintf("%v，等待其结束...", err))
+			info.setPhase(PhaseWaitLock)
+			waiting = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// lockPollInterval wait 模式下重试获取锁的间隔
+var lockPollInterval = 5 * time.Second
+
+func acquireLocks(cfg *config.Config, job config.JobConfig, info *RunInfo) (func(), error) {
+	holder := lock.NewInfo(job.Name, info.Trigger)
+	local, err := lock.AcquireFile(holder)
+	if err != nil {
+		return nil, err
+	}
+	releaseLocal := func() {
+		if err := local.Release(); err != nil {
+			logger.PrintLog("warn", fmt.Sprintf("释放锁文件失败: %v", err))
+		}
+	}
+	if !cfg.Lock.Remote {
+		return releaseLocal, nil
+	}
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		releaseLocal()
+		return nil, fmt.Errorf("创建存储客户端失败: %w", err)
+	}
+	remote, err := lock.AcquireRemote(store, path.Join(job.Prefix, lock.RemoteName), holder, cfg.Lock.RemoteStale())
+	if err != nil {
+		releaseLocal()
+		return nil, err
+	}
+	return func() {
+		if err := remote.Release(); err != nil {
+			logger.PrintLog("warn", fmt.Sprintf("释放远程锁失败: %v", err))
+		}
+		releaseLocal()
+	}, nil
+}
@@ -0,0 +1,58 @@
+package task
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 任务运行阶段，可通过控制接口查询
+const (
+	PhaseWaitLock  = "wait_lock" // 等待同一任务的另一次备份结束
+	PhasePreHooks  = "pre_hooks"
+	PhaseDump      = "dump"    // 导出数据库
+	PhaseArchive   = "archive" // 打包压缩，流式上传时同时上传
+	PhaseUpload    = "upload"
+	PhasePrune     = "prune"
+	PhasePostHooks = "post_hooks" // 包括 on_failure 与 post 钩子
+	PhaseRetryWait = "retry_wait" // 失败后等待重试
+)
+
+// Progress 正在执行的任务的进度
+type Progress struct {
+	Job       string    `json:"job"`
+	Trigger   string    `json:"trigger"`
+	Phase     string    `json:"phase"`
+	StartTime time.Time `json:"start_time"`
+}
+
+var (
+	progressMu sync.Mutex
+	progress   = make(map[string]Progress)
+)
+
+// Running 返回当前进程中正在执行的任务，按任务名排序
+func Running() []Progress {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	list := make([]Progress, 0, len(progress))
+	for _, p := range progress {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Job < list[j].Job })
+	return list
+}
+
+// setPhase 记录任务进入的阶段
+func (r *RunInfo) setPhase(phase string) {
+	progressMu.Lock()
+	progress[r.Job] = Progress{Job: r.Job, Trigger: r.Trigger, Phase: phase, StartTime: r.StartTime}
+	progressMu.Unlock()
+}
+
+// finish 任务结束后移除进度
+func (r *RunInfo) finish() {
+	progressMu.Lock()
+	delete(progress, r.Job)
+	progressMu.Unlock()
+}
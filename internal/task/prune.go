@@ -0,0 +1,31 @@
+package task
+
+import (
+	"fmt"
+
+	"backup-go/internal/config"
+	"backup-go/internal/core/repository"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/core/uploader"
+)
+
+// RunPrune 按保留策略清理任务的过期备份，dryRun 为 true（或配置了 prune_dry_run）时只输出清理计划
+func RunPrune(cfg *config.Config, job config.JobConfig, dryRun bool) error {
+	store, err := storage.New(cfg)
+	if err != nil {
+		return fmt.Errorf("创建存储客户端失败: %w", err)
+	}
+
+	opts := PruneOptions(cfg.Cos)
+	opts.DryRun = opts.DryRun || dryRun
+	if job.Format != config.FormatRepository {
+		return uploader.DeleteExpiredBackups(store, job.Prefix, RetentionPolicy(job), opts)
+	}
+
+	repo, err := repository.Open(store, job.Prefix, cfg.Encryption)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+	return repo.Prune(RetentionPolicy(job), opts)
+}
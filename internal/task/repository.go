@@ -0,0 +1,95 @@
+package task
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"backup-go/internal/config"
+	"backup-go/internal/core/archiver"
+	"backup-go/internal/core/repository"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/core/uploader"
+	"backup-go/internal/logger"
+)
+
+// runRepositoryJob 以分块仓库格式执行一次备份，并清理过期快照与不再引用的数据块
+func runRepositoryJob(cfg *config.Config, store storage.Storage, job config.JobConfig, sources []archiver.Source, streams []archiver.Stream, info *RunInfo) error {
+	repo, err := repository.Open(store, job.Prefix, cfg.Encryption)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	info.setPhase(PhaseArchive)
+	opts := BackupOptions(job.BackupConfig)
+	opts.Stats = &info.Stats
+	opts.Streams = streams
+	opts.TempDir = TempDir
+	key, stats, err := repo.Backup(sources, opts)
+	if err != nil {
+		if strings.Contains(err.Error(), "为空，跳过备份") {
+			logger.PrintLog("skip", err.Error())
+			info.Status = StatusSkipped
+			return nil
+		}
+		return fmt.Errorf("分块备份失败: %w", err)
+	}
+	info.Archive, info.Key, info.Size, info.DataSize = path.Base(key), key, stats.NewBytes, stats.Bytes
+
+	info.setPhase(PhasePrune)
+	if err := repo.Prune(RetentionPolicy(job), PruneOptions(cfg.Cos)); err != nil {
+		logger.PrintLog("warn", fmt.Sprintf("清理过期快照失败: %v", err))
+	}
+
+	logger.PrintLog("done", fmt.Sprintf("任务 [%s] 备份流程完成", job.Name))
+	return nil
+}
+
+// ListBackups 列出任务的备份（按时间从新到旧排序），分块仓库格式下返回快照
+func ListBackups(cfg *config.Config, store storage.Storage, job config.JobConfig) ([]uploader.BackupObject, error) {
+	if job.Format != config.FormatRepository {
+		return uploader.ListBackups(store, job.Prefix)
+	}
+	repo, err := repository.Open(store, job.Prefix, cfg.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Close()
+
+	snapshots, err := repo.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]uploader.BackupObject, 0, len(snapshots))
+	for _, s := range snapshots {
+		backups = append(backups, uploader.BackupObject{Key: s.Key, Size: s.Size, Time: s.Time})
+	}
+	return backups, nil
+}
+
+// restoreSnapshot 从分块仓库恢复快照
+func restoreSnapshot(cfg *config.Config, store storage.Storage, job config.JobConfig, key, targetDir string) error {
+	repo, err := repository.Open(store, job.Prefix, cfg.Encryption)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+	return repo.Restore(key, targetDir)
+}
+
+// checkSnapshot 读取快照引用的全部数据块并校验
+func checkSnapshot(cfg *config.Config, store storage.Storage, job config.JobConfig, key string) error {
+	repo, err := repository.Open(store, job.Prefix, cfg.Encryption)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+
+	stats, err := repo.Check(key)
+	if err != nil {
+		return err
+	}
+	logger.PrintLog("verify", fmt.Sprintf("校验通过: %d 个条目，%d 个文件，%d 字节", stats.Entries, stats.Files, stats.Bytes))
+	return nil
+}
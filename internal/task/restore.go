@@ -0,0 +1,120 @@
+package task
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/core/archiver"
+	"backup-go/internal/core/encryptor"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/core/uploader"
+	"backup-go/internal/logger"
+)
+
+const (
+	// LatestBackup 表示恢复最新一次备份
+	LatestBackup = "latest"
+)
+
+// RunRestore 下载任务的指定备份并解压到目标目录，增量备份会从其完整备份开始依次恢复整条链
+// name 可以是 "latest"、备份文件名（如 backup-20060102-150405.tar.zst）或完整对象键
+func RunRestore(cfg *config.Config, job config.JobConfig, name, targetDir string) error {
+	if targetDir == "" {
+		return fmt.Errorf("未指定恢复目标目录")
+	}
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		return fmt.Errorf("创建存储客户端失败: %w", err)
+	}
+
+	backups, err := ListBackups(cfg, store, job)
+	if err != nil {
+		return fmt.Errorf("获取备份列表失败: %w", err)
+	}
+	backup, err := selectBackup(backups, name)
+	if err != nil {
+		return err
+	}
+	logger.PrintLog("restore", fmt.Sprintf("选择备份: %s (%s)", backup.Key, backup.Time.Format("2006-01-02 15:04:05")))
+
+	applyBandwidth(cfg)
+	if job.Format == config.FormatRepository {
+		if err := restoreSnapshot(cfg, store, job, backup.Key, targetDir); err != nil {
+			return fmt.Errorf("恢复快照失败: %w", err)
+		}
+	} else if err := restoreArchive(cfg, store, backups, backup, targetDir); err != nil {
+		return err
+	}
+
+	logger.PrintLog("done", "恢复流程完成: "+targetDir)
+	return nil
+}
+
+// restoreArchive 下载并解压归档，增量备份需从完整备份开始依次恢复
+func restoreArchive(cfg *config.Config, store storage.Storage, backups []uploader.BackupObject, backup uploader.BackupObject, targetDir string) error {
+	chain, err := backupChain(cfg, store, backups, backup)
+	if err != nil {
+		return err
+	}
+
+	taskTempDir := filepath.Join(TempDir, fmt.Sprintf("restore-%d", time.Now().UnixNano()))
+	if err := os.MkdirAll(taskTempDir, 0755); err != nil {
+		return fmt.Errorf("创建任务临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(taskTempDir)
+
+	for i, item := range chain {
+		if len(chain) > 1 {
+			logger.PrintLog("restore", fmt.Sprintf("恢复增量链 (%d/%d): %s", i+1, len(chain), item.backup.Key))
+		}
+		archivePath := filepath.Join(taskTempDir, path.Base(item.backup.Key))
+		if err := uploader.Download(store, item.backup.Key, archivePath); err != nil {
+			return fmt.Errorf("下载失败: %w", err)
+		}
+		if err := extractArchive(cfg, archivePath, targetDir); err != nil {
+			return fmt.Errorf("解压失败: %w", err)
+		}
+		if err := archiver.RemoveEntries(targetDir, item.deleted); err != nil {
+			return err
+		}
+		_ = os.Remove(archivePath)
+	}
+	return nil
+}
+
+// extractArchive 解压本地备份文件，加密的备份自动解密
+func extractArchive(cfg *config.Config, archivePath, targetDir string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("打开备份文件失败: %w", err)
+	}
+	defer f.Close()
+
+	r, err := encryptor.NewReader(f, cfg.Encryption)
+	if err != nil {
+		return err
+	}
+	logger.PrintLog("restore", fmt.Sprintf("开始解压 %s → %s", archivePath, targetDir))
+	return archiver.ExtractReader(r, targetDir)
+}
+
+// selectBackup 按名称从备份列表中选出一个（列表已按时间从新到旧排序）
+func selectBackup(backups []uploader.BackupObject, name string) (uploader.BackupObject, error) {
+	if len(backups) == 0 {
+		return uploader.BackupObject{}, fmt.Errorf("前缀下没有可用的备份")
+	}
+	if name == "" || name == LatestBackup {
+		return backups[0], nil
+	}
+	for _, b := range backups {
+		if b.Key == name || path.Base(b.Key) == name {
+			return b, nil
+		}
+	}
+	return uploader.BackupObject{}, fmt.Errorf("未找到备份: %s", name)
+}
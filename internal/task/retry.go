@@ -0,0 +1,102 @@
+package task
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/logger"
+)
+
+// RunJobWithRetry 执行任务，失败时按 schedule.retry 策略重试，用于定时与补跑备份
+// 每次尝试都单独记录运行历史；只有最后一次尝试的结果会发送通知
+func RunJobWithRetry(cfg *config.Config, job config.JobConfig, trigger string) error {
+	policy := job.Schedule.Retry
+	start := time.Now()
+	deadline := policy.Deadline(start, config.CalculateNextRunTime(job.Schedule))
+
+	for attempt := 1; ; attempt++ {
+		var delay time.Duration
+		retry := func(err error) bool {
+			if attempt > policy.Attempts {
+				return false
+			}
+			delay = retryDelay(policy, attempt)
+			if time.Now().Add(delay).After(deadline) {
+				logger.PrintLog("warn", fmt.Sprintf("任务 [%s] 下次重试将晚于截止时间 %s，不再重试",
+					job.Name, deadline.Format("2006-01-02 15:04:05")))
+				delay = 0
+				return false
+			}
+			return true
+		}
+
+		err := runAttempt(cfg, job, trigger, attempt, retry)
+		if err == nil || delay == 0 {
+			return err
+		}
+		logger.PrintLog("warn", fmt.Sprintf("任务 [%s] 第 %d 次尝试失败: %v，%v 后重试（最多重试 %d 次）",
+			job.Name, attempt, err, delay.Round(time.Second), policy.Attempts))
+		if !waitRetry(job.Name, trigger, delay) {
+			logger.PrintLog("daemon", fmt.Sprintf("任务 [%s] 的重试已取消，改为立即执行手动备份", job.Name))
+			return err
+		}
+	}
+}
+
+// retryDelay 返回第 n 次重试前的等待时间，在指数退避时间的一半到全部之间随机，避免多个任务同时重试
+func retryDelay(policy config.RetryConfig, n int) time.Duration {
+	d := policy.Backoff(n)
+	return d/2 + rand.N(d/2+1)
+}
+
+var (
+	retryMu    sync.Mutex
+	retryWaits = make(map[string]chan struct{}) // 等待重试的任务 → 取消等待的通道
+)
+
+// waitRetry 等待重试，等待期间可通过控制接口查询到任务处于等待重试阶段
+// 等待被 CancelRetry 取消时返回 false
+func waitRetry(job, trigger string, delay time.Duration) bool {
+	info := &RunInfo{Job: job, Trigger: trigger, StartTime: time.Now()}
+	info.setPhase(PhaseRetryWait)
+	defer info.finish()
+
+	cancel := make(chan struct{})
+	retryMu.Lock()
+	retryWaits[job] = cancel
+	retryMu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-cancel:
+	}
+
+	// 等待结束与取消同时发生时以是否已被取消为准，保证 CancelRetry 返回 true 时不会再重试
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	select {
+	case <-cancel:
+		return false
+	default:
+		delete(retryWaits, job)
+		return true
+	}
+}
+
+// CancelRetry 取消任务正在进行的重试等待，任务不再重试；任务未在等待重试时返回 false
+func CancelRetry(job string) bool {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	cancel, ok := retryWaits[job]
+	if !ok {
+		return false
+	}
+	delete(retryWaits, job)
+	close(cancel)
+	return true
+}
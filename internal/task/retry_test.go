@@ -0,0 +1,86 @@
+//go:build unix
+
+package task
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/history"
+	"backup-go/internal/lock"
+)
+
+func TestRetryDelay(t *testing.T) {
+	policy := config.RetryConfig{BackoffSeconds: 10, MaxBackoffSeconds: 30}
+	for n, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 30 * time.Second, 6: 30 * time.Second} {
+		if got := policy.Backoff(n); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", n, got, want)
+		}
+		for i := 0; i < 20; i++ {
+			if d := retryDelay(policy, n); d < want/2 || d > want {
+				t.Errorf("retryDelay(%d) = %v, want within [%v, %v]", n, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestRunJobWithRetry(t *testing.T) {
+	dir := t.TempDir()
+	oldHistory, oldLock := history.Path, lock.Dir
+	history.Path, lock.Dir = filepath.Join(dir, "history.jsonl"), filepath.Join(dir, "locks")
+	t.Cleanup(func() { history.Path, lock.Dir = oldHistory, oldLock })
+
+	// 前置钩子失败并中止备份，每次尝试都会失败
+	job := config.JobConfig{Name: "retry"}
+	job.PreHooks = []string{"exit 1"}
+	job.AbortOnPreHookFailure = true
+	job.Schedule = config.ScheduleConfig{Enabled: true, Hour: time.Now().Add(2 * time.Hour).Hour(),
+		Retry: config.RetryConfig{Attempts: 1, BackoffSeconds: 1}}
+	cfg := &config.Config{Lock: config.LockConfig{OnConflict: config.LockSkip}}
+
+	if err := RunJobWithRetry(cfg, job, history.TriggerSchedule); err == nil {
+		t.Fatal("Expected the job to fail after retrying")
+	}
+	records, err := history.Load("retry", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Attempt != 2 || records[1].Attempt != 1 || records[0].Success() {
+		t.Errorf("Expected two failed attempts in history, got %+v", records)
+	}
+}
+
+func TestCancelRetry(t *testing.T) {
+	if CancelRetry("retry-wait") {
+		t.Fatal("CancelRetry should return false when no retry is pending")
+	}
+
+	done := make(chan bool)
+	go func() { done <- waitRetry("retry-wait", history.TriggerSchedule, time.Hour) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for !CancelRetry("retry-wait") {
+		if time.Now().After(deadline) {
+			t.Fatal("Retry wait was not registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case retried := <-done:
+		if retried {
+			t.Error("Cancelled wait should not retry")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitRetry did not return after being cancelled")
+	}
+	for _, p := range Running() {
+		if p.Job == "retry-wait" {
+			t.Errorf("Progress should be removed after the wait ends, got %+v", p)
+		}
+	}
+	if !waitRetry("retry-wait", history.TriggerSchedule, time.Millisecond) {
+		t.Error("Wait that is not cancelled should retry")
+	}
+}
@@ -0,0 +1,89 @@
+package task
+
+import (
+	"time"
+
+	"backup-go/internal/core/archiver"
+	"backup-go/internal/history"
+	"backup-go/internal/metrics"
+	"backup-go/internal/notify"
+)
+
+// 任务运行结果，通过 BACKUP_STATUS 传给钩子命令，并记录到运行历史
+const (
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+	StatusSkipped = "skipped"
+)
+
+// RunInfo 一次任务运行的信息，用于钩子环境变量、通知、运行历史与监控指标
+type RunInfo struct {
+	Job       string
+	Prefix    string
+	Trigger   string // 触发方式，见 history.Trigger*
+	Attempt   int    // 失败重试时为第几次尝试，从 1 开始
+	Status    string
+	Archive   string // 归档文件名，分块仓库格式下为快照文件名
+	Key       string // 归档或快照的对象键
+	Size      int64  // 本次写入存储的字节数（归档大小或新上传的数据块大小）
+	DataSize  int64  // 备份的原始数据字节数
+	Stats     archiver.WalkStats
+	Err       error
+	StartTime time.Time
+}
+
+// event 返回用于发送通知的运行结果
+func (r *RunInfo) event() notify.Event {
+	e := notify.NewEvent(r.Job, r.Status)
+	e.Archive, e.Key = r.Archive, r.Key
+	e.Size, e.DataSize, e.Files = r.Size, r.DataSize, r.Stats.Processed
+	e.StartTime, e.Duration = r.StartTime, time.Since(r.StartTime).Seconds()
+	e.Attempt = r.Attempt
+	if r.Err != nil {
+		e.Error = r.Err.Error()
+	}
+	return e
+}
+
+// record 返回用于记录运行历史的结果
+func (r *RunInfo) record() history.Record {
+	rec := history.Record{
+		Job:            r.Job,
+		Trigger:        r.Trigger,
+		Attempt:        r.Attempt,
+		Status:         r.Status,
+		StartTime:      r.StartTime,
+		EndTime:        time.Now(),
+		Key:            r.Key,
+		OriginalSize:   r.DataSize,
+		CompressedSize: r.Size,
+		FilesProcessed: r.Stats.Processed,
+		FilesSkipped:   r.Stats.Skipped,
+	}
+	if r.Err != nil {
+		rec.Error = r.Err.Error()
+	}
+	return rec
+}
+
+// observe 更新任务的监控指标
+// 跳过的运行（源目录为空或任务正在运行）不算成功，只记录跳过时间，持续跳过的任务仍会触发备份过期告警
+func (r *RunInfo) observe() {
+	now := time.Now()
+	metrics.Runs.Inc(r.Job, r.Status)
+	metrics.LastRunTimestamp.Set(float64(now.Unix()), r.Job)
+	metrics.LastRunDuration.Set(now.Sub(r.StartTime).Seconds(), r.Job)
+	switch r.Status {
+	case StatusSkipped:
+		metrics.LastSkippedTimestamp.Set(float64(now.Unix()), r.Job)
+	case StatusSuccess:
+		metrics.LastRunSuccess.Set(1, r.Job)
+		metrics.LastSuccessTimestamp.Set(float64(now.Unix()), r.Job)
+		metrics.ArchivedBytes.Add(float64(r.DataSize), r.Job)
+		metrics.UploadedBytes.Add(float64(r.Size), r.Job)
+		metrics.FilesSkipped.Add(float64(r.Stats.Skipped), r.Job)
+	default:
+		metrics.LastRunSuccess.Set(0, r.Job)
+	}
+}
@@ -0,0 +1,48 @@
+package task
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backup-go/internal/metrics"
+)
+
+// metricLine 返回指标输出中指定序列所在的行，不存在时返回空字符串
+func metricLine(t *testing.T, series string) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range strings.Split(rec.Body.String(), "\n") {
+		if strings.HasPrefix(line, series+" ") {
+			return line
+		}
+	}
+	return ""
+}
+
+func TestObserveSkipped(t *testing.T) {
+	job := "observe-skipped"
+	(&RunInfo{Job: job, Status: StatusFailure, StartTime: time.Now()}).observe()
+	(&RunInfo{Job: job, Status: StatusSkipped, StartTime: time.Now()}).observe()
+
+	label := `{job="` + job + `"}`
+	if line := metricLine(t, "backup_go_last_run_success"+label); line != "backup_go_last_run_success"+label+" 0" {
+		t.Errorf("Skipped run should not mark the job as successful, got %q", line)
+	}
+	if line := metricLine(t, "backup_go_last_success_timestamp_seconds"+label); line != "" {
+		t.Errorf("Skipped run should not set the last success time, got %q", line)
+	}
+	if line := metricLine(t, "backup_go_last_skipped_timestamp_seconds"+label); line == "" {
+		t.Error("Expected the last skipped time to be set")
+	}
+	if line := metricLine(t, `backup_go_runs_total{job="`+job+`",status="skipped"}`); !strings.HasSuffix(line, " 1") {
+		t.Errorf("Expected one skipped run, got %q", line)
+	}
+
+	(&RunInfo{Job: job, Status: StatusSuccess, StartTime: time.Now()}).observe()
+	if line := metricLine(t, "backup_go_last_run_success"+label); line != "backup_go_last_run_success"+label+" 1" {
+		t.Errorf("Expected the successful run to be recorded, got %q", line)
+	}
+}
@@ -1,69 +1,479 @@
 package task
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"backup-go/internal/config"
 	"backup-go/internal/core/archiver"
+	"backup-go/internal/core/dbdump"
+	"backup-go/internal/core/encryptor"
+	"backup-go/internal/core/retention"
+	"backup-go/internal/core/storage"
 	"backup-go/internal/core/uploader"
+	"backup-go/internal/history"
+	"backup-go/internal/lock"
 	"backup-go/internal/logger"
+	"backup-go/internal/notify"
 )
 
+// newStorage 创建存储客户端，测试中替换为模拟的存储
+var newStorage = storage.New
+
 const (
 	TempDir = "tmp"
+
+	// staleUploadAge 超过该时长且本地无续传状态的分块上传视为废弃
+	staleUploadAge = 24 * time.Hour
 )
 
-// RunBackup 执行一次完整备份
-func RunBackup(cfg *config.Config) error {
-	// 创建 COS 客户端
-	client, err := uploader.NewClient(&cfg.Cos)
+// BackupSources 返回备份源目录列表：配置了 sources 时每个目录位于独立的顶层目录下，否则沿用 data_dir 的单目录布局
+// 配置了数据库导出时，源目录不使用导出所在的顶层目录名
+func BackupSources(b config.BackupConfig) []archiver.Source {
+	if len(b.Sources) == 0 {
+		return []archiver.Source{{Path: b.DataDir}}
+	}
+	if len(b.Databases) > 0 {
+		return archiver.NewSources(b.Sources, databasesPrefix)
+	}
+	return archiver.NewSources(b.Sources)
+}
+
+// databasesPrefix 数据库导出文件在归档中所在的顶层目录
+const databasesPrefix = "databases"
+
+// jobSources 返回任务本次运行的备份源目录，以及每个数据库的导出数据流（位于 databases/ 顶层目录下）
+// 导出在打包到对应条目时才开始，导出内容直接写入归档，不占用本地磁盘（SQLite 除外）
+func jobSources(job config.JobConfig, info *RunInfo) ([]archiver.Source, []archiver.Stream, error) {
+	var sources []archiver.Source
+	if job.DataDir != "" || len(job.Sources) > 0 || len(job.Databases) == 0 {
+		sources = BackupSources(job.BackupConfig)
+	}
+	if len(job.Databases) == 0 {
+		return sources, nil, nil
+	}
+	// 单目录布局下源目录内容直接位于包根目录，不能与导出目录重名
+	if len(job.Sources) == 0 && job.DataDir != "" {
+		if _, err := os.Lstat(filepath.Join(job.DataDir, databasesPrefix)); err == nil {
+			return nil, nil, fmt.Errorf("data_dir 中已存在 %s，与数据库导出目录冲突，请改用 sources 配置源目录", databasesPrefix)
+		}
+	}
+
+	streams := make([]archiver.Stream, 0, len(job.Databases))
+	for _, db := range job.Databases {
+		streams = append(streams, archiver.Stream{
+			Name: databasesPrefix + "/" + db.FileName(),
+			Open: func() (io.ReadCloser, error) {
+				info.setPhase(PhaseDump)
+				r, err := dbdump.Open(db, TempDir)
+				if err != nil {
+					return nil, fmt.Errorf("导出数据库 %s 失败: %w", db.Database, err)
+				}
+				return r, nil
+			},
+		})
+	}
+	return sources, streams, nil
+}
+
+// BackupOptions 返回打包选项
+func BackupOptions(b config.BackupConfig) archiver.Options {
+	return archiver.Options{Include: b.Include, Exclude: b.Exclude}
+}
+
+// UploadOptions 返回上传选项
+func UploadOptions(c config.CosConfig) uploader.UploadOptions {
+	return uploader.UploadOptions{
+		PartSize:           int64(c.PartSizeMB) * 1024 * 1024,
+		MultipartThreshold: int64(c.MultipartThresholdMB) * 1024 * 1024,
+	}
+}
+
+// applyBandwidth 按配置设置上传与下载限速，同一进程内同时进行的传输共用限速
+func applyBandwidth(cfg *config.Config) {
+	upload, download := cfg.Bandwidth.Rates()
+	uploader.SetBandwidth(upload, download)
+	if cfg.Bandwidth.Enabled() {
+		now := time.Now()
+		logger.PrintLog("info", fmt.Sprintf("传输限速: 当前上传 %s，下载 %s", rateText(upload, now), rateText(download, now)))
+	}
+}
+
+// rateText 返回指定时刻限速的可读描述
+func rateText(rate func(time.Time) int64, now time.Time) string {
+	if rate == nil || rate(now) <= 0 {
+		return "不限速"
+	}
+	return humanize.Bytes(uint64(rate(now))) + "/s"
+}
+
+// PruneOptions 返回清理过期备份的选项
+func PruneOptions(c config.CosConfig) retention.Options {
+	return retention.Options{DryRun: c.PruneDryRun, MaxDeletePercent: c.PruneMaxPercent}
+}
+
+// RetentionPolicy 返回任务的备份保留策略，keep_days 为负数时不清理
+func RetentionPolicy(job config.JobConfig) retention.Policy {
+	if job.KeepDays < 0 {
+		return retention.Policy{}
+	}
+	return retention.Policy{
+		KeepDays:    job.KeepDays,
+		KeepLast:    job.KeepLast,
+		KeepDaily:   job.KeepDaily,
+		KeepWeekly:  job.KeepWeekly,
+		KeepMonthly: job.KeepMonthly,
+		KeepYearly:  job.KeepYearly,
+	}
+}
+
+// RunBackup 依次执行配置中的所有备份任务，trigger 为触发方式（见 history.Trigger*）
+func RunBackup(cfg *config.Config, trigger string) error {
+	var failed []string
+	for _, job := range cfg.JobList() {
+		if err := RunJob(cfg, job, trigger); err != nil {
+			logger.PrintLog("error", fmt.Sprintf("任务 [%s] 备份失败: %v", job.Name, err))
+			failed = append(failed, job.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d 个任务备份失败: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// RunJob 执行一个任务的完整备份，并在备份前后执行配置的钩子命令，结束后发送通知并记录运行历史
+// 同一任务的另一次备份仍在运行时，按 lock.on_conflict 等待、跳过或失败
+func RunJob(cfg *config.Config, job config.JobConfig, trigger string) error {
+	return runAttempt(cfg, job, trigger, 1, nil)
+}
+
+// runAttempt 执行一次任务运行，attempt 为第几次尝试
+// 运行失败且 retry 返回 true（之后还会重试）时不发送通知，只记录日志与运行历史
+func runAttempt(cfg *config.Config, job config.JobConfig, trigger string, attempt int, retry func(error) bool) error {
+	if attempt > 1 {
+		logger.PrintLog("backup", fmt.Sprintf("开始执行任务 [%s]（第 %d 次尝试）", job.Name, attempt))
+	} else {
+		logger.PrintLog("backup", fmt.Sprintf("开始执行任务 [%s]", job.Name))
+	}
+	info := &RunInfo{Job: job.Name, Prefix: job.Prefix, Trigger: trigger, Attempt: attempt, Status: StatusRunning, StartTime: time.Now()}
+	defer info.finish()
+
+	release, err := lockJob(cfg, job, info)
+	switch {
+	case err == nil:
+		err = runWithHooks(cfg, job, info)
+		release()
+	case errors.Is(err, lock.ErrLocked) && cfg.Lock.OnConflict == config.LockSkip:
+		logger.PrintLog("skip", fmt.Sprintf("%v，跳过本次备份", err))
+		info.Status, err = StatusSkipped, nil
+	default:
+		info.Status, info.Err = StatusFailure, err
+	}
+
+	info.observe()
+	if err == nil || retry == nil || !retry(err) {
+		notify.Send(cfg.Notify, info.event())
+	}
+	if hisErr := history.Append(info.record()); hisErr != nil {
+		logger.PrintLog("warn", fmt.Sprintf("记录运行历史失败: %v", hisErr))
+	}
+	return err
+}
+
+// runWithHooks 依次执行前置钩子、备份与后置钩子，并根据结果设置 info 的状态
+func runWithHooks(cfg *config.Config, job config.JobConfig, info *RunInfo) error {
+	info.setPhase(PhasePreHooks)
+	err := runHooks(HookPre, job.PreHooks, job.HooksConfig, info)
+	if err != nil && job.AbortOnPreHookFailure {
+		err = fmt.Errorf("前置钩子失败，已中止备份: %w", err)
+	} else {
+		if err != nil {
+			logger.PrintLog("warn", fmt.Sprintf("%v，继续备份", err))
+		}
+		err = runJob(cfg, job, info)
+	}
+
+	info.setPhase(PhasePostHooks)
+	if err != nil {
+		info.Status, info.Err = StatusFailure, err
+		if hookErr := runHooks(HookFailure, job.OnFailure, job.HooksConfig, info); hookErr != nil {
+			logger.PrintLog("warn", hookErr.Error())
+		}
+	} else if info.Status == StatusRunning {
+		info.Status = StatusSuccess
+	}
+	if hookErr := runHooks(HookPost, job.PostHooks, job.HooksConfig, info); hookErr != nil {
+		logger.PrintLog("warn", hookErr.Error())
+	}
+	return err
+}
+
+// runJob 执行备份、上传与清理，并将归档信息记录到 info
+func runJob(cfg *config.Config, job config.JobConfig, info *RunInfo) error {
+	// 创建存储客户端
+	store, err := newStorage(cfg)
 	if err != nil {
-		return fmt.Errorf("创建COS客户端失败: %w", err)
+		return fmt.Errorf("创建存储客户端失败: %w", err)
 	}
 
+	sources, streams, err := jobSources(job, info)
+	if err != nil {
+		return err
+	}
+
+	// 归档与分块仓库格式的传输都受限速约束
+	applyBandwidth(cfg)
+	if job.Format == config.FormatRepository {
+		return runRepositoryJob(cfg, store, job, sources, streams, info)
+	}
+
+	// 续传上次中断的上传
+	uploadOpts := UploadOptions(cfg.Cos)
+	resumePendingUploads(store, job, uploadOpts)
+
 	// 准备临时目录 (使用独立子目录避免冲突)
-	taskID := fmt.Sprintf("manual-%d", time.Now().UnixNano())
+	taskID := fmt.Sprintf("%s-%d", job.Name, time.Now().UnixNano())
 	taskTempDir := filepath.Join(TempDir, taskID)
-	
+
 	if err := os.MkdirAll(taskTempDir, 0755); err != nil {
 		return fmt.Errorf("创建任务临时目录失败: %w", err)
 	}
-	defer os.RemoveAll(taskTempDir) // 任务结束清理
+	keepTemp := false
+	defer func() {
+		// 任务结束清理，留有上传进度时保留归档以便续传
+		if !keepTemp {
+			os.RemoveAll(taskTempDir)
+		}
+	}()
+
+	opts := BackupOptions(job.BackupConfig)
+	opts.Stats = &info.Stats
+	opts.Streams = streams
+	opts.TempDir = taskTempDir
+	if opts.Encryptor, err = encryptor.New(cfg.Encryption); err != nil {
+		return fmt.Errorf("初始化加密失败: %w", err)
+	}
+
+	// 增量模式下基于上一个备份的清单只打包变化的文件
+	opts.Manifest = archiver.NewManifest(sources)
+	base, baseName := incrementalBase(cfg, store, job, sources, opts.Encryptor != nil)
 
 	// 生成文件名
-	archiveName := fmt.Sprintf("backup-%s.tar.zst", time.Now().Format("20060102-150405"))
+	archiveName := "backup-" + time.Now().Format("20060102-150405")
+	if base != nil {
+		opts.Manifest.SetBase(base, baseName)
+		archiveName += uploader.IncrementalSuffix
+		logger.PrintLog("backup", "执行增量备份，基于: "+baseName)
+	}
+	archiveName += ".tar.zst"
+	if opts.Encryptor != nil {
+		archiveName += opts.Encryptor.Extension()
+	}
 	archivePath := filepath.Join(taskTempDir, archiveName)
+	manifestPath := archivePath + archiver.ManifestSuffix
 
-	// 1. 压缩
-	_, _, err = archiver.Compress(cfg.Backup.DataDir, archivePath)
-	if err != nil {
-		if strings.Contains(err.Error(), "为空，跳过备份") {
-			logger.PrintLog("skip", err.Error())
-			return nil
+	key := job.Prefix + archiveName
+	// 归一化 prefix
+	if job.Prefix != "" && !strings.HasSuffix(job.Prefix, "/") {
+		key = job.Prefix + "/" + archiveName
+	}
+
+	var sum uploader.Checksum
+	info.setPhase(PhaseArchive)
+	if cfg.Cos.StreamUpload {
+		// 1+2. 边压缩边分块上传
+		if sum, info.DataSize, err = streamBackup(store, sources, opts, key, uploadOpts.PartSize); err != nil {
+			if strings.Contains(err.Error(), "为空，跳过备份") {
+				logger.PrintLog("skip", err.Error())
+				info.Status = StatusSkipped
+				return nil
+			}
+			return fmt.Errorf("流式备份失败: %w", err)
+		}
+		if err := archiver.WriteManifestFile(manifestPath, opts.Manifest, opts.Encryptor); err != nil {
+			return err
+		}
+	} else {
+		// 1. 压缩
+		info.DataSize, _, err = archiver.CompressSources(sources, archivePath, opts)
+		if err != nil {
+			if strings.Contains(err.Error(), "为空，跳过备份") {
+				logger.PrintLog("skip", err.Error())
+				info.Status = StatusSkipped
+				return nil
+			}
+			return fmt.Errorf("压缩失败: %w", err)
+		}
+		// 清单在上传前写入临时目录，上传中断时与归档一起保留，续传完成后一并上传
+		if err := archiver.WriteManifestFile(manifestPath, opts.Manifest, opts.Encryptor); err != nil {
+			return err
+		}
+		if sum, err = uploader.FileChecksum(archivePath); err != nil {
+			return fmt.Errorf("计算校验和失败: %w", err)
+		}
+
+		// 2. 上传
+		info.setPhase(PhaseUpload)
+		if err := uploader.Upload(store, archivePath, key, uploadOpts); err != nil {
+			if _, statErr := os.Stat(uploader.StatePath(archivePath)); statErr == nil {
+				keepTemp = true
+				logger.PrintLog("warn", "已保留本地归档，下次运行时续传: "+archivePath)
+			}
+			return fmt.Errorf("上传失败: %w", err)
 		}
-		return fmt.Errorf("压缩失败: %w", err)
 	}
+	if err := finishUpload(store, key, sum); err != nil {
+		return err
+	}
+	info.Archive, info.Key, info.Size = archiveName, key, sum.Size
 
-	// 2. 上传
-	cosPath := cfg.Cos.Prefix + archiveName
-	// 归一化 prefix
-	if cfg.Cos.Prefix != "" && !strings.HasSuffix(cfg.Cos.Prefix, "/") {
-		cosPath = cfg.Cos.Prefix + "/" + archiveName
+	// 清单与归档放在一起，便于不下载归档即可查看和校验内容
+	if err := uploadManifest(store, manifestPath, key, uploadOpts); err != nil {
+		return err
 	}
 
-	if err := uploader.Upload(client, archivePath, cosPath); err != nil {
-		return fmt.Errorf("上传失败: %w", err)
+	// 中止本任务前缀下无人续传的过期分块上传，避免残留分块持续计费
+	if err := uploader.AbortStaleUploads(store, job.Prefix, staleUploadAge, pendingUploadIDs()); err != nil {
+		logger.PrintLog("warn", fmt.Sprintf("清理未完成的分块上传失败: %v", err))
 	}
 
 	// 3. 清理过期
-	if err := uploader.DeleteExpiredBackups(client, cfg.Cos.Bucket, cfg.Cos.Prefix, cfg.Cos.KeepDays); err != nil {
+	info.setPhase(PhasePrune)
+	if err := uploader.DeleteExpiredBackups(store, job.Prefix, RetentionPolicy(job), PruneOptions(cfg.Cos)); err != nil {
 		logger.PrintLog("warn", fmt.Sprintf("清理过期备份失败: %v", err))
 	}
 
-	logger.PrintLog("done", "备份流程完成")
+	logger.PrintLog("done", fmt.Sprintf("任务 [%s] 备份流程完成", job.Name))
 	return nil
 }
+
+// streamBackup 通过 io.Pipe 将打包压缩的数据流直接分块上传到存储，同时计算校验信息
+// 返回归档的校验信息与原始数据大小
+func streamBackup(store storage.Storage, sources []archiver.Source, opts archiver.Options, key string, partSize int64) (uploader.Checksum, int64, error) {
+	pr, pw := io.Pipe()
+	h := uploader.NewHasher()
+	var originalSize int64
+	compressErr := make(chan error, 1)
+	go func() {
+		var err error
+		originalSize, _, err = archiver.CompressTo(sources, io.MultiWriter(pw, h), opts)
+		pw.CloseWithError(err)
+		compressErr <- err
+	}()
+
+	_, uploadErr := uploader.UploadStream(store, pr, key, partSize)
+	// 上传失败时让压缩协程尽快退出
+	pr.CloseWithError(uploadErr)
+	if err := <-compressErr; err != nil && uploadErr == nil {
+		return uploader.Checksum{}, 0, err
+	}
+	return h.Sum(), originalSize, uploadErr
+}
+
+// finishUpload 核对远端对象与本地计算的校验信息，并将校验信息保存到归档旁边
+func finishUpload(store storage.Storage, key string, sum uploader.Checksum) error {
+	if err := uploader.CheckRemote(store, key, sum); err != nil {
+		return fmt.Errorf("上传后校验失败: %w", err)
+	}
+	return uploader.PutChecksum(store, key, sum)
+}
+
+// uploadManifest 上传归档旁边的清单
+// 上传失败时删除已上传的归档及其校验文件，避免存储中留下缺少清单的归档（增量链恢复依赖清单）
+func uploadManifest(store storage.Storage, manifestPath, key string, opts uploader.UploadOptions) error {
+	err := uploader.Upload(store, manifestPath, key+archiver.ManifestSuffix, opts)
+	if err == nil {
+		return nil
+	}
+	for _, k := range []string{key, key + uploader.ChecksumSuffix} {
+		if delErr := store.Delete(k); delErr != nil {
+			logger.PrintLog("warn", fmt.Sprintf("删除缺少清单的归档失败: %s: %v", k, delErr))
+		}
+	}
+	return fmt.Errorf("上传清单失败，已删除本次上传的归档 %s: %w", key, err)
+}
+
+// resumePendingUploads 续传本任务在临时目录中留存的未完成上传
+// 本地归档已不存在的上传无法续传，直接中止并清理状态文件
+func resumePendingUploads(store storage.Storage, job config.JobConfig, opts uploader.UploadOptions) {
+	statePaths, err := uploader.PendingUploads(TempDir)
+	if err != nil {
+		return
+	}
+	for _, statePath := range statePaths {
+		state, err := uploader.LoadUploadState(statePath)
+		if err != nil {
+			logger.PrintLog("warn", fmt.Sprintf("忽略无法读取的上传状态文件: %s (错误: %v)", statePath, err))
+			continue
+		}
+		dir := path.Dir(state.Key)
+		if dir == "." {
+			dir = ""
+		}
+		if dir != strings.TrimSuffix(job.Prefix, "/") {
+			continue // 属于其他任务
+		}
+
+		taskTempDir := filepath.Dir(statePath)
+		archivePath := strings.TrimSuffix(statePath, uploader.StateFileSuffix)
+		if _, err := os.Stat(archivePath); err != nil {
+			logger.PrintLog("warn", fmt.Sprintf("本地归档已不存在，放弃续传: %s", state.Key))
+			if mp, ok := store.(storage.Multipart); ok {
+				uploader.AbortUpload(mp, state.Key, state.UploadID)
+			}
+			os.RemoveAll(taskTempDir)
+			continue
+		}
+
+		logger.PrintLog("upload", fmt.Sprintf("任务 [%s] 续传上次中断的备份: %s", job.Name, state.Key))
+		if err := uploader.Upload(store, archivePath, state.Key, opts); err != nil {
+			logger.PrintLog("warn", fmt.Sprintf("续传失败，下次运行时重试: %v", err))
+			continue
+		}
+		sum, err := uploader.FileChecksum(archivePath)
+		if err == nil {
+			err = finishUpload(store, state.Key, sum)
+		}
+		if err != nil {
+			// 校验失败的归档不能当作完整备份：删除远端对象，保留本地归档与清单，下次运行时重新上传
+			logger.PrintLog("warn", fmt.Sprintf("续传的备份校验失败，下次运行时重新上传: %s: %v", state.Key, err))
+			for _, k := range []string{state.Key, state.Key + uploader.ChecksumSuffix} {
+				if delErr := store.Delete(k); delErr != nil && !errors.Is(delErr, storage.ErrNotFound) {
+					logger.PrintLog("warn", fmt.Sprintf("删除校验失败的归档失败: %s: %v", k, delErr))
+				}
+			}
+			if err := uploader.ResetUploadState(archivePath, state.Key); err != nil {
+				logger.PrintLog("warn", fmt.Sprintf("保存上传状态失败: %s: %v", archivePath, err))
+			}
+			continue
+		}
+		// 上次中断前已生成的清单一并上传
+		manifestPath := archivePath + archiver.ManifestSuffix
+		if _, err := os.Stat(manifestPath); err != nil {
+			logger.PrintLog("warn", fmt.Sprintf("续传的备份缺少本地清单: %s", state.Key))
+		} else if err := uploadManifest(store, manifestPath, state.Key, opts); err != nil {
+			logger.PrintLog("warn", err.Error())
+		}
+		os.RemoveAll(taskTempDir)
+	}
+}
+
+// pendingUploadIDs 返回本地留存的上传状态对应的 UploadID
+func pendingUploadIDs() map[string]bool {
+	ids := make(map[string]bool)
+	statePaths, _ := uploader.PendingUploads(TempDir)
+	for _, statePath := range statePaths {
+		if state, err := uploader.LoadUploadState(statePath); err == nil {
+			ids[state.UploadID] = true
+		}
+	}
+	return ids
+}
@@ -0,0 +1,220 @@
+package task
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"backup-go/internal/config"
+	"backup-go/internal/core/archiver"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/core/uploader"
+)
+
+// multipartStore 在本地存储之上模拟分块上传，failPart 大于 0 时上传该编号及之后的分块会失败，
+// dropPart 大于 0 时完成上传得到的对象缺少该编号的分块
+type multipartStore struct {
+	storage.Storage
+	mu       sync.Mutex
+	uploads  map[string]map[int][]byte
+	failPart int
+	dropPart int
+}
+
+func newMultipartStore(t *testing.T) *multipartStore {
+	local, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &multipartStore{Storage: local, uploads: make(map[string]map[int][]byte)}
+}
+
+func (s *multipartStore) InitiateMultipart(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := fmt.Sprintf("upload-%d", len(s.uploads)+1)
+	s.uploads[id] = make(map[int][]byte)
+	return id, nil
+}
+
+func (s *multipartStore) UploadPart(key, uploadID string, number int, r io.Reader, size int64) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failPart > 0 && number >= s.failPart {
+		return "", errors.New("connection reset")
+	}
+	parts, ok := s.uploads[uploadID]
+	if !ok {
+		return "", errors.New("NoSuchUpload")
+	}
+	parts[number] = data
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (s *multipartStore) CompleteMultipart(key, uploadID string, parts []storage.Part) error {
+	s.mu.Lock()
+	uploaded := s.uploads[uploadID]
+	delete(s.uploads, uploadID)
+	s.mu.Unlock()
+	var buf bytes.Buffer
+	for _, p := range parts {
+		if p.Number != s.dropPart {
+			buf.Write(uploaded[p.Number])
+		}
+	}
+	return s.Put(key, &buf, int64(buf.Len()))
+}
+
+func (s *multipartStore) AbortMultipart(key, uploadID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploads, uploadID)
+	return nil
+}
+
+func (s *multipartStore) ListParts(key, uploadID string) ([]storage.Part, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var parts []storage.Part
+	for n, data := range s.uploads[uploadID] {
+		sum := md5.Sum(data)
+		parts = append(parts, storage.Part{Number: n, ETag: hex.EncodeToString(sum[:])})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+	return parts, nil
+}
+
+func (s *multipartStore) ListMultipartUploads(prefix string) ([]storage.MultipartUpload, error) {
+	return nil, nil
+}
+
+func TestResumeUploadsManifest(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := newMultipartStore(t)
+	store.failPart = 2
+	oldStorage := newStorage
+	newStorage = func(*config.Config) (storage.Storage, error) { return store, nil }
+	t.Cleanup(func() { newStorage = oldStorage })
+
+	// 随机数据无法压缩，归档分为 3 个 5MB 的分块
+	data := make([]byte, 12*1024*1024)
+	for i := range data {
+		data[i] = byte(rand.IntN(256))
+	}
+	if err := os.MkdirAll("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("data", "blob.bin"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Cos: config.CosConfig{PartSizeMB: config.MinPartSizeMB, MultipartThresholdMB: 1}}
+	job := config.JobConfig{Name: "resume", Prefix: "backup/"}
+	job.DataDir = "data"
+
+	if err := runJob(cfg, job, &RunInfo{Job: job.Name}); err == nil {
+		t.Fatal("Expected the interrupted upload to fail")
+	}
+	statePaths, err := uploader.PendingUploads(TempDir)
+	if err != nil || len(statePaths) != 1 {
+		t.Fatalf("Expected one pending upload, got %v (%v)", statePaths, err)
+	}
+	archivePath := strings.TrimSuffix(statePaths[0], uploader.StateFileSuffix)
+	if _, err := os.Stat(archivePath + archiver.ManifestSuffix); err != nil {
+		t.Fatalf("Manifest should be kept next to the interrupted archive: %v", err)
+	}
+	state, err := uploader.LoadUploadState(statePaths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.failPart = 0
+	resumePendingUploads(store, job, UploadOptions(cfg.Cos))
+	for _, key := range []string{state.Key, state.Key + uploader.ChecksumSuffix, state.Key + archiver.ManifestSuffix} {
+		if _, err := store.Stat(key); err != nil {
+			t.Errorf("Expected %s after resuming: %v", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Dir(archivePath)); !os.IsNotExist(err) {
+		t.Errorf("Temp dir should be removed after resuming, got %v", err)
+	}
+}
+
+func TestResumeUploadsChecksumFailure(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := newMultipartStore(t)
+	store.failPart = 2
+	oldStorage := newStorage
+	newStorage = func(*config.Config) (storage.Storage, error) { return store, nil }
+	t.Cleanup(func() { newStorage = oldStorage })
+
+	// 随机数据无法压缩，归档分为 3 个 5MB 的分块
+	data := make([]byte, 12*1024*1024)
+	for i := range data {
+		data[i] = byte(rand.IntN(256))
+	}
+	if err := os.MkdirAll("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("data", "blob.bin"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{Cos: config.CosConfig{PartSizeMB: config.MinPartSizeMB, MultipartThresholdMB: 1}}
+	job := config.JobConfig{Name: "resume", Prefix: "backup/"}
+	job.DataDir = "data"
+
+	if err := runJob(cfg, job, &RunInfo{Job: job.Name}); err == nil {
+		t.Fatal("Expected the interrupted upload to fail")
+	}
+	statePaths, err := uploader.PendingUploads(TempDir)
+	if err != nil || len(statePaths) != 1 {
+		t.Fatalf("Expected one pending upload, got %v (%v)", statePaths, err)
+	}
+	archivePath := strings.TrimSuffix(statePaths[0], uploader.StateFileSuffix)
+	if _, err := os.Stat(archivePath + archiver.ManifestSuffix); err != nil {
+		t.Fatalf("Manifest should be kept next to the interrupted archive: %v", err)
+	}
+	state, err := uploader.LoadUploadState(statePaths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 续传完成后校验失败：不上传清单，保留本地归档等待下次重新上传
+	store.failPart, store.dropPart = 0, 1
+	resumePendingUploads(store, job, UploadOptions(cfg.Cos))
+	for _, key := range []string{state.Key, state.Key + uploader.ChecksumSuffix, state.Key + archiver.ManifestSuffix} {
+		if _, err := store.Stat(key); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("Expected %s to be absent after a failed check, got %v", key, err)
+		}
+	}
+	if _, err := os.Stat(archivePath + archiver.ManifestSuffix); err != nil {
+		t.Fatalf("Local archive and manifest should be kept: %v", err)
+	}
+
+	store.dropPart = 0
+	resumePendingUploads(store, job, UploadOptions(cfg.Cos))
+	for _, key := range []string{state.Key, state.Key + uploader.ChecksumSuffix, state.Key + archiver.ManifestSuffix} {
+		if _, err := store.Stat(key); err != nil {
+			t.Errorf("Expected %s after uploading again: %v", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Dir(archivePath)); !os.IsNotExist(err) {
+		t.Errorf("Temp dir should be removed after uploading again, got %v", err)
+	}
+}
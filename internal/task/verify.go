@@ -0,0 +1,134 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"backup-go/internal/config"
+	"backup-go/internal/core/archiver"
+	"backup-go/internal/core/encryptor"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/core/uploader"
+	"backup-go/internal/logger"
+)
+
+const (
+	// AllBackups 表示校验前缀下的全部备份
+	AllBackups = "all"
+)
+
+// RunVerify 校验任务的远端备份：比对存储的校验信息，并完整解码归档（不落盘）
+// name 可以是 "all"（默认）、"latest"、备份文件名或完整对象键
+func RunVerify(cfg *config.Config, job config.JobConfig, name string) error {
+	store, err := storage.New(cfg)
+	if err != nil {
+		return fmt.Errorf("创建存储客户端失败: %w", err)
+	}
+
+	backups, err := ListBackups(cfg, store, job)
+	if err != nil {
+		return fmt.Errorf("获取备份列表失败: %w", err)
+	}
+	if name != "" && name != AllBackups {
+		backup, err := selectBackup(backups, name)
+		if err != nil {
+			return err
+		}
+		backups = []uploader.BackupObject{backup}
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("前缀下没有可用的备份")
+	}
+
+	applyBandwidth(cfg)
+	verify := func(key string) error { return verifyBackup(cfg, store, key) }
+	if job.Format == config.FormatRepository {
+		verify = func(key string) error { return checkSnapshot(cfg, store, job, key) }
+	}
+
+	var failed []string
+	for _, b := range backups {
+		logger.PrintLog("verify", fmt.Sprintf("开始校验: %s", b.Key))
+		if err := verify(b.Key); err != nil {
+			logger.PrintLog("error", fmt.Sprintf("校验失败: %s: %v", b.Key, err))
+			failed = append(failed, b.Key)
+		}
+	}
+
+	logger.PrintLog("verify", fmt.Sprintf("共校验 %d 个备份，通过 %d 个，失败 %d 个", len(backups), len(backups)-len(failed), len(failed)))
+	if len(failed) > 0 {
+		return fmt.Errorf("%d 个备份校验失败: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// verifyBackup 校验单个备份
+func verifyBackup(cfg *config.Config, store storage.Storage, key string) error {
+	sum, err := uploader.GetChecksum(store, key)
+	hasSum := err == nil
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		logger.PrintLog("warn", "备份没有校验信息（可能由旧版本创建），仅检查能否完整解码")
+	case err != nil:
+		return err
+	default:
+		if err := uploader.CheckRemote(store, key, sum); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := loadManifest(cfg, store, key)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		logger.PrintLog("warn", "备份没有清单，跳过逐文件 SHA-256 核对")
+	case err != nil:
+		return err
+	}
+
+	body, err := store.Get(key)
+	if err != nil {
+		return fmt.Errorf("读取备份失败: %w", err)
+	}
+	body = uploader.ThrottleDownload(body)
+	defer body.Close()
+
+	// 边下载边计算校验和，再解密、解压并遍历全部条目
+	h := uploader.NewHasher()
+	tee := io.TeeReader(body, h)
+	r, err := encryptor.NewReader(tee, cfg.Encryption)
+	if err != nil {
+		return err
+	}
+	stats, err := archiver.Verify(r, manifest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return fmt.Errorf("读取备份失败: %w", err)
+	}
+
+	if hasSum {
+		got := h.Sum()
+		if got.Size != sum.Size {
+			return fmt.Errorf("备份内容被截断: 期望 %d 字节，实际 %d 字节", sum.Size, got.Size)
+		}
+		if got.SHA256 != sum.SHA256 {
+			return fmt.Errorf("备份内容 SHA-256 不一致: 期望 %s，实际 %s", sum.SHA256, got.SHA256)
+		}
+	}
+	logger.PrintLog("verify", fmt.Sprintf("校验通过: %d 个条目，%d 个文件，%s", stats.Entries, stats.Files, humanize.Bytes(uint64(stats.Bytes))))
+	return nil
+}
+
+// loadManifest 读取归档旁边的清单，不存在时返回 storage.ErrNotFound
+func loadManifest(cfg *config.Config, store storage.Storage, key string) (*archiver.Manifest, error) {
+	r, err := store.Get(key + archiver.ManifestSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return archiver.ReadManifest(r, cfg.Encryption)
+}
@@ -0,0 +1,113 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"backup-go/internal/config"
+	"backup-go/internal/control"
+	"backup-go/internal/history"
+	"backup-go/internal/task"
+)
+
+// daemonClient 返回后台服务控制接口的客户端，服务未运行或无法连接时返回 false
+func daemonClient(cfgPath string) (*control.Client, control.Status, bool) {
+	var cc config.ControlConfig
+	if cfg, err := config.LoadConfig(cfgPath); err == nil {
+		cc = cfg.Control
+	}
+	c := control.NewClient(cc)
+	st, err := c.Status()
+	if err != nil {
+		return nil, st, false
+	}
+	return c, st, true
+}
+
+// runInDaemon 由后台服务执行全部任务，等待结束后显示运行结果
+func runInDaemon(c *control.Client) error {
+	res, err := c.Run("")
+	if err != nil {
+		return err
+	}
+	for _, name := range res.Skipped {
+		fmt.Printf("⚠️  任务 [%s] 正在执行，等待其结束\n", name)
+	}
+	if len(res.Started) > 0 {
+		fmt.Printf("已交由后台服务执行: %s\n", strings.Join(res.Started, ", "))
+	}
+	names := append(res.Started, res.Skipped...)
+	if err := waitForJobs(c, names); err != nil {
+		return err
+	}
+
+	var records []history.Record
+	for _, name := range names {
+		if recs, err := c.History(name, 1); err == nil {
+			records = append(records, recs...)
+		}
+	}
+	fmt.Println()
+	task.PrintHistory(os.Stdout, records)
+	var failed []string
+	for _, r := range records {
+		if !r.Success() {
+			failed = append(failed, r.Job)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d 个任务备份失败: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// waitForJobs 定期查询后台服务，显示任务所处的阶段，直到 names 中的任务全部结束
+func waitForJobs(c *control.Client, names []string) error {
+	shown := make(map[string]string)
+	for {
+		st, err := c.Status()
+		if err != nil {
+			return err
+		}
+		running := 0
+		for _, js := range st.Jobs {
+			if !js.Running || !slices.Contains(names, js.Name) {
+				continue
+			}
+			running++
+			if p := js.Progress; p != nil && shown[js.Name] != p.Phase {
+				shown[js.Name] = p.Phase
+				fmt.Printf("  [%s] %s (已运行 %s)\n", js.Name, phaseText(p.Phase), time.Since(p.StartTime).Round(time.Second))
+			}
+		}
+		if running == 0 {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func phaseText(phase string) string {
+	switch phase {
+	case task.PhaseWaitLock:
+		return "等待另一次备份结束"
+	case task.PhasePreHooks:
+		return "执行前置钩子"
+	case task.PhaseDump:
+		return "导出数据库"
+	case task.PhaseArchive:
+		return "打包压缩"
+	case task.PhaseUpload:
+		return "上传归档"
+	case task.PhasePrune:
+		return "清理过期备份"
+	case task.PhasePostHooks:
+		return "执行后置钩子"
+	case task.PhaseRetryWait:
+		return "等待重试"
+	}
+	return phase
+}
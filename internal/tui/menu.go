@@ -7,9 +7,13 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
+	"github.com/dustin/go-humanize"
 	"backup-go/internal/config"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/history"
 	"backup-go/internal/logger"
 	"backup-go/internal/service"
 	"backup-go/internal/task"
@@ -29,6 +33,10 @@ func ShowMenu(cfgPath string) {
 		fmt.Println("  2. 🔧 配置管理")
 		fmt.Println("  3. 📋 服务管理")
 		fmt.Println("  4. 📝 日志管理")
+		fmt.Println("  5. ♻️  恢复备份")
+		fmt.Println("  6. 🔍 校验备份")
+		fmt.Println("  7. 🧹 清理过期备份")
+		fmt.Println("  8. 📜 运行历史")
 		fmt.Println("  0. ❌ 退出")
 
 		choice := getUserInput("请输入选项: ")
@@ -39,9 +47,17 @@ func ShowMenu(cfgPath string) {
 		case "2":
 			handleConfigMenu(cfgPath)
 		case "3":
-			handleServiceMenu()
+			handleServiceMenu(cfgPath)
 		case "4":
 			handleLogMenu()
+		case "5":
+			handleRestore(cfgPath)
+		case "6":
+			handleVerify(cfgPath)
+		case "7":
+			handlePrune(cfgPath)
+		case "8":
+			handleHistory(cfgPath)
 		case "0", "q", "exit":
 			logger.PrintLog("info", "退出程序")
 			os.Exit(0)
@@ -55,6 +71,16 @@ func ShowMenu(cfgPath string) {
 func handleImmediateBackup(cfgPath string) {
 	clearScreen()
 	fmt.Println("🎯 立即备份")
+	// 后台服务运行时交由服务执行，避免与定时备份同时运行同一任务
+	if c, _, ok := daemonClient(cfgPath); ok {
+		if err := runInDaemon(c); err != nil {
+			fmt.Printf("❌ 备份失败: %v\n", err)
+		} else {
+			fmt.Println("✅ 备份成功完成")
+		}
+		pauseForKey()
+		return
+	}
 	cfg, err := config.LoadConfig(cfgPath)
 	if err != nil {
 		fmt.Printf("❌ 加载配置失败: %v\n", err)
@@ -63,7 +89,7 @@ func handleImmediateBackup(cfgPath string) {
 	}
 
 	fmt.Println("正在执行备份...")
-	if err := task.RunBackup(cfg); err != nil {
+	if err := task.RunBackup(cfg, history.TriggerManual); err != nil {
 		fmt.Printf("❌ 备份失败: %v\n", err)
 	} else {
 		fmt.Println("✅ 备份成功完成")
@@ -71,13 +97,185 @@ func handleImmediateBackup(cfgPath string) {
 	pauseForKey()
 }
 
+func handleRestore(cfgPath string) {
+	clearScreen()
+	fmt.Println("♻️  恢复备份")
+	cfg, err := config.LoadConfig(cfgPath)
+	if err != nil {
+		fmt.Printf("❌ 加载配置失败: %v\n", err)
+		pauseForKey()
+		return
+	}
+
+	job, ok := selectJob(cfg)
+	if !ok {
+		pauseForKey()
+		return
+	}
+
+	store, err := storage.New(cfg)
+	if err != nil {
+		fmt.Printf("❌ 存储客户端创建失败: %v\n", err)
+		pauseForKey()
+		return
+	}
+	backups, err := task.ListBackups(cfg, store, job)
+	if err != nil {
+		fmt.Printf("❌ 获取备份列表失败: %v\n", err)
+		pauseForKey()
+		return
+	}
+	if len(backups) == 0 {
+		fmt.Println("暂无可用备份")
+		pauseForKey()
+		return
+	}
+
+	for i, b := range backups {
+		kind := "完整"
+		if b.Incremental {
+			kind = "增量"
+		}
+		fmt.Printf("  %2d. %s  %s  %s  %s\n", i+1, b.Time.Format("2006-01-02 15:04:05"), kind,
+			humanize.Bytes(uint64(b.Size)), b.Key)
+	}
+
+	name := task.LatestBackup
+	if choice := getUserInput("请选择备份序号 (直接回车选择最新): "); choice != "" {
+		idx, err := strconv.Atoi(choice)
+		if err != nil || idx < 1 || idx > len(backups) {
+			fmt.Println("无效选项")
+			pauseForKey()
+			return
+		}
+		name = backups[idx-1].Key
+	}
+
+	targetDir := getUserInput("请输入恢复目标目录: ")
+	if targetDir == "" {
+		fmt.Println("未指定目标目录，已取消")
+		pauseForKey()
+		return
+	}
+
+	fmt.Println("正在执行恢复...")
+	if err := task.RunRestore(cfg, job, name, targetDir); err != nil {
+		fmt.Printf("❌ 恢复失败: %v\n", err)
+	} else {
+		fmt.Println("✅ 恢复成功完成")
+	}
+	pauseForKey()
+}
+
+func handleVerify(cfgPath string) {
+	clearScreen()
+	fmt.Println("🔍 校验备份")
+	cfg, err := config.LoadConfig(cfgPath)
+	if err != nil {
+		fmt.Printf("❌ 加载配置失败: %v\n", err)
+		pauseForKey()
+		return
+	}
+
+	job, ok := selectJob(cfg)
+	if !ok {
+		pauseForKey()
+		return
+	}
+
+	name := getUserInput("请输入要校验的备份 (latest / 备份文件名，直接回车校验全部): ")
+	fmt.Println("正在校验备份（将完整下载并解码，不写入文件）...")
+	if err := task.RunVerify(cfg, job, name); err != nil {
+		fmt.Printf("❌ 校验失败: %v\n", err)
+	} else {
+		fmt.Println("✅ 校验通过")
+	}
+	pauseForKey()
+}
+
+// handlePrune 先预演清理计划，用户确认后再实际删除
+func handlePrune(cfgPath string) {
+	clearScreen()
+	fmt.Println("🧹 清理过期备份")
+	cfg, err := config.LoadConfig(cfgPath)
+	if err != nil {
+		fmt.Printf("❌ 加载配置失败: %v\n", err)
+		pauseForKey()
+		return
+	}
+
+	job, ok := selectJob(cfg)
+	if !ok {
+		pauseForKey()
+		return
+	}
+
+	fmt.Println("清理计划（预演，不会删除任何备份）:")
+	if err := task.RunPrune(cfg, job, true); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		pauseForKey()
+		return
+	}
+	if cfg.Cos.PruneDryRun {
+		fmt.Println("配置中启用了 prune_dry_run，不执行实际删除")
+		pauseForKey()
+		return
+	}
+	if strings.ToLower(getUserInput("确认按以上计划删除? (y/N): ")) != "y" {
+		fmt.Println("已取消")
+		pauseForKey()
+		return
+	}
+	if err := task.RunPrune(cfg, job, false); err != nil {
+		fmt.Printf("❌ 清理失败: %v\n", err)
+	} else {
+		fmt.Println("✅ 清理完成")
+	}
+	pauseForKey()
+}
+
+func handleHistory(cfgPath string) {
+	clearScreen()
+	fmt.Println("📜 运行历史")
+	var err error
+	if c, _, ok := daemonClient(cfgPath); ok {
+		var records []history.Record
+		if records, err = c.History("", task.DefaultHistoryLimit); err == nil {
+			err = task.PrintHistory(os.Stdout, records)
+		}
+	} else {
+		err = task.RunHistory("", task.DefaultHistoryLimit)
+	}
+	if err != nil {
+		fmt.Printf("❌ 读取运行历史失败: %v\n", err)
+	}
+	pauseForKey()
+}
+
+// selectJob 配置了多个任务时让用户选择其中一个
+func selectJob(cfg *config.Config) (config.JobConfig, bool) {
+	jobs := cfg.JobList()
+	if len(jobs) == 1 {
+		return jobs[0], true
+	}
+	for i, job := range jobs {
+		fmt.Printf("  %2d. %s (%s)\n", i+1, job.Name, job.Prefix)
+	}
+	idx, err := strconv.Atoi(getUserInput("请选择任务序号: "))
+	if err != nil || idx < 1 || idx > len(jobs) {
+		fmt.Println("无效选项")
+		return config.JobConfig{}, false
+	}
+	return jobs[idx-1], true
+}
+
 func handleConfigMenu(cfgPath string) {
 	for {
 		clearScreen()
 		fmt.Println("🔧 配置管理")
 		fmt.Println("  1. 查看配置文件")
 		fmt.Println("  2. 编辑配置文件")
-		fmt.Println("  3. 测试 COS 连接")
+		fmt.Println("  3. 测试存储连接")
 		fmt.Println("  4. 生成默认配置 (覆盖)")
 		fmt.Println("  0. 返回上一级")
 
@@ -93,7 +291,7 @@ func handleConfigMenu(cfgPath string) {
 		case "2":
 			editConfigFile(cfgPath)
 		case "3":
-			CheckConfigAndTestCOS(cfgPath)
+			CheckConfigAndTestStorage(cfgPath)
 			pauseForKey()
 		case "4":
 			if getUserInput("确认覆盖? (y/n): ") == "y" {
@@ -107,11 +305,12 @@ func handleConfigMenu(cfgPath string) {
 	}
 }
 
-func handleServiceMenu() {
+func handleServiceMenu(cfgPath string) {
 	svc := service.GetServiceManager()
 	for {
 		clearScreen()
 		status := svc.Status()
+		client, daemon, connected := daemonClient(cfgPath)
 		state := "已停止"
 		if status.Running {
 			state = fmt.Sprintf("运行中 (PID: %d)", status.PID)
@@ -123,11 +322,21 @@ func handleServiceMenu() {
 
 		fmt.Println("📋 服务管理")
 		fmt.Printf("当前状态: %s | 开机自启: %s\n", state, auto)
+		pauseAction := "暂停定时调度"
+		if connected {
+			schedule := "运行中"
+			if daemon.Paused {
+				schedule, pauseAction = "已暂停", "恢复定时调度"
+			}
+			fmt.Printf("定时调度: %s\n", schedule)
+		}
 		fmt.Println("  1. 安装服务 (开机自启)")
 		fmt.Println("  2. 卸载服务")
 		fmt.Println("  3. 启动服务")
 		fmt.Println("  4. 停止服务")
 		fmt.Println("  5. 重启服务")
+		fmt.Printf("  6. %s\n", pauseAction)
+		fmt.Println("  7. 重新加载配置")
 		fmt.Println("  0. 返回上一级")
 
 		switch getUserInput("选项: ") {
@@ -156,6 +365,24 @@ func handleServiceMenu() {
 				fmt.Printf("重启失败: %v\n", err)
 			}
 			pauseForKey()
+		case "6":
+			if !connected {
+				fmt.Println("无法连接后台服务，请先启动服务")
+			} else if _, err := client.SetPaused(!daemon.Paused); err != nil {
+				fmt.Printf("%s失败: %v\n", pauseAction, err)
+			} else {
+				fmt.Printf("已%s\n", pauseAction)
+			}
+			pauseForKey()
+		case "7":
+			if !connected {
+				fmt.Println("无法连接后台服务，请先启动服务")
+			} else if _, err := client.Reload(); err != nil {
+				fmt.Printf("重新加载失败: %v\n", err)
+			} else {
+				fmt.Println("后台服务已重新加载配置")
+			}
+			pauseForKey()
 		case "0":
 			return
 		}
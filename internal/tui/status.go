@@ -2,13 +2,17 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"backup-go/internal/config"
+	"backup-go/internal/control"
 	"backup-go/internal/core/archiver"
-	"backup-go/internal/core/uploader"
+	"backup-go/internal/core/storage"
+	"backup-go/internal/history"
 	"backup-go/internal/service"
+	"backup-go/internal/task"
 )
 
 // SystemStatus 系统状态结构体
@@ -16,13 +20,19 @@ type SystemStatus struct {
 	ServiceRunning    bool
 	ServicePID        int
 	ConfigLoaded      bool
+	Jobs              []string
 	DataDir           string
-	Bucket            string
+	DataSources       []archiver.Source
+	DataOptions       archiver.Options
+	Bucket            string // 存储位置，如 cos://bucket、local:/path
 	Prefix            string
 	LastBackup        time.Time
 	LastBackupSuccess bool
 	NextBackup        time.Time
 	ScheduleEnabled   bool
+	SchedulePaused    bool     // 后台服务已暂停定时调度
+	RunningJobs       []string // 后台服务正在执行的任务及阶段
+	RetryJobs         []string // 失败后等待重试的任务
 	ServiceAutoStart  bool
 }
 
@@ -33,17 +43,54 @@ func GetCurrentSystemStatus(cfgPath string) SystemStatus {
 	// 加载配置获取基础信息
 	if cfg, err := config.LoadConfig(cfgPath); err == nil {
 		status.ConfigLoaded = true
-		status.DataDir = cfg.Backup.DataDir
-		status.Bucket = cfg.Cos.Bucket
+		status.Bucket = cfg.StorageTarget()
 		status.Prefix = cfg.Cos.Prefix
-		status.ScheduleEnabled = cfg.Backup.Schedule.Enabled
 
-		// 计算下次备份时间
-		if status.ScheduleEnabled {
-			status.NextBackup = config.CalculateNextRunTime(cfg.Backup.Schedule)
+		var dirs []string
+		for _, job := range cfg.JobList() {
+			status.Jobs = append(status.Jobs, job.Name)
+			status.DataSources = append(status.DataSources, task.BackupSources(job.BackupConfig)...)
+			if len(job.Sources) > 0 {
+				dirs = append(dirs, job.Sources...)
+			} else {
+				dirs = append(dirs, job.DataDir)
+			}
+		}
+		status.DataDir = strings.Join(dirs, ", ")
+		if len(cfg.Jobs) == 0 {
+			status.DataOptions = task.BackupOptions(cfg.Backup)
+		}
+
+		// 计算下次备份时间（所有任务中最早的一次）
+		status.NextBackup, _ = config.NextJobRun(cfg.JobList())
+		status.ScheduleEnabled = !status.NextBackup.IsZero()
+
+		// 后台服务运行时通过控制接口读取调度状态与执行进度
+		if st, err := control.NewClient(cfg.Control).Status(); err == nil {
+			status.SchedulePaused = st.Paused
+			for _, js := range st.Jobs {
+				if !js.Running {
+					continue
+				}
+				if js.RetryPending {
+					status.RetryJobs = append(status.RetryJobs, js.Name)
+					continue
+				}
+				desc := js.Name
+				if js.Progress != nil {
+					desc += " (" + phaseText(js.Progress.Phase) + ")"
+				}
+				status.RunningJobs = append(status.RunningJobs, desc)
+			}
 		}
 	}
 
+	// 最近一次运行记录
+	if last, ok := history.Last(""); ok {
+		status.LastBackup = last.StartTime
+		status.LastBackupSuccess = last.Success()
+	}
+
 	// 检查服务状态
 	svc := service.GetServiceManager()
 	svcStatus := svc.Status()
@@ -58,10 +105,10 @@ func GetCurrentSystemStatus(cfgPath string) SystemStatus {
 func ShowSystemStatus(cfgPath string) {
 	status := GetCurrentSystemStatus(cfgPath)
 
-	// 综合配置和COS状态
+	// 综合配置和存储状态
 	configStatus := "❌ 未加载"
 	if status.ConfigLoaded {
-		configStatus = fmt.Sprintf("✅ 正常 (桶: %s)", status.Bucket)
+		configStatus = fmt.Sprintf("✅ 正常 (%s)", status.Bucket)
 	}
 
 	// 服务状态
@@ -73,7 +120,10 @@ func ShowSystemStatus(cfgPath string) {
 	// 定时任务
 	scheduleStatus := "○ 未启用"
 	if status.ScheduleEnabled {
-		scheduleStatus = fmt.Sprintf("✅ 已启用 (下次: %s)", status.NextBackup.Format("15:04:05"))
+		scheduleStatus = fmt.Sprintf("✅ 已启用 (下次: %s)", status.NextBackup.Format("01-02 15:04"))
+	}
+	if status.SchedulePaused {
+		scheduleStatus = "⏸️  已暂停"
 	}
 
 	// 开机自启
@@ -85,7 +135,8 @@ func ShowSystemStatus(cfgPath string) {
 	// 备份路径状态
 	dataStatus := "❌ 未配置"
 	if status.ConfigLoaded {
-		if size, err := archiver.CalculateDirSize(status.DataDir); err == nil {
+		// 多任务时各任务的过滤规则不同，此处仅统计目录总大小
+		if size, err := archiver.CalculateSourcesSize(status.DataSources, status.DataOptions); err == nil {
 			dataStatus = fmt.Sprintf("✅ 就绪 (%s)", humanize.Bytes(uint64(size)))
 		} else {
 			dataStatus = fmt.Sprintf("⚠️  无法读取 (%s)", status.DataDir)
@@ -93,29 +144,45 @@ func ShowSystemStatus(cfgPath string) {
 	}
 
 	fmt.Println("📊 系统状态检测:")
-	fmt.Printf("  🔧 配置与COS: %-30s | 🔄 服务: %s\n", configStatus, serviceStatus)
+	fmt.Printf("  🔧 配置与存储: %-30s | 🔄 服务: %s\n", configStatus, serviceStatus)
 	fmt.Printf("  ⏰ 定时任务: %-30s | 🚀 自启: %s\n", scheduleStatus, autoStartStatus)
 	fmt.Printf("  📁 数据目录: %s\n", dataStatus)
+	if !status.LastBackup.IsZero() {
+		result := "✅ 成功"
+		if !status.LastBackupSuccess {
+			result = "❌ 失败"
+		}
+		fmt.Printf("  🕒 上次备份: %s %s\n", status.LastBackup.Format("01-02 15:04"), result)
+	}
+	if len(status.RunningJobs) > 0 {
+		fmt.Printf("  ⏳ 正在备份: %s\n", strings.Join(status.RunningJobs, ", "))
+	}
+	if len(status.RetryJobs) > 0 {
+		fmt.Printf("  🔁 等待重试: %s（手动备份将立即执行）\n", strings.Join(status.RetryJobs, ", "))
+	}
+	if len(status.Jobs) > 1 {
+		fmt.Printf("  🗂️  备份任务: %s\n", strings.Join(status.Jobs, ", "))
+	}
 }
 
-// CheckConfigAndTestCOS 检查配置并测试 COS
-func CheckConfigAndTestCOS(cfgPath string) {
+// CheckConfigAndTestStorage 检查配置并测试存储连接
+func CheckConfigAndTestStorage(cfgPath string) {
 	cfg, err := config.LoadConfig(cfgPath)
 	if err != nil {
 		fmt.Printf("❌ 配置文件加载失败: %v\n", err)
 		return
 	}
 
-	fmt.Println("正在测试 COS 连接...")
-	client, err := uploader.NewClient(&cfg.Cos)
+	fmt.Printf("正在测试存储连接 (%s)...\n", cfg.StorageTarget())
+	store, err := storage.New(cfg)
 	if err != nil {
-		fmt.Printf("❌ COS 客户端创建失败: %v\n", err)
+		fmt.Printf("❌ 存储客户端创建失败: %v\n", err)
 		return
 	}
 
-	if err := uploader.TestConnection(client, cfg.Cos.Bucket); err != nil {
-		fmt.Printf("❌ COS 连接测试失败: %v\n", err)
+	if err := storage.TestConnection(store, cfg.Cos.Prefix); err != nil {
+		fmt.Printf("❌ 存储连接测试失败: %v\n", err)
 	} else {
-		fmt.Println("✅ COS 连接测试成功！")
+		fmt.Println("✅ 存储连接测试成功！")
 	}
 }